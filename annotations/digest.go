@@ -0,0 +1,235 @@
+package annotations
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/juruen/rmapi/log"
+	"github.com/juruen/rmapi/pdfbox"
+	"github.com/juruen/rmapi/render"
+)
+
+// A Digest lists the annotations of a document: the passages that were
+// highlighted and the pages with handwritten notes, meant to be pulled
+// into a knowledge base rather than read on the document itself.
+type Digest struct {
+	Name  string       `json:"name"`
+	Pages []DigestPage `json:"pages"`
+}
+
+// A DigestPage holds the annotations of a page.
+type DigestPage struct {
+	// Page is the number of the page in the document, from 1.
+	Page       int               `json:"page"`
+	Highlights []DigestHighlight `json:"highlights,omitempty"`
+	// Notes is the rendering of the handwritten notes of the page,
+	// relative to the digest, if the page has any and they are rendered.
+	Notes string `json:"notes,omitempty"`
+
+	data *rm.Rm
+}
+
+// A DigestHighlight is a highlighted passage of the text of a page.
+type DigestHighlight struct {
+	Text  string `json:"text"`
+	Color string `json:"color"`
+	// RGB is the color as shown on the device, like #ffed75.
+	RGB string `json:"rgb"`
+	// Start and Length locate the passage in the text of the page.
+	// They are zero for the text found under highlighter strokes.
+	Start  int `json:"start"`
+	Length int `json:"length"`
+}
+
+// NewDigest collects the annotations of the pages of a document.
+// The handwritten notes are rendered as name-<page>.<imageFormat>,
+// imageFormat being png or svg, and aren't rendered if it is empty.
+// The colors of the highlights are taken from palette, which may be nil
+// like the one of PdfGeneratorOptions.
+func NewDigest(z *archive.Zip, name, imageFormat string, palette rm.Palette) (*Digest, error) {
+	if imageFormat != "" && imageFormat != "png" && imageFormat != "svg" {
+		return nil, fmt.Errorf("unsupported image format: %s", imageFormat)
+	}
+
+	var text *pdfText
+	if z.Content.FileType == "pdf" && z.Payload != nil && textLicensed() {
+		if reader, err := pdfbox.Open(z.Payload); err != nil {
+			log.Warning.Printf("can't read the pdf to extract the text under the highlights: %v", err)
+		} else if boxes, err := pdfbox.Read(reader); err != nil {
+			log.Warning.Printf("can't read the crop boxes and the rotations of the pdf pages: %v", err)
+		} else {
+			text = newPdfText(reader, boxes)
+		}
+	}
+
+	d := &Digest{Name: name, Pages: []DigestPage{}}
+	for i, page := range z.Pages {
+		p := DigestPage{Page: i + 1}
+
+		for _, layer := range page.Highlights.LayerHighlights {
+			for _, h := range layer {
+				p.Highlights = append(p.Highlights, digestHighlight(palette, h.Text, h.Color, h.Start, h.Length))
+			}
+		}
+		// in the order of the text, whatever the layers
+		sort.SliceStable(p.Highlights, func(i, j int) bool {
+			return p.Highlights[i].Start < p.Highlights[j].Start
+		})
+
+		// highlighter strokes come after, from the top of the page
+		if text != nil && page.Data != nil && z.PayloadPage(i) >= 0 {
+			p.Highlights = append(p.Highlights, textHighlights(text, z.PayloadPage(i)+1, page.Data, palette)...)
+		}
+
+		if hasNotes(page.Data) {
+			p.data = page.Data
+			if imageFormat != "" {
+				p.Notes = fmt.Sprintf("%s-%d.%s", name, p.Page, imageFormat)
+			}
+		}
+
+		if len(p.Highlights) > 0 || p.data != nil {
+			d.Pages = append(d.Pages, p)
+		}
+	}
+
+	return d, nil
+}
+
+func digestHighlight(palette rm.Palette, text string, c rm.BrushColor, start, length int) DigestHighlight {
+	// older firmwares don't record the color of highlights
+	if c == rm.Black || c == rm.Grey || c == rm.White {
+		c = rm.HighlightYellow
+	}
+	rgba := palette.Highlight(c)
+
+	return DigestHighlight{
+		Text:   text,
+		Color:  c.String(),
+		RGB:    fmt.Sprintf("#%02x%02x%02x", rgba.R, rgba.G, rgba.B),
+		Start:  start,
+		Length: length,
+	}
+}
+
+// textHighlights returns the text of a pdf page under the highlighter
+// strokes of a page, the ones over no text being left out.
+func textHighlights(text *pdfText, pdfPage int, page *rm.Rm, palette rm.Palette) []DigestHighlight {
+	strokes := strokeHighlights(page, palette)
+	if len(strokes) == 0 {
+		return nil
+	}
+	pt := text.page(pdfPage)
+	if pt == nil {
+		return nil
+	}
+
+	var list []DigestHighlight
+	for _, h := range strokes {
+		if t := pt.under(quadRects(h.Highlight)); t != "" {
+			list = append(list, digestHighlight(palette, t, h.color, 0, 0))
+		}
+	}
+	return list
+}
+
+// hasNotes tells if a page has strokes other than highlights and erasers.
+func hasNotes(page *rm.Rm) bool {
+	if page == nil {
+		return false
+	}
+	for _, layer := range page.Layers {
+		for _, stroke := range layer.Strokes {
+			switch stroke.BrushType {
+			case rm.Highlighter, rm.HighlighterV5, rm.Eraser, rm.EraseArea:
+			default:
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WriteNotes renders the handwritten notes of the pages in dir.
+func (d *Digest) WriteNotes(dir string) error {
+	for _, p := range d.Pages {
+		if p.Notes == "" {
+			continue
+		}
+		if err := writeNotes(filepath.Join(dir, p.Notes), p.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeNotes(fileName string, page *rm.Rm) error {
+	out, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+
+	if strings.HasSuffix(fileName, ".svg") {
+		err = render.SVG(out, page)
+	} else {
+		err = render.PNG(out, page, render.RasterOptions{DPI: render.DeviceDPI})
+	}
+	if err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+// WriteJSON writes the digest as JSON.
+func (d *Digest) WriteJSON(w io.Writer) error {
+	bytes, err := json.MarshalIndent(d, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(bytes, '\n'))
+	return err
+}
+
+// WriteMarkdown writes the digest as Markdown, with a section per page.
+// Highlights are quoted, followed by their color.
+func (d *Digest) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n", d.Name)
+	for _, p := range d.Pages {
+		fmt.Fprintf(&b, "\n## Page %d\n", p.Page)
+
+		for _, h := range p.Highlights {
+			b.WriteString("\n")
+			for _, line := range strings.Split(strings.TrimSpace(h.Text), "\n") {
+				fmt.Fprintf(&b, "> %s\n", strings.TrimSpace(line))
+			}
+			fmt.Fprintf(&b, "\n*%s*\n", h.Color)
+		}
+
+		if p.Notes != "" {
+			fmt.Fprintf(&b, "\n![Notes of page %d](%s)\n", p.Page, markdownURL(p.Notes))
+		} else if p.data != nil {
+			b.WriteString("\nHandwritten notes\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownURL escapes the characters of a file name that would end
+// a link target.
+func markdownURL(name string) string {
+	r := strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29")
+	return r.Replace(name)
+}
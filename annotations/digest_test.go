@@ -0,0 +1,137 @@
+package annotations
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/encoding/rm"
+)
+
+func digestZip(t *testing.T) *archive.Zip {
+	z := archive.NewNotebook()
+	for i := 0; i < 3; i++ {
+		z.AddPage()
+	}
+
+	// two highlights in reverse order on the first page
+	err := json.Unmarshal([]byte(`{"highlights": [
+		[{"start": 20, "length": 5, "text": "later", "color": 4}],
+		[{"start": 3, "length": 9, "text": "first one", "rects": []}]
+	]}`), &z.Pages[0].Highlights)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	segments := []rm.Segment{{X: 10, Y: 10, Width: 2, Pressure: 1}, {X: 100, Y: 100, Width: 2, Pressure: 1}}
+	// only highlighted with the pen, no notes
+	z.SetData(1, &rm.Rm{Version: rm.V5, Layers: []rm.Layer{{Strokes: []rm.Stroke{
+		{BrushType: rm.HighlighterV5, Segments: segments},
+	}}}})
+	z.SetData(2, &rm.Rm{Version: rm.V5, Layers: []rm.Layer{{Strokes: []rm.Stroke{
+		{BrushType: rm.FinelinerV5, Segments: segments},
+	}}}})
+
+	return z
+}
+
+func TestDigest(t *testing.T) {
+	d, err := NewDigest(digestZip(t), "my notes", "svg", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.Pages) != 2 || d.Pages[0].Page != 1 || d.Pages[1].Page != 3 {
+		t.Fatalf("unexpected pages %+v", d.Pages)
+	}
+
+	expected := []DigestHighlight{
+		{Text: "first one", Color: "highlight-yellow", RGB: "#ffed75", Start: 3, Length: 9},
+		{Text: "later", Color: "green", RGB: "#00ff00", Start: 20, Length: 5},
+	}
+	if !reflect.DeepEqual(d.Pages[0].Highlights, expected) {
+		t.Errorf("highlights %+v, expected %+v", d.Pages[0].Highlights, expected)
+	}
+	if d.Pages[0].Notes != "" || d.Pages[1].Notes != "my notes-3.svg" {
+		t.Errorf("unexpected notes %q and %q", d.Pages[0].Notes, d.Pages[1].Notes)
+	}
+
+	var md bytes.Buffer
+	if err := d.WriteMarkdown(&md); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"# my notes\n", "## Page 1\n", "> first one\n", "*green*", "![Notes of page 3](my%20notes-3.svg)"} {
+		if !strings.Contains(md.String(), s) {
+			t.Errorf("%q missing from the markdown:\n%s", s, md.String())
+		}
+	}
+
+	var js bytes.Buffer
+	if err := d.WriteJSON(&js); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Digest
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Pages[0], d.Pages[0]) {
+		t.Errorf("json page %+v, expected %+v", decoded.Pages[0], d.Pages[0])
+	}
+
+	dir, err := ioutil.TempDir("", "digest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := d.WriteNotes(dir); err != nil {
+		t.Fatal(err)
+	}
+	svg, err := ioutil.ReadFile(filepath.Join(dir, "my notes-3.svg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(svg, []byte("<svg")) {
+		t.Error("the notes aren't an svg image")
+	}
+}
+
+func TestDigestPalette(t *testing.T) {
+	palette := rm.Palette{rm.Green: {R: 1, G: 2, B: 3, A: 255}}
+	d, err := NewDigest(digestZip(t), "doc", "", palette)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rgb := d.Pages[0].Highlights[1].RGB; rgb != "#010203" {
+		t.Errorf("the green highlight is %s, want the color of the palette", rgb)
+	}
+	if rgb := d.Pages[0].Highlights[0].RGB; rgb != "#ffed75" {
+		t.Errorf("the yellow highlight is %s, want the default color", rgb)
+	}
+}
+
+func TestDigestWithoutImages(t *testing.T) {
+	d, err := NewDigest(digestZip(t), "doc", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var md bytes.Buffer
+	if err := d.WriteMarkdown(&md); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(md.String(), "Handwritten notes") || strings.Contains(md.String(), "![") {
+		t.Errorf("unexpected markdown:\n%s", md.String())
+	}
+
+	if _, err := NewDigest(digestZip(t), "doc", "gif", nil); err == nil {
+		t.Error("expected an error for an unsupported image format")
+	}
+}
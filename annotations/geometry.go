@@ -19,6 +19,8 @@ type Rect struct {
 	UR Point
 }
 
+// Intersects tells if two rectangles overlap, the ones without area
+// or only touching each other not overlapping.
 func (r *Rect) Intersects(b Rect) bool {
 	if r.LL.X == r.UR.X ||
 		r.LL.Y == r.UR.Y ||
@@ -27,8 +29,8 @@ func (r *Rect) Intersects(b Rect) bool {
 		return false
 	}
 
-	if r.LL.X >= b.UR.Y ||
-		b.LL.X >= r.UR.Y {
+	if r.LL.X >= b.UR.X ||
+		b.LL.X >= r.UR.X {
 		return false
 	}
 
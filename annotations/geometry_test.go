@@ -0,0 +1,28 @@
+package annotations
+
+import "testing"
+
+func TestRectIntersects(t *testing.T) {
+	r := Rect{LL: Point{X: 0, Y: 100}, UR: Point{X: 50, Y: 110}}
+	tests := []struct {
+		b        Rect
+		expected bool
+	}{
+		{Rect{LL: Point{X: 40, Y: 105}, UR: Point{X: 90, Y: 115}}, true},
+		// apart on the x axis, though overlapping when x is compared with y
+		{Rect{LL: Point{X: 60, Y: 100}, UR: Point{X: 120, Y: 110}}, false},
+		{Rect{LL: Point{X: 20, Y: 0}, UR: Point{X: 80, Y: 90}}, false},
+		// touching
+		{Rect{LL: Point{X: 50, Y: 100}, UR: Point{X: 60, Y: 110}}, false},
+		// no area
+		{Rect{LL: Point{X: 10, Y: 105}, UR: Point{X: 10, Y: 108}}, false},
+	}
+	for _, tt := range tests {
+		if got := r.Intersects(tt.b); got != tt.expected {
+			t.Errorf("%+v intersects %+v: %v, expected %v", r, tt.b, got, tt.expected)
+		}
+		if got := tt.b.Intersects(r); got != tt.expected {
+			t.Errorf("%+v intersects %+v: %v, expected %v", tt.b, r, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package annotations
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"unicode/utf16"
+
+	"github.com/juruen/rmapi/log"
+	"github.com/juruen/rmapi/pdfbox"
+	"github.com/juruen/rmapi/util"
+	"github.com/phpdave/gofpdf"
+	"github.com/unidoc/unipdf/v3/core"
+	pdfmodel "github.com/unidoc/unipdf/v3/model"
+)
+
+// A pdfDest is a destination in a pdf: a page, numbered from 1, and the
+// left and the top of the view in the coordinates of the page, NaN if not
+// given. The left matters on rotated pages.
+type pdfDest struct {
+	page int
+	left float64
+	top  float64
+}
+
+// pdfOutlineItem is an entry of an outline flattened depth first.
+type pdfOutlineItem struct {
+	title string
+	level int
+	dest  pdfDest
+}
+
+// pdfLink is a link annotation, to a web page or to a destination.
+type pdfLink struct {
+	rect pdfmodel.PdfRectangle
+	uri  string
+	dest pdfDest
+}
+
+// pdfNavigation holds the outline and the links of a pdf, which are lost
+// when its pages are imported by gofpdi.
+type pdfNavigation struct {
+	outline []pdfOutlineItem
+	links   map[int][]pdfLink
+
+	reader *pdfmodel.PdfReader
+	names  map[string]core.PdfObject
+}
+
+// maxDepth limits the recursion on malformed files.
+const maxDepth = 32
+
+// readNavigation reads the outline and the links of a pdf.
+func readNavigation(reader *pdfmodel.PdfReader) (*pdfNavigation, error) {
+	var nav *pdfNavigation
+	err := util.Safely(func() error {
+		var err error
+		nav, err = newNavigation(reader)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nav, nil
+}
+
+// newNavigation reads the navigation of a pdf for readNavigation.
+func newNavigation(reader *pdfmodel.PdfReader) (*pdfNavigation, error) {
+	nav := &pdfNavigation{
+		links:  make(map[int][]pdfLink),
+		reader: reader,
+		names:  make(map[string]core.PdfObject),
+	}
+
+	trailer, err := reader.GetTrailer()
+	if err != nil {
+		return nil, err
+	}
+	catalog, ok := core.GetDict(trailer.Get("Root"))
+	if !ok {
+		return nil, fmt.Errorf("missing catalog")
+	}
+	nav.readNames(catalog)
+
+	if outlines, ok := core.GetDict(catalog.Get("Outlines")); ok {
+		nav.readOutline(outlines.Get("First"), 0, make(map[core.PdfObject]bool))
+	}
+
+	count, err := reader.GetNumPages()
+	if err != nil {
+		return nil, err
+	}
+	for num := 1; num <= count; num++ {
+		// the links of a page that can't be read are left out
+		err := util.Safely(func() error {
+			return nav.readLinks(num)
+		})
+		if err != nil {
+			log.Warning.Printf("can't read the links of page %d: %v", num, err)
+		}
+	}
+
+	return nav, nil
+}
+
+// readNames reads the named destinations, from the catalog in pdf 1.1
+// and from the name tree of later versions.
+func (nav *pdfNavigation) readNames(catalog *core.PdfObjectDictionary) {
+	if dests, ok := core.GetDict(catalog.Get("Dests")); ok {
+		for _, key := range dests.Keys() {
+			nav.names[string(key)] = dests.Get(key)
+		}
+	}
+
+	var walk func(node core.PdfObject, depth int)
+	walk = func(node core.PdfObject, depth int) {
+		dict, ok := core.GetDict(node)
+		if !ok || depth > maxDepth {
+			return
+		}
+		if names, ok := core.GetArray(dict.Get("Names")); ok {
+			for i := 0; i+1 < names.Len(); i += 2 {
+				if key, ok := core.GetStringVal(names.Get(i)); ok {
+					nav.names[key] = names.Get(i + 1)
+				}
+			}
+		}
+		if kids, ok := core.GetArray(dict.Get("Kids")); ok {
+			for _, kid := range kids.Elements() {
+				walk(kid, depth+1)
+			}
+		}
+	}
+	if names, ok := core.GetDict(catalog.Get("Names")); ok {
+		walk(names.Get("Dests"), 0)
+	}
+}
+
+// readOutline reads the items of the outline from item and its siblings.
+func (nav *pdfNavigation) readOutline(item core.PdfObject, level int, seen map[core.PdfObject]bool) {
+	for item != nil && level < maxDepth {
+		if seen[item] {
+			return
+		}
+		seen[item] = true
+
+		dict, ok := core.GetDict(item)
+		if !ok {
+			return
+		}
+
+		entry := pdfOutlineItem{level: level, dest: pdfDest{left: math.NaN(), top: math.NaN()}}
+		if title, ok := core.GetString(dict.Get("Title")); ok {
+			entry.title = title.Decoded()
+		}
+		if dest, ok := nav.resolve(dict.Get("Dest"), 0); ok {
+			entry.dest = dest
+		} else if _, dest, ok := nav.action(dict.Get("A")); ok {
+			entry.dest = dest
+		}
+		nav.outline = append(nav.outline, entry)
+
+		nav.readOutline(dict.Get("First"), level+1, seen)
+		item = dict.Get("Next")
+	}
+}
+
+// readLinks reads the link annotations of a page.
+func (nav *pdfNavigation) readLinks(num int) error {
+	page, err := nav.reader.GetPage(num)
+	if err != nil {
+		return err
+	}
+	annots, err := page.GetAnnotations()
+	if err != nil {
+		return err
+	}
+	for _, annot := range annots {
+		ctx, ok := annot.GetContext().(*pdfmodel.PdfAnnotationLink)
+		if !ok {
+			continue
+		}
+		arr, ok := core.GetArray(annot.Rect)
+		if !ok {
+			continue
+		}
+		rect, err := pdfmodel.NewPdfRectangle(*arr)
+		if err != nil {
+			continue
+		}
+
+		link := pdfLink{rect: pdfbox.Normalize(*rect)}
+		if dest, ok := nav.resolve(ctx.Dest, 0); ok {
+			link.dest = dest
+		} else if uri, dest, ok := nav.action(ctx.A); ok {
+			link.uri, link.dest = uri, dest
+		} else {
+			continue
+		}
+		nav.links[num] = append(nav.links[num], link)
+	}
+	return nil
+}
+
+// action returns the target of a GoTo or an URI action.
+func (nav *pdfNavigation) action(obj core.PdfObject) (string, pdfDest, bool) {
+	dict, ok := core.GetDict(obj)
+	if !ok {
+		return "", pdfDest{}, false
+	}
+	switch name, _ := core.GetNameVal(dict.Get("S")); name {
+	case "URI":
+		if uri, ok := core.GetStringVal(dict.Get("URI")); ok {
+			return uri, pdfDest{}, true
+		}
+	case "GoTo":
+		dest, ok := nav.resolve(dict.Get("D"), 0)
+		return "", dest, ok
+	}
+	return "", pdfDest{}, false
+}
+
+// resolve returns the page and the position of an explicit or named destination.
+func (nav *pdfNavigation) resolve(obj core.PdfObject, depth int) (pdfDest, bool) {
+	if obj == nil || depth > 2 {
+		return pdfDest{}, false
+	}
+
+	switch v := core.TraceToDirectObject(obj).(type) {
+	case *core.PdfObjectName:
+		return nav.resolve(nav.names[string(*v)], depth+1)
+	case *core.PdfObjectString:
+		return nav.resolve(nav.names[v.Str()], depth+1)
+	case *core.PdfObjectDictionary:
+		return nav.resolve(v.Get("D"), depth+1)
+	case *core.PdfObjectArray:
+		return nav.explicitDest(v)
+	}
+	return pdfDest{}, false
+}
+
+// explicitDest reads a [page /XYZ left top zoom] like destination.
+func (nav *pdfNavigation) explicitDest(arr *core.PdfObjectArray) (pdfDest, bool) {
+	if arr.Len() == 0 {
+		return pdfDest{}, false
+	}
+
+	dest := pdfDest{left: math.NaN(), top: math.NaN()}
+	if ind, ok := core.GetIndirect(arr.Get(0)); ok {
+		_, num, err := nav.reader.PageFromIndirectObject(ind)
+		if err != nil {
+			return pdfDest{}, false
+		}
+		dest.page = num
+	} else if idx, ok := core.GetIntVal(arr.Get(0)); ok {
+		// only expected in remote destinations, but seen in the wild
+		dest.page = idx + 1
+	} else {
+		return pdfDest{}, false
+	}
+
+	leftIdx, topIdx := -1, -1
+	switch mode, _ := core.GetNameVal(arr.Get(1)); mode {
+	case "XYZ":
+		leftIdx, topIdx = 2, 3
+	case "FitH", "FitBH":
+		topIdx = 2
+	case "FitV", "FitBV":
+		leftIdx = 2
+	case "FitR":
+		leftIdx, topIdx = 2, 5
+	}
+	number := func(idx int) float64 {
+		if idx > 0 && idx < arr.Len() {
+			if v, err := core.GetNumberAsFloat(core.TraceToDirectObject(arr.Get(idx))); err == nil {
+				return v
+			}
+		}
+		return math.NaN()
+	}
+	dest.left, dest.top = number(leftIdx), number(topIdx)
+
+	return dest, true
+}
+
+// A generatedPage is a page generated from a page of a pdf.
+type generatedPage struct {
+	num    int
+	height float64
+}
+
+// addNavigation adds the outline and the links of a pdf to the pages
+// generated from it, boxes being the ones of its pages. pages maps the pages
+// of the pdf to the generated ones, whose pdf page is drawn as shown from
+// their top left corner, any padding being at the right or the bottom, so
+// that positions from the top are kept.
+func addNavigation(pdf *gofpdf.Fpdf, nav *pdfNavigation, boxes map[int]pdfbox.Box, pages map[int]generatedPage) {
+	if len(pages) == 0 {
+		return
+	}
+	last := pdf.PageNo()
+	_, lastHeight := pdf.GetPageSize()
+	// the titles are given in UTF-16, which gofpdf leaves as they are
+	// with the core fonts, rather than embedding a UTF-8 font for them
+	pdf.SetFont("Helvetica", "", 12)
+
+	// y of a destination from the top of its generated page
+	destY := func(dest pdfDest) float64 {
+		// the top is the left on pages rotated by 90 or 270 degrees,
+		// a coordinate which isn't given keeps the view at the top
+		_, y := boxes[dest.page].FromPdf(dest.left, dest.top)
+		if math.IsNaN(y) {
+			return 0
+		}
+		return math.Max(0, y)
+	}
+
+	var sources []int
+	for src := range pages {
+		sources = append(sources, src)
+	}
+	sort.Ints(sources)
+
+	for _, src := range sources {
+		links := nav.links[src]
+		if len(links) == 0 {
+			continue
+		}
+		pdf.SetPage(pages[src].num)
+		box := boxes[src]
+		for _, link := range links {
+			x, y, w, h := box.FromPdfRect(link.rect)
+			if link.uri != "" {
+				pdf.LinkString(x, y, w, h, link.uri)
+				continue
+			}
+			target, ok := pages[link.dest.page]
+			if !ok {
+				continue
+			}
+			id := pdf.AddLink()
+			pdf.SetLink(id, destY(link.dest), target.num)
+			pdf.Link(x, y, w, h, id)
+		}
+	}
+
+	// entries of pages that weren't generated go to the next generated
+	// page, the outline keeping its structure
+	for _, item := range nav.outline {
+		target, y := generatedPage{num: last, height: lastHeight}, 0.0
+		if page, ok := pages[item.dest.page]; ok {
+			target, y = page, destY(item.dest)
+		} else if idx := sort.SearchInts(sources, item.dest.page); idx < len(sources) {
+			target = pages[sources[idx]]
+		}
+
+		// gofpdf positions all the bookmarks with the height of the last page
+		y += lastHeight - target.height
+
+		pdf.SetPage(target.num)
+		pdf.Bookmark(utf16Text(item.title), item.level, y)
+	}
+
+	pdf.SetPage(last)
+}
+
+// utf16Text returns the UTF-16 encoding of a text string of a pdf.
+func utf16Text(s string) string {
+	b := []byte{0xfe, 0xff}
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(c>>8), byte(c))
+	}
+	return string(b)
+}
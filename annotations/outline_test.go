@@ -0,0 +1,221 @@
+package annotations
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/log"
+	"github.com/juruen/rmapi/pdfbox"
+	"github.com/juruen/rmapi/pdffont"
+	"github.com/phpdave/gofpdf"
+	pdfmodel "github.com/unidoc/unipdf/v3/model"
+)
+
+// navigationPdf makes an A4 pdf of 3 pages with an outline, a link to the
+// last page on the first one and a link to a web page on the second one.
+func navigationPdf(t *testing.T) []byte {
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{UnitStr: "pt", Size: gofpdf.SizeType{Wd: 595, Ht: 842}})
+	pdf.SetFont("Helvetica", "", 12)
+
+	target := pdf.AddLink()
+	pdf.AddPage()
+	pdf.Bookmark("Chapter 1", 0, 0)
+	pdf.Bookmark("Section 1.1", 1, 100)
+	pdf.Link(50, 200, 100, 20, target)
+
+	pdf.AddPage()
+	pdf.LinkString(50, 300, 120, 20, "https://example.com")
+
+	pdf.AddPage()
+	pdf.SetLink(target, 400, 3)
+	pdf.Bookmark("Chapter 2", 0, 400)
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadNavigation(t *testing.T) {
+	reader, _ := readFixture(t, navigationPdf(t))
+	nav, err := readNavigation(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []pdfOutlineItem{
+		{title: "Chapter 1", level: 0, dest: pdfDest{page: 1, top: 842}},
+		{title: "Section 1.1", level: 1, dest: pdfDest{page: 1, top: 742}},
+		{title: "Chapter 2", level: 0, dest: pdfDest{page: 3, top: 442}},
+	}
+	if len(nav.outline) != len(expected) {
+		t.Fatalf("outline %+v, expected %+v", nav.outline, expected)
+	}
+	for i, item := range nav.outline {
+		e := expected[i]
+		if item.title != e.title || item.level != e.level || item.dest.page != e.dest.page || math.Abs(item.dest.top-e.dest.top) > 0.1 {
+			t.Errorf("outline item %d: %+v, expected %+v", i, item, e)
+		}
+	}
+
+	if links := nav.links[1]; len(links) != 1 || links[0].dest.page != 3 || math.Abs(links[0].rect.Ury-642) > 0.1 {
+		t.Errorf("unexpected links on page 1: %+v", links)
+	}
+	if links := nav.links[2]; len(links) != 1 || links[0].uri != "https://example.com" {
+		t.Errorf("unexpected links on page 2: %+v", links)
+	}
+}
+
+func TestReadNavigationSkipsBrokenLinks(t *testing.T) {
+	log.InitLog()
+	// the annotations of the second page aren't an array
+	reader, _ := readFixture(t, fixturePdf("/MediaBox [0 0 595 842]", "", "/Annots (x)", ""))
+	nav, err := readNavigation(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, num := range []int{1, 3} {
+		if links := nav.links[num]; len(links) != 1 || links[0].uri != "https://example.com" {
+			t.Errorf("unexpected links on page %d: %+v", num, links)
+		}
+	}
+	if links := nav.links[2]; len(links) != 0 {
+		t.Errorf("unexpected links on page 2: %+v", links)
+	}
+}
+
+func TestGenerateKeepsNavigation(t *testing.T) {
+	log.InitLog()
+
+	dir, err := ioutil.TempDir("", "navigation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	z := archive.NewZip()
+	z.Content.FileType = "pdf"
+	z.Payload = navigationPdf(t)
+	for i := 0; i < 3; i++ {
+		z.AddPage()
+	}
+
+	zipName := filepath.Join(dir, "doc.zip")
+	out, err := os.Create(zipName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := z.Write(out); err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+
+	pdfName := filepath.Join(dir, "doc.pdf")
+	if err := CreatePdfGenerator(zipName, pdfName, PdfGeneratorOptions{AllPages: true}).Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	generated, err := ioutil.ReadFile(pdfName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, boxes := readFixture(t, generated)
+	nav, err := readNavigation(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// narrow pages are padded at the right to the ratio of the device,
+	// positions from the top are kept
+	if width := boxes[3].Rect.Urx; width <= 595 {
+		t.Fatalf("the page should be padded, width %v", width)
+	}
+	height := boxes[3].Rect.Ury
+
+	if len(nav.outline) != 3 || nav.outline[1].title != "Section 1.1" || nav.outline[1].level != 1 {
+		t.Fatalf("outline not kept: %+v", nav.outline)
+	}
+	if d := nav.outline[2].dest; d.page != 3 || math.Abs(height-d.top-400) > 0.1 {
+		t.Errorf("wrong destination of Chapter 2: %+v", d)
+	}
+
+	if links := nav.links[1]; len(links) != 1 || links[0].dest.page != 3 || math.Abs(height-links[0].rect.Ury-200) > 0.1 {
+		t.Errorf("link to page 3 not kept: %+v", links)
+	} else if math.Abs(height-links[0].dest.top-400) > 0.1 {
+		t.Errorf("wrong destination of the link: %+v", links[0].dest)
+	}
+	if links := nav.links[2]; len(links) != 1 || links[0].uri != "https://example.com" {
+		t.Errorf("web link not kept: %+v", links)
+	}
+}
+
+func TestAddNavigationPageHeights(t *testing.T) {
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{UnitStr: "pt", Size: gofpdf.SizeType{Wd: 595, Ht: 842}})
+	pdf.AddPage()
+	pdf.AddPageFormat("L", gofpdf.SizeType{Wd: 1000, Ht: 1300})
+
+	nav := &pdfNavigation{
+		outline: []pdfOutlineItem{{title: "First", dest: pdfDest{page: 1, top: 742}}},
+	}
+	boxes := map[int]pdfbox.Box{1: {Rect: pdfmodel.PdfRectangle{Urx: 595, Ury: 842}}}
+	addNavigation(pdf, nav, boxes, map[int]generatedPage{1: {num: 1, height: 842}, 2: {num: 2, height: 1300}})
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	reader, _ := readFixture(t, buf.Bytes())
+	read, err := readNavigation(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the bookmark of the first page isn't positioned with the height of the last one
+	if len(read.outline) != 1 || read.outline[0].dest.page != 1 || math.Abs(read.outline[0].dest.top-742) > 0.1 {
+		t.Errorf("wrong outline %+v", read.outline)
+	}
+}
+
+func TestAddNavigationTitles(t *testing.T) {
+	titles := []string{"Глава 1", "Ελληνικά – “café”", "Chapter (2)"}
+	for _, font := range []string{"Helvetica", pdffont.Text} {
+		pdf := gofpdf.NewCustom(&gofpdf.InitType{UnitStr: "pt", Size: gofpdf.SizeType{Wd: 595, Ht: 842}})
+		pdffont.Add(pdf, pdffont.Text)
+		pdf.SetFont(font, "", 12)
+		pdf.AddPage()
+
+		nav := &pdfNavigation{}
+		for _, title := range titles {
+			nav.outline = append(nav.outline, pdfOutlineItem{title: title, dest: pdfDest{page: 1, top: 842}})
+		}
+		boxes := map[int]pdfbox.Box{1: {Rect: pdfmodel.PdfRectangle{Urx: 595, Ury: 842}}}
+		addNavigation(pdf, nav, boxes, map[int]generatedPage{1: {num: 1, height: 842}})
+
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		reader, _ := readFixture(t, buf.Bytes())
+		read, err := readNavigation(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// whatever the font of the last page
+		if len(read.outline) != len(titles) {
+			t.Fatalf("%s: wrong outline %+v", font, read.outline)
+		}
+		for i, item := range read.outline {
+			if item.title != titles[i] {
+				t.Errorf("%s: got the title %q, want %q", font, item.title, titles[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package annotations
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/juruen/rmapi/log"
+	"github.com/juruen/rmapi/pdfbox"
+	pdfmodel "github.com/unidoc/unipdf/v3/model"
+	pdfrender "github.com/unidoc/unipdf/v3/render"
+)
+
+// fixturePdf writes a pdf whose page tree has the attributes treeAttrs,
+// inherited by its pages, each page having its own attributes. The pages
+// draw a square at 100 100 and link to a web page from 100 200 to 150 300.
+func fixturePdf(treeAttrs string, pageAttrs ...string) []byte {
+	objects := []string{"<< /Type /Catalog /Pages 2 0 R >>", ""}
+	kids := ""
+	for _, attrs := range pageAttrs {
+		page, content := len(objects)+1, len(objects)+2
+		kids += fmt.Sprintf("%d 0 R ", page)
+		stream := "0 0 1 rg 100 100 50 50 re f"
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /Resources << >> /Contents %d 0 R "+
+				"/Annots [<< /Type /Annot /Subtype /Link /Rect [100 200 150 300] /A << /S /URI /URI (https://example.com) >> >>] %s >>",
+				content, attrs),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d %s >>", kids, len(pageAttrs), treeAttrs)
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// readFixture parses a pdf and reads the boxes of its pages.
+func readFixture(t *testing.T, payload []byte) (*pdfmodel.PdfReader, map[int]pdfbox.Box) {
+	t.Helper()
+
+	reader, err := pdfbox.Open(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	boxes, err := pdfbox.Read(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reader, boxes
+}
+
+func TestReadPageBoxes(t *testing.T) {
+	payload := fixturePdf("/MediaBox [0 0 595 842] /Rotate 90",
+		"",
+		"/CropBox [50 60 545 792] /Rotate 0",
+		"/MediaBox [0 0 842 595] /Rotate -90",
+	)
+	_, boxes := readFixture(t, payload)
+
+	expected := map[int]pdfbox.Box{
+		1: {Rect: pdfmodel.PdfRectangle{Urx: 595, Ury: 842}, Rotation: 90, Media: pdfmodel.PdfRectangle{Urx: 595, Ury: 842}},
+		2: {Rect: pdfmodel.PdfRectangle{Llx: 50, Lly: 60, Urx: 545, Ury: 792}, Media: pdfmodel.PdfRectangle{Urx: 595, Ury: 842}},
+		3: {Rect: pdfmodel.PdfRectangle{Urx: 842, Ury: 595}, Rotation: 270, Media: pdfmodel.PdfRectangle{Urx: 842, Ury: 595}},
+	}
+	sizes := map[int][2]float64{1: {842, 595}, 2: {495, 732}, 3: {595, 842}}
+	for num, e := range expected {
+		if boxes[num] != e {
+			t.Errorf("page %d: %+v, expected %+v", num, boxes[num], e)
+		}
+		if w, h := boxes[num].Size(); w != sizes[num][0] || h != sizes[num][1] {
+			t.Errorf("page %d shown as %vx%v, expected %v", num, w, h, sizes[num])
+		}
+	}
+}
+
+func TestPdfRectRotated(t *testing.T) {
+	// a landscape scan, stored in portrait: the device shows its left at the top
+	p := &pageText{box: pdfbox.Box{Rect: pdfmodel.PdfRectangle{Urx: 595, Ury: 842}, Rotation: 90}}
+	scale := 842 / rmPageSize.Wd * PtPerPx
+
+	r := p.pdfRect(Rect{LL: Point{X: 100, Y: 200}, UR: Point{X: 300, Y: 250}})
+	expected := pdfmodel.PdfRectangle{Llx: 200 * scale, Urx: 250 * scale, Lly: 100 * scale, Ury: 300 * scale}
+	for _, v := range [][2]float64{{r.Llx, expected.Llx}, {r.Urx, expected.Urx}, {r.Lly, expected.Lly}, {r.Ury, expected.Ury}} {
+		if math.Abs(v[0]-v[1]) > 1e-3 {
+			t.Fatalf("pdfRect = %+v, expected %+v", r, expected)
+		}
+	}
+}
+
+// A generatedFixture is the annotation pdf generated from a pdf.
+type generatedFixture struct {
+	reader *pdfmodel.PdfReader
+	boxes  map[int]pdfbox.Box
+	nav    *pdfNavigation
+}
+
+// generateFixture generates the annotation pdf of a pdf whose pages have
+// the given drawings, by index, and reads the boxes and the links of the
+// generated pages.
+func generateFixture(t *testing.T, payload []byte, options PdfGeneratorOptions, drawings map[int]*rm.Rm) generatedFixture {
+	log.InitLog()
+
+	dir, err := ioutil.TempDir("", "pagebox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	_, boxes := readFixture(t, payload)
+	z := archive.NewZip()
+	z.Content.FileType = "pdf"
+	z.Payload = payload
+	for range boxes {
+		z.AddPage()
+	}
+	for idx, data := range drawings {
+		z.SetData(idx, data)
+	}
+
+	zipName := filepath.Join(dir, "doc.zip")
+	out, err := os.Create(zipName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := z.Write(out); err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+
+	pdfName := filepath.Join(dir, "doc.pdf")
+	if err := CreatePdfGenerator(zipName, pdfName, options).Generate(); err != nil {
+		t.Fatal(err)
+	}
+	generated, err := ioutil.ReadFile(pdfName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := generatedFixture{}
+	f.reader, f.boxes = readFixture(t, generated)
+	if f.nav, err = readNavigation(f.reader); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// strokeAcross draws a thick black line through the point x y of a pdf
+// page as shown, from its top left corner, which fills the width or the
+// height of the device like in Generate.
+func strokeAcross(box pdfbox.Box, x, y float64) *rm.Rm {
+	w, h := box.Size()
+	scale := h / rmPageSize.Ht
+	if w/h > rmPageSize.Wd/rmPageSize.Ht {
+		scale = w / rmPageSize.Wd
+	}
+	px := func(v float64) float32 {
+		return float32(v / scale / PtPerPx)
+	}
+
+	segments := []rm.Segment{
+		{X: px(x - 10), Y: px(y), Width: 8, Pressure: 1},
+		{X: px(x + 10), Y: px(y), Width: 8, Pressure: 1},
+	}
+	return &rm.Rm{Version: rm.V5, Layers: []rm.Layer{{Strokes: []rm.Stroke{
+		{BrushType: rm.FinelinerV5, BrushColor: rm.Black, Segments: segments},
+	}}}}
+}
+
+// checkSquare renders a generated page and checks that the square of the
+// fixture is drawn with its top left corner at x y, from the top left
+// corner of the page, a stroke going through its center.
+func checkSquare(t *testing.T, f generatedFixture, num int, x, y float64) {
+	t.Helper()
+
+	page, err := f.reader.GetPage(num)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := pdfrender.NewImageDevice().Render(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(dx, dy float64) color.Color {
+		return img.At(int(math.Round(x+dx)), int(math.Round(y+dy)))
+	}
+	blue := color.RGBA{B: 255, A: 255}
+	for _, p := range [][2]float64{{5, 5}, {45, 5}, {5, 45}, {45, 45}} {
+		if c := color.RGBAModel.Convert(at(p[0], p[1])); c != blue {
+			t.Errorf("page %d: the square should be at %v %v, found %v at %v", num, x, y, c, p)
+		}
+	}
+	for _, p := range [][2]float64{{-5, 25}, {55, 25}} {
+		if c := color.RGBAModel.Convert(at(p[0], p[1])); c == blue {
+			t.Errorf("page %d: the square should be at %v %v, found it at %v", num, x, y, p)
+		}
+	}
+	if r, g, b, _ := at(25, 25).RGBA(); r+g+b > 3*0x4000 {
+		t.Errorf("page %d: the stroke should be drawn on the square", num)
+	}
+}
+
+func TestGenerateRotated(t *testing.T) {
+	payload := fixturePdf("/MediaBox [0 0 595 842]", "/Rotate 90", "/Rotate 90")
+	_, boxes := readFixture(t, payload)
+	// the square from 100 100 to 150 150 is shown at the same place
+	f := generateFixture(t, payload, PdfGeneratorOptions{AllPages: true}, map[int]*rm.Rm{
+		0: strokeAcross(boxes[1], 125, 125),
+		1: strokeAcross(boxes[2], 125, 125),
+	})
+
+	// shown in landscape, wider than the device: padded at the bottom
+	height := 842 * rmPageSize.Ht / rmPageSize.Wd
+	box := f.boxes[1]
+	if box.Rotation != 0 || math.Abs(box.Rect.Width()-842) > 0.1 || math.Abs(box.Rect.Height()-height) > 0.1 {
+		t.Fatalf("unexpected page %+v", box)
+	}
+	checkSquare(t, f, 1, 100, 100)
+	checkSquare(t, f, 2, 100, 100)
+
+	// the link from 100 200 to 150 300 is shown from 200 100 to 300 150
+	links := f.nav.links[2]
+	expected := pdfmodel.PdfRectangle{Llx: 200, Lly: height - 150, Urx: 300, Ury: height - 100}
+	if len(links) != 1 {
+		t.Fatalf("unexpected links %+v", links)
+	}
+	r := links[0].rect
+	for _, v := range [][2]float64{{r.Llx, expected.Llx}, {r.Urx, expected.Urx}, {r.Lly, expected.Lly}, {r.Ury, expected.Ury}} {
+		if math.Abs(v[0]-v[1]) > 0.1 {
+			t.Fatalf("link at %+v, expected %+v", r, expected)
+		}
+	}
+}
+
+func TestGenerateCropped(t *testing.T) {
+	payload := fixturePdf("/MediaBox [0 0 595 842] /CropBox [50 60 545 792]", "")
+	_, boxes := readFixture(t, payload)
+	// the square from 100 100 to 150 150 moves with the corner of the crop box
+	f := generateFixture(t, payload,
+		PdfGeneratorOptions{AllPages: true, AddPageNumbers: true, PageNumberPosition: TopRight, PageNumberFormat: "{n} / {total}"},
+		map[int]*rm.Rm{0: strokeAcross(boxes[1], 75, 667)})
+
+	// narrower than the device: the crop box fills the height
+	box := f.boxes[1]
+	if math.Abs(box.Rect.Height()-732) > 0.1 || math.Abs(box.Rect.Width()-732*rmPageSize.Wd/rmPageSize.Ht) > 0.1 {
+		t.Fatalf("unexpected page %+v", box)
+	}
+	checkSquare(t, f, 1, 50, 642)
+
+	// the link from 100 200 to 150 300 moves with the corner of the crop box
+	expected := pdfmodel.PdfRectangle{Llx: 50, Lly: 140, Urx: 100, Ury: 240}
+	links := f.nav.links[1]
+	if len(links) != 1 {
+		t.Fatalf("unexpected links %+v", links)
+	}
+	r := links[0].rect
+	for _, v := range [][2]float64{{r.Llx, expected.Llx}, {r.Urx, expected.Urx}, {r.Lly, expected.Lly}, {r.Ury, expected.Ury}} {
+		if math.Abs(v[0]-v[1]) > 0.1 {
+			t.Fatalf("link at %+v, expected %+v", r, expected)
+		}
+	}
+}
+
+func TestGenerateMixedBoxes(t *testing.T) {
+	payload := fixturePdf("/MediaBox [0 0 595 842]",
+		"",
+		"/CropBox [50 60 545 792]",
+		"/Rotate 90",
+	)
+	_, boxes := readFixture(t, payload)
+	f := generateFixture(t, payload, PdfGeneratorOptions{AllPages: true}, map[int]*rm.Rm{
+		0: strokeAcross(boxes[1], 125, 717),
+		1: strokeAcross(boxes[2], 75, 667),
+		2: strokeAcross(boxes[3], 125, 125),
+	})
+
+	// each page is shown with its own box
+	checkSquare(t, f, 1, 100, 692)
+	checkSquare(t, f, 2, 50, 642)
+	checkSquare(t, f, 3, 100, 100)
+}
@@ -0,0 +1,74 @@
+package annotations
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/phpdave/gofpdf"
+)
+
+// Positions of the page numbers, BottomCenter being the default.
+const (
+	TopLeft      = "top-left"
+	TopCenter    = "top-center"
+	TopRight     = "top-right"
+	BottomLeft   = "bottom-left"
+	BottomCenter = "bottom-center"
+	BottomRight  = "bottom-right"
+)
+
+// PageNumberPositions lists the positions of the page numbers.
+var PageNumberPositions = []string{TopLeft, TopCenter, TopRight, BottomLeft, BottomCenter, BottomRight}
+
+// DefaultPageNumberFormat only shows the number of the page.
+const DefaultPageNumberFormat = "{n}"
+
+// pageNumberText replaces {n} with the number of the page and {total}
+// with the number of pages of the document in format.
+func pageNumberText(format string, n, total int) string {
+	if format == "" {
+		format = DefaultPageNumberFormat
+	}
+	return strings.NewReplacer("{n}", strconv.Itoa(n), "{total}", strconv.Itoa(total)).Replace(format)
+}
+
+// checkPageNumberPosition returns an error for an unknown position,
+// the empty one being the default.
+func checkPageNumberPosition(position string) error {
+	if position == "" {
+		return nil
+	}
+	for _, p := range PageNumberPositions {
+		if p == position {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown page number position %q, expected one of %s", position, strings.Join(PageNumberPositions, ", "))
+}
+
+// drawPageNumber writes text at a position of the current page, whose
+// size is in points, with a margin and a font size following its height.
+func drawPageNumber(pdf *gofpdf.Fpdf, text, position string, width, height float64) {
+	fontSize := math.Max(8, height/70)
+	margin := fontSize * 2
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	text = tr(text)
+
+	pdf.SetFont("Helvetica", "", fontSize)
+	pdf.SetTextColor(0, 0, 0)
+	textWidth := pdf.GetStringWidth(text)
+
+	x, y := (width-textWidth)/2, height-margin
+	if strings.HasPrefix(position, "top") {
+		y = margin + fontSize
+	}
+	switch {
+	case strings.HasSuffix(position, "left"):
+		x = margin
+	case strings.HasSuffix(position, "right"):
+		x = width - margin - textWidth
+	}
+	pdf.Text(x, y, text)
+}
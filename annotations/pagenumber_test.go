@@ -0,0 +1,75 @@
+package annotations
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/phpdave/gofpdf"
+)
+
+func TestPageNumberText(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{"", "3"},
+		{"{n}", "3"},
+		{"Page {n} of {total}", "Page 3 of 12"},
+		{"- {n} -", "- 3 -"},
+	}
+	for _, tt := range tests {
+		if text := pageNumberText(tt.format, 3, 12); text != tt.expected {
+			t.Errorf("pageNumberText(%q) = %q, expected %q", tt.format, text, tt.expected)
+		}
+	}
+}
+
+func TestCheckPageNumberPosition(t *testing.T) {
+	for _, p := range append(PageNumberPositions, "") {
+		if err := checkPageNumberPosition(p); err != nil {
+			t.Errorf("%q: %v", p, err)
+		}
+	}
+	if err := checkPageNumberPosition("middle"); err == nil {
+		t.Error("an unknown position should be rejected")
+	}
+}
+
+func TestDrawPageNumber(t *testing.T) {
+	const width, height = 600.0, 840.0
+	// 12pt with a margin of 24pt
+	const size, margin = 12.0, 24.0
+
+	newPdf := func() *gofpdf.Fpdf {
+		pdf := gofpdf.NewCustom(&gofpdf.InitType{UnitStr: "pt", Size: gofpdf.SizeType{Wd: width, Ht: height}})
+		pdf.SetCompression(false)
+		return pdf
+	}
+	pdf := newPdf()
+	pdf.SetFont("Helvetica", "", size)
+	textWidth := pdf.GetStringWidth("7 / 9")
+
+	// x and y from the bottom of the text on the page
+	positions := map[string][2]float64{
+		TopLeft:      {margin, height - margin - size},
+		BottomCenter: {(width - textWidth) / 2, margin},
+		"":           {(width - textWidth) / 2, margin},
+		BottomRight:  {width - margin - textWidth, margin},
+	}
+	for position, expected := range positions {
+		pdf := newPdf()
+		pdf.AddPage()
+		drawPageNumber(pdf, "7 / 9", position, width, height)
+
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		op := fmt.Sprintf("BT %.2F %.2F Td (7 / 9) Tj ET", expected[0], expected[1])
+		if !bytes.Contains(buf.Bytes(), []byte(op)) {
+			t.Errorf("%q: %s missing from the pdf", position, op)
+		}
+	}
+}
@@ -12,6 +12,8 @@ import (
 
 	"github.com/juruen/rmapi/archive"
 	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/juruen/rmapi/log"
+	"github.com/juruen/rmapi/pdfbox"
 	"github.com/phpdave/gofpdf"
 	"github.com/phpdave/gofpdf/contrib/gofpdi"
 )
@@ -36,6 +38,17 @@ type PdfGeneratorOptions struct {
 	AddPageNumbers  bool
 	AllPages        bool
 	AnnotationsOnly bool //export the annotations without the background/pdf
+
+	// PageNumberPosition is one of PageNumberPositions, BottomCenter by default
+	PageNumberPosition string
+	// PageNumberFormat is the text of the page numbers, where {n} is the
+	// number of the page and {total} the number of pages, {n} by default
+	PageNumberFormat string
+
+	// Palette maps the brush colors to the colors of the strokes and the
+	// highlights, rm.DefaultPalette being used for the colors missing from it.
+	// CMap still takes precedence.
+	Palette rm.Palette
 }
 
 var (
@@ -45,12 +58,38 @@ var (
 	Yellow = color.RGBA{R: 255, G: 240, B: 102, A: 77}
 )
 
+// CMap maps the brush colors to the colors of the strokes,
+// colors missing from it are taken from the palette of the options.
 var CMap = map[rm.BrushColor]color.Color{
 	rm.Black: Black,
 	rm.Grey:  Grey,
 	rm.White: White,
 }
 
+var defaultPalette = rm.DefaultPalette()
+
+// brushColor returns the color of a stroke, palette may be nil.
+func brushColor(palette rm.Palette, c rm.BrushColor) color.Color {
+	if col, ok := CMap[c]; ok && col != nil {
+		return col
+	}
+	if palette == nil {
+		palette = defaultPalette
+	}
+	return palette.RGBA(c)
+}
+
+// highlightColor returns the color of a highlight, Yellow for the
+// highlighters of older firmwares which don't record it.
+func highlightColor(palette rm.Palette, c rm.BrushColor) ([]float32, float32) {
+	col := Yellow
+	if c != rm.Black && c != rm.Grey && c != rm.White {
+		r, g, b, _ := brushColor(palette, c).RGBA()
+		col = color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: Yellow.A}
+	}
+	return []float32{float32(col.R) / 255, float32(col.G) / 255, float32(col.B) / 255}, float32(col.A) / 255
+}
+
 type Highlight struct {
 	Contents   string
 	Rect       []float32
@@ -93,34 +132,40 @@ func transformAnnots(annots []Highlight, scale float32, pageHeight float32) []Hi
 	return xformed
 }
 
+func isHighlighter(stroke rm.Stroke) bool {
+	return stroke.BrushType == rm.Highlighter || stroke.BrushType == rm.HighlighterV5
+}
+
+// strokeHighlight returns the highlight of a highlighter stroke, covering
+// its bounding box in the coordinates of the device. palette may be nil.
+func strokeHighlight(stroke rm.Stroke, palette rm.Palette) Highlight {
+	bbox := stroke.BoundingBox()
+	rect := Rect{LL: Point{X: bbox.MinX, Y: bbox.MinY}, UR: Point{X: bbox.MaxX, Y: bbox.MaxY}}
+	qp := rect.ToQuadPoints()
+	rgb, opacity := highlightColor(palette, stroke.BrushColor)
+
+	return Highlight{
+		Rect:       rect.ToList(),
+		QuadPoints: qp.ToList(),
+		Color:      rgb,
+		Opacity:    opacity,
+		Author:     "reMarkable",
+	}
+}
+
+// PaintStroke draws a stroke with the colors of rm.DefaultPalette,
+// highlights are added to highlights instead.
 func PaintStroke(stroke rm.Stroke, pdf *gofpdf.Fpdf, highlights *[]Highlight) error {
+	return paintStroke(stroke, pdf, highlights, nil)
+}
+
+func paintStroke(stroke rm.Stroke, pdf *gofpdf.Fpdf, highlights *[]Highlight, palette rm.Palette) error {
 	// Beware! Here lie magic numbers aplenty. Based on RMRL
 	// and hand tuned to get more-or-less correct appearance
-	r, g, b, _ := CMap[stroke.BrushColor].RGBA()
-
-	if stroke.BrushType == rm.Highlighter || stroke.BrushType == rm.HighlighterV5 {
-		var rect Rect
-		for i, segment := range stroke.Segments {
-			if i == 0 {
-				rect = Rect{LL: Point{X: segment.X - segment.Width/2, Y: segment.Y - segment.Width/2},
-					UR: Point{X: segment.X + segment.Width/2, Y: segment.Y + segment.Width/2}}
-			} else {
-				newRect := Rect{LL: Point{X: segment.X - segment.Width/2, Y: segment.Y - segment.Width/2},
-					UR: Point{X: segment.X + segment.Width/2, Y: segment.Y + segment.Width/2}}
-				rect = rect.Union(newRect)
-			}
-		}
-
-		qp := rect.ToQuadPoints()
-
-		*highlights = append(*highlights, Highlight{
-			Rect:       rect.ToList(),
-			QuadPoints: qp.ToList(),
-			Color:      []float32{float32(Yellow.R) / 255, float32(Yellow.G) / 255, float32(Yellow.B) / 255},
-			Opacity:    float32(Yellow.A) / 255,
-			Author:     "reMarkable",
-		})
+	r, g, b, _ := brushColor(palette, stroke.BrushColor).RGBA()
 
+	if isHighlighter(stroke) {
+		*highlights = append(*highlights, strokeHighlight(stroke, palette))
 		return nil
 	} else {
 		pdf.SetLineCapStyle("round")
@@ -216,30 +261,52 @@ func (p *PdfGenerator) Generate() error {
 		return errors.New("the document has no pages")
 	}
 
+	if p.options.AddPageNumbers {
+		if err := checkPageNumberPosition(p.options.PageNumberPosition); err != nil {
+			return err
+		}
+	}
+
 	pdf := gofpdf.NewCustom(&gofpdf.InitType{
 		UnitStr: "pt",
 		Size:    rmPageSize,
 	})
 
-	// TODO: outlines
-	/*
-		if p.pdfReader != nil && p.options.AllPages {
-			p.pdfReader.AddOutline()
-			outlines := p.pdfReader.GetOutlineTree()
-			c.SetOutlineTree(outlines)
-		}
-	*/
+	// text is laid out at the positions of the device
+	pdf.SetAutoPageBreak(false, 0)
+
 	pdf.OpenLayerPane()
 	var seeker io.ReadSeeker
 
 	layers := make([]int, 2)
 	layers[0] = pdf.AddLayer("Background", true)
 	layers[1] = pdf.AddLayer("Layer 1", true)
+	textLayer := -1
 
 	annotations := make([][]Highlight, 0, 2)
 
+	// the text under the highlights becomes their contents
+	var text *pdfText
+	// the outline and the links of the pdf are added back once its pages are generated
+	var nav *pdfNavigation
+	generated := make(map[int]generatedPage)
+	// the pages are shown cropped and rotated on the device
+	var boxes map[int]pdfbox.Box
 	if zip.Content.FileType == "pdf" && zip.Payload != nil {
 		seeker = io.ReadSeeker(bytes.NewReader(zip.Payload))
+		if reader, err := pdfbox.Open(zip.Payload); err != nil {
+			log.Warning.Printf("can't read the pdf: %v", err)
+		} else if boxes, err = pdfbox.Read(reader); err != nil {
+			// the links and the text can't be positioned without the boxes
+			log.Warning.Printf("can't read the crop boxes and the rotations of the pdf pages: %v", err)
+			boxes = nil
+		} else {
+			text = newPdfText(reader, boxes)
+			if nav, err = readNavigation(reader); err != nil {
+				log.Warning.Printf("can't read the outline and the links of the pdf: %v", err)
+				nav = nil
+			}
+		}
 	}
 
 	for i, page := range zip.Pages {
@@ -253,18 +320,20 @@ func (p *PdfGenerator) Generate() error {
 		scale := float64(100)
 		newHeight := float64(rmPageSize.Ht)
 		newWidth := float64(rmPageSize.Wd)
-		if zip.Content.FileType == "pdf" && zip.Payload != nil && seeker != nil {
-			tpl1 := gofpdi.ImportPageFromStream(pdf, &seeker, i+1, "/MediaBox")
-			sizes := gofpdi.GetPageSizes()
-			w := sizes[i+1]["/MediaBox"]["w"]
-			h := sizes[i+1]["/MediaBox"]["h"]
-			var orientation string
-			if w > h {
-				orientation = "L"
+		// pages inserted in a pdf document don't have a pdf page
+		pdfPage := zip.PayloadPage(i) + 1
+		if zip.Content.FileType == "pdf" && zip.Payload != nil && seeker != nil && pdfPage > 0 {
+			// gofpdi rotates the template, but takes the box of the first page
+			// and can't fall back to the media box: the page is cropped when drawn
+			tpl1 := gofpdi.ImportPageFromStream(pdf, &seeker, pdfPage, "/MediaBox")
+			var w, h float64
+			box, hasBox := boxes[pdfPage]
+			if hasBox {
+				w, h = box.Size()
 			} else {
-				orientation = "P"
+				sizes := gofpdi.GetPageSizes()
+				w, h = sizes[pdfPage]["/MediaBox"]["w"], sizes[pdfPage]["/MediaBox"]["h"]
 			}
-
 			// Need to resize the page so that it has the same aspect ratio as the reMarkable
 			pdfRatio := w / h
 			rmRatio := rmPageSize.Wd / rmPageSize.Ht
@@ -279,20 +348,37 @@ func (p *PdfGenerator) Generate() error {
 				scale = w / rmPageSize.Wd * 100
 			}
 
-			pdf.AddPageFormat(orientation, gofpdf.SizeType{Wd: newWidth, Ht: newHeight})
+			// the page has the portrait ratio of the device, gofpdf would
+			// swap its width and its height in landscape
+			pdf.AddPageFormat("P", gofpdf.SizeType{Wd: newWidth, Ht: newHeight})
+			if _, ok := generated[pdfPage]; !ok {
+				generated[pdfPage] = generatedPage{num: pdf.PageNo(), height: newHeight}
+			}
 			pdf.BeginLayer(layers[0])
-			gofpdi.UseImportedTemplate(pdf, tpl1, 0, 0, w, h)
+			if hasBox {
+				tx, ty, tw, th := box.TemplateRect(boxes[1])
+				pdf.ClipRect(0, 0, w, h, false)
+				gofpdi.UseImportedTemplate(pdf, tpl1, tx, ty, tw, th)
+				pdf.ClipEnd()
+			} else {
+				gofpdi.UseImportedTemplate(pdf, tpl1, 0, 0, w, h)
+			}
 			pdf.EndLayer()
 		} else { // No underlying PDF
 			pdf.AddPage()
 			scale = 100
 		}
 
+		if p.options.AddPageNumbers {
+			text := pageNumberText(p.options.PageNumberFormat, i+1, len(zip.Pages))
+			drawPageNumber(pdf, text, p.options.PageNumberPosition, newWidth, newHeight)
+		}
+
 		if !hasContent {
 			continue
 		}
 
-		for idx, layer := range page.Data.Layers {
+		for idx, layer := range rm.ResolveErasers(page.Data).Layers {
 			if idx+1 >= len(layers) {
 				layers = append(layers, pdf.AddLayer("Layer "+strconv.Itoa(idx), true))
 			}
@@ -307,7 +393,7 @@ func (p *PdfGenerator) Generate() error {
 					continue
 				}
 
-				err = PaintStroke(stroke, pdf, &annotations[idx])
+				err = paintStroke(stroke, pdf, &annotations[idx], p.options.Palette)
 				if err != nil {
 					continue
 				}
@@ -343,11 +429,12 @@ func (p *PdfGenerator) Generate() error {
 									r.X+r.Width, r.Y)
 							}
 
+							rgb, opacity := highlightColor(p.options.Palette, h.Color)
 							highlight := Highlight{
 								Rect:       rect.ToList(),
 								QuadPoints: qp,
-								Color:      []float32{float32(Yellow.R) / 255, float32(Yellow.G) / 255, float32(Yellow.B) / 255},
-								Opacity:    float32(Yellow.A) / 255,
+								Color:      rgb,
+								Opacity:    opacity,
 								Author:     "reMarkable",
 							}
 
@@ -370,6 +457,15 @@ func (p *PdfGenerator) Generate() error {
 			}
 
 			grouped := groupHighlights(annotations[idx])
+			if text != nil && pdfPage > 0 && len(grouped) > 0 {
+				if pt := text.page(pdfPage); pt != nil {
+					for j := range grouped {
+						if grouped[j].Contents == "" {
+							grouped[j].Contents = pt.under(quadRects(grouped[j]))
+						}
+					}
+				}
+			}
 			xformed := transformAnnots(grouped, float32(scale/100), float32(newHeight))
 			for _, annot := range xformed {
 				pdf.AddHighlightAnnotation(gofpdf.Highlight(annot))
@@ -378,6 +474,25 @@ func (p *PdfGenerator) Generate() error {
 			pdf.TransformEnd()
 			pdf.EndLayer()
 		}
+
+		// typed text of newer documents
+		if page.Data.Scene != nil {
+			if typed := page.Data.Scene.Text(); typed != nil {
+				if textLayer < 0 {
+					textLayer = pdf.AddLayer("Text", true)
+				}
+				pdf.BeginLayer(textLayer)
+				pdf.TransformBegin()
+				pdf.TransformScaleXY(scale, 0, 0)
+				drawTypedText(pdf, typed)
+				pdf.TransformEnd()
+				pdf.EndLayer()
+			}
+		}
+	}
+
+	if nav != nil {
+		addNavigation(pdf, nav, boxes, generated)
 	}
 
 	return pdf.OutputFileAndClose(p.outputFilePath)
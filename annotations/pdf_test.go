@@ -1,11 +1,17 @@
 package annotations
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
+
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/juruen/rmapi/log"
+	"github.com/phpdave/gofpdf"
 )
 
 func test(name string, t *testing.T) {
+	log.InitLog()
 	zip := fmt.Sprintf("testfiles/%s.zip", name)
 	outfile := fmt.Sprintf("/tmp/%s.pdf", name)
 	options := PdfGeneratorOptions{AddPageNumbers: true, AllPages: true, AnnotationsOnly: false}
@@ -42,3 +48,52 @@ func TestGenerateStrangeBug(t *testing.T) {
 func TestHighlights(t *testing.T) {
 	test("highlights", t)
 }
+
+func TestPaintStrokeColors(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+
+	var highlights []Highlight
+	for _, c := range []rm.BrushColor{rm.Black, rm.Blue, rm.Red, rm.BrushColor(99)} {
+		stroke := rm.Stroke{BrushType: rm.FinelinerV5, BrushColor: c, Segments: []rm.Segment{{X: 1, Y: 1, Width: 2}, {X: 10, Y: 10, Width: 2}}}
+		if err := PaintStroke(stroke, pdf, &highlights); err != nil {
+			t.Error(err)
+		}
+	}
+
+	stroke := rm.Stroke{BrushType: rm.HighlighterV5, BrushColor: rm.HighlightGreen, Segments: []rm.Segment{{X: 1, Y: 1, Width: 20}}}
+	if err := PaintStroke(stroke, pdf, &highlights); err != nil {
+		t.Fatal(err)
+	}
+	if len(highlights) != 1 || highlights[0].Color[0] == 1 {
+		t.Errorf("highlighter color not kept: %+v", highlights)
+	}
+}
+
+func TestPaintStrokePalette(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	pdf.AddPage()
+
+	palette := rm.Palette{rm.Blue: {B: 255, A: 255}, rm.HighlightGreen: {R: 255, A: 255}}
+	var highlights []Highlight
+	stroke := rm.Stroke{BrushType: rm.FinelinerV5, BrushColor: rm.Blue, Segments: []rm.Segment{{X: 1, Y: 1, Width: 2}, {X: 10, Y: 10, Width: 2}}}
+	if err := paintStroke(stroke, pdf, &highlights, palette); err != nil {
+		t.Fatal(err)
+	}
+	stroke = rm.Stroke{BrushType: rm.HighlighterV5, BrushColor: rm.HighlightGreen, Segments: []rm.Segment{{X: 1, Y: 1, Width: 20}}}
+	if err := paintStroke(stroke, pdf, &highlights, palette); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(highlights) != 1 || highlights[0].Color[0] != 1 || highlights[0].Color[1] != 0 || highlights[0].Color[2] != 0 {
+		t.Errorf("highlight not in the color of the palette: %+v", highlights)
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("0.000 0.000 1.000 RG")) {
+		t.Error("stroke not in the color of the palette")
+	}
+}
@@ -0,0 +1,214 @@
+package annotations
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/juruen/rmapi/log"
+	"github.com/juruen/rmapi/pdfbox"
+	"github.com/juruen/rmapi/util"
+	"github.com/unidoc/unipdf/v3/common/license"
+	"github.com/unidoc/unipdf/v3/extractor"
+	pdfmodel "github.com/unidoc/unipdf/v3/model"
+)
+
+var (
+	setLicense sync.Once
+	licensed   bool
+)
+
+// pdfText finds the text of a pdf under the highlights of its pages.
+//
+// The text extraction of unipdf requires a commercial license, set with
+// the UNIDOC_LICENSE_API_KEY environment variable.
+type pdfText struct {
+	reader *pdfmodel.PdfReader
+	boxes  map[int]pdfbox.Box
+	pages  map[int]*pageText
+	failed bool
+}
+
+// pageText is the text of a pdf page with its position.
+type pageText struct {
+	marks []extractor.TextMark
+	box   pdfbox.Box
+}
+
+// textLicensed tells whether a unidoc license is set: unipdf can't
+// extract text without one, and prints a notice on each attempt.
+func textLicensed() bool {
+	setLicense.Do(func() {
+		key := os.Getenv("UNIDOC_LICENSE_API_KEY")
+		if key == "" {
+			return
+		}
+		if err := license.SetMeteredKey(key); err != nil {
+			log.Warning.Printf("invalid unidoc license: %v", err)
+			return
+		}
+		licensed = true
+	})
+	return licensed
+}
+
+// newPdfText returns the text of the pdf read by reader, whose pages have
+// the given boxes, or nil without a unidoc license.
+func newPdfText(reader *pdfmodel.PdfReader, boxes map[int]pdfbox.Box) *pdfText {
+	if !textLicensed() {
+		return nil
+	}
+	return &pdfText{reader: reader, boxes: boxes, pages: make(map[int]*pageText)}
+}
+
+// page returns the text of a page, numbered from 1, or nil if it can't
+// be extracted. Only the first failure is logged.
+func (t *pdfText) page(num int) *pageText {
+	if p, ok := t.pages[num]; ok {
+		return p
+	}
+
+	p, err := t.extract(num)
+	if err != nil && !t.failed {
+		log.Warning.Printf("can't extract the text under the highlights: %v", err)
+		t.failed = true
+	}
+	t.pages[num] = p
+	return p
+}
+
+func (t *pdfText) extract(num int) (*pageText, error) {
+	box, ok := t.boxes[num]
+	if !ok {
+		return nil, fmt.Errorf("unknown box of page %d", num)
+	}
+
+	var p *pageText
+	err := util.Safely(func() error {
+		page, err := t.reader.GetPage(num)
+		if err != nil {
+			return err
+		}
+		ex, err := extractor.New(page)
+		if err != nil {
+			return err
+		}
+		text, _, _, err := ex.ExtractPageText()
+		if err != nil {
+			return err
+		}
+
+		p = &pageText{marks: text.Marks().Elements(), box: box}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// pdfRect converts a rectangle of the device, in pixels from the top left
+// corner, to the coordinates of the pdf page. Like in Generate, the page
+// as shown is scaled to fill the width or the height of the device.
+func (p *pageText) pdfRect(r Rect) pdfmodel.PdfRectangle {
+	w, h := p.box.Size()
+	scale := h / rmPageSize.Ht
+	if w/h > rmPageSize.Wd/rmPageSize.Ht {
+		scale = w / rmPageSize.Wd
+	}
+	scale *= PtPerPx
+
+	return p.box.ToPdfRect(float64(r.LL.X)*scale, float64(r.LL.Y)*scale, float64(r.UR.X)*scale, float64(r.UR.Y)*scale)
+}
+
+// under returns the text whose center is in one of the rectangles,
+// given in the coordinates of the device.
+func (p *pageText) under(rects []Rect) string {
+	boxes := make([]pdfmodel.PdfRectangle, len(rects))
+	for i, r := range rects {
+		boxes[i] = p.pdfRect(r)
+	}
+	return textIn(p.marks, boxes)
+}
+
+// textIn joins the text of the marks whose center is in one of the boxes.
+// Parts of the text that aren't contiguous are separated by a space.
+func textIn(marks []extractor.TextMark, boxes []pdfmodel.PdfRectangle) string {
+	var b strings.Builder
+	separate := false
+	for _, m := range marks {
+		if m.Meta || strings.TrimSpace(m.Text) == "" || !inBoxes(m.BBox, boxes) {
+			separate = b.Len() > 0
+			continue
+		}
+		if separate {
+			b.WriteString(" ")
+			separate = false
+		}
+		b.WriteString(m.Text)
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func inBoxes(r pdfmodel.PdfRectangle, boxes []pdfmodel.PdfRectangle) bool {
+	x, y := (r.Llx+r.Urx)/2, (r.Lly+r.Ury)/2
+	for _, b := range boxes {
+		if b.Llx <= x && x <= b.Urx && b.Lly <= y && y <= b.Ury {
+			return true
+		}
+	}
+	return false
+}
+
+// quadRects returns the rectangles of the quad points of a highlight.
+func quadRects(h Highlight) []Rect {
+	var rects []Rect
+	for i := 0; i+7 < len(h.QuadPoints); i += 8 {
+		q := h.QuadPoints[i : i+8]
+		r := Rect{LL: Point{X: q[0], Y: q[1]}, UR: Point{X: q[0], Y: q[1]}}
+		for j := 2; j < 8; j += 2 {
+			r = r.Union(Rect{LL: Point{X: q[j], Y: q[j+1]}, UR: Point{X: q[j], Y: q[j+1]}})
+		}
+		rects = append(rects, r)
+	}
+	return rects
+}
+
+// A highlightGroup is a group of highlighter strokes of the same color.
+type highlightGroup struct {
+	Highlight
+	color rm.BrushColor
+}
+
+// strokeHighlights returns the highlighter strokes of a page, in the
+// coordinates of the device, the overlapping ones of the same color being
+// grouped. They are sorted from the top of the page. palette may be nil.
+func strokeHighlights(page *rm.Rm, palette rm.Palette) []highlightGroup {
+	byColor := make(map[rm.BrushColor][]Highlight)
+	var colors []rm.BrushColor
+	for _, layer := range rm.ResolveErasers(page).Layers {
+		for _, stroke := range layer.Strokes {
+			if len(stroke.Segments) == 0 || !isHighlighter(stroke) {
+				continue
+			}
+			if _, ok := byColor[stroke.BrushColor]; !ok {
+				colors = append(colors, stroke.BrushColor)
+			}
+			byColor[stroke.BrushColor] = append(byColor[stroke.BrushColor], strokeHighlight(stroke, palette))
+		}
+	}
+
+	var list []highlightGroup
+	for _, c := range colors {
+		for _, h := range groupHighlights(byColor[c]) {
+			list = append(list, highlightGroup{Highlight: h, color: c})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Rect[1] < list[j].Rect[1]
+	})
+	return list
+}
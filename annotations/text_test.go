@@ -0,0 +1,109 @@
+package annotations
+
+import (
+	"math"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/juruen/rmapi/pdfbox"
+	"github.com/unidoc/unipdf/v3/extractor"
+	pdfmodel "github.com/unidoc/unipdf/v3/model"
+)
+
+func mark(text string, llx, lly, urx, ury float64) extractor.TextMark {
+	return extractor.TextMark{Text: text, BBox: pdfmodel.PdfRectangle{Llx: llx, Lly: lly, Urx: urx, Ury: ury}}
+}
+
+func TestTextIn(t *testing.T) {
+	marks := []extractor.TextMark{
+		mark("H", 10, 90, 16, 100), mark("i", 16, 90, 19, 100),
+		{Text: " ", Meta: true},
+		mark("y", 22, 90, 28, 100), mark("o", 28, 90, 34, 100), mark("u", 34, 90, 40, 100),
+		{Text: "\n", Meta: true},
+		mark("n", 10, 70, 16, 80), mark("o", 16, 70, 22, 80),
+		{Text: " ", Meta: true},
+		mark("w", 25, 70, 31, 80),
+	}
+
+	tests := []struct {
+		boxes    []pdfmodel.PdfRectangle
+		expected string
+	}{
+		{[]pdfmodel.PdfRectangle{{Llx: 0, Lly: 85, Urx: 50, Ury: 105}}, "Hi you"},
+		{[]pdfmodel.PdfRectangle{{Llx: 20, Lly: 85, Urx: 50, Ury: 105}}, "you"},
+		// two lines, the end of the first and the start of the second
+		{[]pdfmodel.PdfRectangle{{Llx: 20, Lly: 85, Urx: 50, Ury: 105}, {Llx: 0, Lly: 65, Urx: 23, Ury: 85}}, "you no"},
+		{[]pdfmodel.PdfRectangle{{Llx: 0, Lly: 0, Urx: 50, Ury: 10}}, ""},
+	}
+
+	for _, tt := range tests {
+		if text := textIn(marks, tt.boxes); text != tt.expected {
+			t.Errorf("textIn(%v) = %q, expected %q", tt.boxes, text, tt.expected)
+		}
+	}
+}
+
+func TestPdfRect(t *testing.T) {
+	// an A4 page with an offset media box, narrower than the device:
+	// it fills its height
+	p := &pageText{box: pdfbox.Box{Rect: pdfmodel.PdfRectangle{Llx: 10, Lly: 20, Urx: 10 + 595, Ury: 20 + 842}}}
+	scale := 842 / rmPageSize.Ht * PtPerPx
+
+	r := p.pdfRect(Rect{LL: Point{X: 100, Y: 200}, UR: Point{X: 300, Y: 250}})
+	expected := pdfmodel.PdfRectangle{
+		Llx: 10 + 100*scale,
+		Urx: 10 + 300*scale,
+		Lly: 862 - 250*scale,
+		Ury: 862 - 200*scale,
+	}
+	for _, v := range [][2]float64{{r.Llx, expected.Llx}, {r.Urx, expected.Urx}, {r.Lly, expected.Lly}, {r.Ury, expected.Ury}} {
+		if math.Abs(v[0]-v[1]) > 1e-3 {
+			t.Fatalf("pdfRect = %+v, expected %+v", r, expected)
+		}
+	}
+}
+
+func TestStrokeHighlights(t *testing.T) {
+	stroke := func(c rm.BrushColor, x0, x1, y float32) rm.Stroke {
+		return rm.Stroke{BrushType: rm.HighlighterV5, BrushColor: c, Segments: []rm.Segment{
+			{X: x0, Y: y, Width: 30}, {X: x1, Y: y, Width: 30},
+		}}
+	}
+	page := &rm.Rm{Layers: []rm.Layer{{Strokes: []rm.Stroke{
+		stroke(rm.Yellow, 100, 500, 300),
+		stroke(rm.Green, 100, 500, 100),
+		// overlaps the first one
+		stroke(rm.Yellow, 400, 800, 310),
+		{BrushType: rm.FinelinerV5, Segments: []rm.Segment{{X: 1, Y: 1}, {X: 2, Y: 2}}},
+	}}}}
+
+	list := strokeHighlights(page, nil)
+	if len(list) != 2 {
+		t.Fatalf("%d highlights, expected 2", len(list))
+	}
+	if list[0].color != rm.Green || list[1].color != rm.Yellow {
+		t.Errorf("unexpected colors %v and %v", list[0].color, list[1].color)
+	}
+	if rects := quadRects(list[1].Highlight); len(rects) != 2 || rects[1].UR.X != 815 {
+		t.Errorf("unexpected rects %+v", rects)
+	}
+
+	// the strokes are shown with the colors of the palette, as in the pdf
+	palette := rm.Palette{rm.Green: {R: 255, A: 255}}
+	if list := strokeHighlights(page, palette); !reflect.DeepEqual(list[0].Color, []float32{1, 0, 0}) {
+		t.Errorf("the green highlight is %v, want the color of the palette", list[0].Color)
+	}
+}
+
+func TestPdfTextUnlicensed(t *testing.T) {
+	if os.Getenv("UNIDOC_LICENSE_API_KEY") != "" {
+		t.Skip("a unidoc license is set")
+	}
+
+	reader, boxes := readFixture(t, fixturePdf("/MediaBox [0 0 595 842]", ""))
+	if text := newPdfText(reader, boxes); text != nil {
+		t.Errorf("no text should be extracted without a license, got %v", text)
+	}
+}
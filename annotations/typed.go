@@ -0,0 +1,78 @@
+package annotations
+
+import (
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/juruen/rmapi/pdffont"
+	"github.com/phpdave/gofpdf"
+)
+
+// typedStyle is the layout of a paragraph of typed text,
+// sizes being in pixels of the device.
+type typedStyle struct {
+	fontStyle  string
+	fontSize   float64
+	lineHeight float64
+	// indent is the space left for the bullet or the checkbox
+	indent float64
+	bullet string
+	box    bool
+}
+
+var typedStyles = map[rm.ParagraphStyle]typedStyle{
+	rm.BasicStyle:           {fontSize: 32, lineHeight: 56},
+	rm.PlainStyle:           {fontSize: 32, lineHeight: 56},
+	rm.HeadingStyle:         {fontStyle: "B", fontSize: 52, lineHeight: 84},
+	rm.BoldStyle:            {fontStyle: "B", fontSize: 32, lineHeight: 56},
+	rm.BulletStyle:          {fontSize: 32, lineHeight: 56, indent: 48, bullet: "•"},
+	rm.Bullet2Style:         {fontSize: 32, lineHeight: 56, indent: 96, bullet: "–"},
+	rm.CheckboxStyle:        {fontSize: 32, lineHeight: 56, indent: 48, box: true},
+	rm.CheckboxCheckedStyle: {fontSize: 32, lineHeight: 56, indent: 48, box: true},
+}
+
+// drawTypedText lays out the typed text of a page at its position, in
+// points, the page being scaled by the caller like the strokes.
+func drawTypedText(pdf *gofpdf.Fpdf, block *rm.RootTextBlock) {
+	// the text may be in any script, which the core fonts can't show
+	pdffont.Add(pdf, pdffont.Text)
+	pdffont.WarnMissingGlyphs(block.String())
+
+	// the text is positioned from the top center of the page
+	left := (block.PosX + float64(rm.Width)/2) * PtPerPx
+	width := float64(block.Width) * PtPerPx
+	y := block.PosY * PtPerPx
+
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetDrawColor(0, 0, 0)
+	for _, p := range block.Paragraphs() {
+		style, ok := typedStyles[p.Style]
+		if !ok {
+			style = typedStyles[rm.PlainStyle]
+		}
+		lineHeight := style.lineHeight * PtPerPx
+		indent := style.indent * PtPerPx
+
+		pdf.SetFont(pdffont.Text, style.fontStyle, style.fontSize*PtPerPx)
+		if p.Text == "" {
+			y += lineHeight
+			continue
+		}
+
+		switch {
+		case style.bullet != "":
+			pdf.Text(left+indent/2, y+lineHeight*0.7, style.bullet)
+		case style.box:
+			size := style.fontSize * PtPerPx * 0.7
+			bx, by := left+indent-size*1.6, y+(lineHeight-size)/2
+			pdf.SetLineWidth(size / 10)
+			pdf.Rect(bx, by, size, size, "D")
+			if p.Style == rm.CheckboxCheckedStyle {
+				pdf.Line(bx+size*0.2, by+size*0.5, bx+size*0.45, by+size*0.8)
+				pdf.Line(bx+size*0.45, by+size*0.8, bx+size*0.85, by+size*0.2)
+			}
+		}
+
+		pdf.SetXY(left+indent, y)
+		pdf.MultiCell(width-indent, lineHeight, p.Text, "", "L", false)
+		y = pdf.GetY()
+	}
+}
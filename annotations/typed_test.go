@@ -0,0 +1,118 @@
+package annotations
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/juruen/rmapi/log"
+	"github.com/phpdave/gofpdf"
+)
+
+func typedPage() *rm.Rm {
+	return &rm.Rm{Version: rm.V6, Scene: &rm.Scene{Blocks: []rm.Block{
+		&rm.RootTextBlock{
+			Items: []rm.TextItem{
+				{ItemID: rm.CrdtID{Part1: 1, Part2: 10}, LeftID: rm.EndID, RightID: rm.EndID, HasValue: true,
+					Text: "Meeting\nfirst point\n\nto do"},
+			},
+			Formats: []rm.TextFormat{
+				{CharID: rm.EndID, Style: rm.HeadingStyle},
+				{CharID: rm.CrdtID{Part1: 1, Part2: 17}, Style: rm.BulletStyle},
+				{CharID: rm.CrdtID{Part1: 1, Part2: 30}, Style: rm.CheckboxCheckedStyle},
+			},
+			PosX:  -468,
+			PosY:  234,
+			Width: 936,
+		},
+	}}}
+}
+
+// drawnText returns the pdf of a page with typed text, uncompressed.
+func drawnText(block *rm.RootTextBlock) *gofpdf.Fpdf {
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{UnitStr: "pt", Size: rmPageSize})
+	pdf.SetCompression(false)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.AddPage()
+	drawTypedText(pdf, block)
+	return pdf
+}
+
+// checkDrawnText checks that the lines of text are written in the pdf,
+// in UTF-16 like the text of the embedded fonts.
+func checkDrawnText(t *testing.T, pdf *gofpdf.Fpdf, lines ...string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range lines {
+		var u bytes.Buffer
+		for _, c := range utf16.Encode([]rune(s)) {
+			u.Write([]byte{byte(c >> 8), byte(c)})
+		}
+		if !bytes.Contains(buf.Bytes(), u.Bytes()) {
+			t.Errorf("%s missing from the pdf", s)
+		}
+	}
+	if pdf.PageCount() != 1 {
+		t.Errorf("%d pages, the text should stay on its page", pdf.PageCount())
+	}
+}
+
+func TestDrawTypedText(t *testing.T) {
+	checkDrawnText(t, drawnText(typedPage().Scene.Text()), "Meeting", "first point", "to do")
+}
+
+func TestDrawTypedTextScripts(t *testing.T) {
+	log.InitLog()
+
+	text := "Встреча • Ελληνικά – “café”"
+	block := &rm.RootTextBlock{
+		Items: []rm.TextItem{
+			{ItemID: rm.CrdtID{Part1: 1, Part2: 10}, LeftID: rm.EndID, RightID: rm.EndID, HasValue: true, Text: text},
+		},
+		PosX:  -468,
+		PosY:  234,
+		Width: 936,
+	}
+	checkDrawnText(t, drawnText(block), text)
+}
+
+func TestGenerateTypedText(t *testing.T) {
+	log.InitLog()
+
+	dir, err := ioutil.TempDir("", "typed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	z := archive.NewNotebook()
+	z.AddPage()
+	z.SetData(0, typedPage())
+
+	zipName := filepath.Join(dir, "typed.zip")
+	out, err := os.Create(zipName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := z.Write(out); err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+
+	pdfName := filepath.Join(dir, "typed.pdf")
+	if err := CreatePdfGenerator(zipName, pdfName, PdfGeneratorOptions{}).Generate(); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(pdfName); err != nil || fi.Size() == 0 {
+		t.Errorf("no pdf generated: %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package archive
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/juruen/rmapi/encoding/rm"
+)
+
+// NewNotebook creates a Zip for a notebook without pages,
+// pages being added with AddPage.
+func NewNotebook() *Zip {
+	z := NewZip()
+	z.Content.FileType = "notebook"
+	return z
+}
+
+// AddPage appends a blank page to the archive and returns its index.
+// A new ID is generated for the page and the page list of the
+// content is kept up to date.
+func (z *Zip) AddPage() int {
+	z.syncPages(true)
+
+	id := uuid.New().String()
+	z.Pages = append(z.Pages, Page{UUID: id, Pagedata: defaultPagadata})
+	z.Content.Pages = append(z.Content.Pages, id)
+	z.Content.PageCount = len(z.Pages)
+
+	return len(z.Pages) - 1
+}
+
+// SetTemplate sets the name of the background template of a page,
+// such as "Blank" or "P Lines medium".
+func (z *Zip) SetTemplate(idx int, template string) error {
+	if err := z.checkPage(idx); err != nil {
+		return err
+	}
+
+	z.Pages[idx].Pagedata = template
+	return nil
+}
+
+// SetData attaches the drawing of a page. The layers of the page
+// metadata are named after the layers of the drawing.
+func (z *Zip) SetData(idx int, data *rm.Rm) error {
+	if err := z.checkPage(idx); err != nil {
+		return err
+	}
+
+	page := &z.Pages[idx]
+	page.Data = data
+	page.Metadata.Layers = nil
+	if data == nil {
+		return nil
+	}
+
+	for i, layer := range data.Layers {
+		name := layer.Name
+		if name == "" {
+			name = fmt.Sprintf("Layer %d", i+1)
+		}
+		page.Metadata.Layers = append(page.Metadata.Layers, Layer{Name: name})
+	}
+	return nil
+}
+
+// SetLayerNames renames the layers of a page, in the metadata and in the
+// drawing. Missing layers are added to the drawing.
+func (z *Zip) SetLayerNames(idx int, names ...string) error {
+	if err := z.checkPage(idx); err != nil {
+		return err
+	}
+
+	page := &z.Pages[idx]
+	if page.Data == nil {
+		page.Data = &rm.Rm{Version: rm.V5}
+	}
+	for len(page.Data.Layers) < len(names) {
+		page.Data.Layers = append(page.Data.Layers, rm.Layer{})
+	}
+	// a v6 scene has to be rebuilt from the renamed layers
+	page.Data.Scene = nil
+
+	page.Metadata.Layers = make([]Layer, len(names))
+	for i, name := range names {
+		page.Metadata.Layers[i] = Layer{Name: name}
+		page.Data.Layers[i].Name = name
+	}
+	return nil
+}
+
+func (z *Zip) checkPage(idx int) error {
+	if idx < 0 || idx >= len(z.Pages) {
+		return fmt.Errorf("page %d not found", idx)
+	}
+	return nil
+}
+
+// syncPages makes sure every page has an ID, that the page list of
+// the content matches the pages and that the page count of a notebook
+// is the number of its pages. Archives that were read without a page
+// list are left untouched unless force is set.
+func (z *Zip) syncPages(force bool) {
+	if len(z.Pages) == 0 {
+		return
+	}
+	if !force && z.source != nil && len(z.Content.Pages) == 0 {
+		return
+	}
+
+	ids := make([]string, len(z.Pages))
+	for idx := range z.Pages {
+		page := &z.Pages[idx]
+		if page.UUID == "" {
+			if idx < len(z.Content.Pages) && z.Content.Pages[idx] != "" {
+				page.UUID = z.Content.Pages[idx]
+			} else {
+				page.UUID = uuid.New().String()
+			}
+		}
+		ids[idx] = page.UUID
+	}
+
+	z.Content.Pages = ids
+	z.Content.PageCount = len(z.Pages)
+}
@@ -0,0 +1,68 @@
+package archive
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/juruen/rmapi/encoding/rm"
+)
+
+func TestBuildNotebook(t *testing.T) {
+	zip := NewNotebook()
+
+	first := zip.AddPage()
+	second := zip.AddPage()
+
+	page := &rm.Rm{Version: rm.V5, Layers: []rm.Layer{{Strokes: []rm.Stroke{
+		{BrushType: rm.FinelinerV5, Segments: []rm.Segment{{X: 10, Y: 10, Width: 2}, {X: 20, Y: 20, Width: 2}}},
+	}}}}
+	if err := zip.SetData(first, page); err != nil {
+		t.Fatal(err)
+	}
+	if err := zip.SetTemplate(second, "P Grid medium"); err != nil {
+		t.Fatal(err)
+	}
+	if err := zip.SetLayerNames(second, "Sketch", "Notes"); err != nil {
+		t.Fatal(err)
+	}
+	if err := zip.SetTemplate(2, "Blank"); err == nil {
+		t.Error("expected an error for a missing page")
+	}
+
+	if zip.Content.PageCount != 2 || len(zip.Content.Pages) != 2 || zip.Content.Pages[0] == zip.Content.Pages[1] {
+		t.Errorf("inconsistent content %d %v", zip.Content.PageCount, zip.Content.Pages)
+	}
+
+	var buf bytes.Buffer
+	if err := zip.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	read := NewZip()
+	if err := read.Read(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil {
+		t.Fatal(err)
+	}
+
+	if read.Content.FileType != "notebook" || len(read.Pages) != 2 {
+		t.Fatalf("got %q with %d pages", read.Content.FileType, len(read.Pages))
+	}
+	if read.Pages[0].UUID != zip.Content.Pages[0] || read.Pages[1].UUID != zip.Content.Pages[1] {
+		t.Error("page ids not kept")
+	}
+	if read.Pages[0].Pagedata != "Blank" || read.Pages[1].Pagedata != "P Grid medium" {
+		t.Errorf("wrong templates %q %q", read.Pages[0].Pagedata, read.Pages[1].Pagedata)
+	}
+	if read.Pages[0].Data == nil || len(read.Pages[0].Data.Layers[0].Strokes) != 1 {
+		t.Error("drawing not written")
+	}
+	if len(read.Pages[0].Metadata.Layers) != 1 || read.Pages[0].Metadata.Layers[0].Name != "Layer 1" {
+		t.Errorf("wrong layers %+v", read.Pages[0].Metadata.Layers)
+	}
+	layers := read.Pages[1].Metadata.Layers
+	if len(layers) != 2 || layers[0].Name != "Sketch" || layers[1].Name != "Notes" {
+		t.Errorf("wrong layer names %+v", layers)
+	}
+	if len(read.Pages[1].Data.Layers) != 2 {
+		t.Error("layers not added to the drawing")
+	}
+}
@@ -0,0 +1,221 @@
+package archive
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// PageIDs returns the IDs of the pages in order. They are taken from
+// Pages, or from the pages of CPages that aren't deleted for archives
+// which only have CPages.
+func (c *Content) PageIDs() []string {
+	if len(c.Pages) > 0 || c.CPages == nil {
+		return c.Pages
+	}
+
+	var pages []CPage
+	for _, p := range c.CPages.Pages {
+		if p.Deleted != nil && p.Deleted.Value != 0 {
+			continue
+		}
+		pages = append(pages, p)
+	}
+
+	idx := func(p CPage) string {
+		if p.Idx == nil {
+			return ""
+		}
+		return p.Idx.Value
+	}
+	sort.SliceStable(pages, func(i, j int) bool { return idx(pages[i]) < idx(pages[j]) })
+
+	ids := make([]string, len(pages))
+	for i, p := range pages {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+// The structs of the .content file keep the fields they don't model
+// so that reading and writing an archive doesn't lose tablet state.
+// Each of them decodes itself through a type without methods,
+// the unknown fields being handled by the helpers below.
+
+func (c *Content) UnmarshalJSON(data []byte) error {
+	type content Content
+	return unmarshalKeepingUnknown(data, (*content)(c), &c.Unknown)
+}
+
+func (c Content) MarshalJSON() ([]byte, error) {
+	type content Content
+	return marshalWithUnknown(content(c), c.Unknown)
+}
+
+func (m *ExtraMetadata) UnmarshalJSON(data []byte) error {
+	type extraMetadata ExtraMetadata
+	return unmarshalKeepingUnknown(data, (*extraMetadata)(m), &m.Unknown)
+}
+
+func (m ExtraMetadata) MarshalJSON() ([]byte, error) {
+	type extraMetadata ExtraMetadata
+	return marshalWithUnknown(extraMetadata(m), m.Unknown)
+}
+
+func (m *DocumentMetadata) UnmarshalJSON(data []byte) error {
+	type documentMetadata DocumentMetadata
+	return unmarshalKeepingUnknown(data, (*documentMetadata)(m), &m.Unknown)
+}
+
+func (m DocumentMetadata) MarshalJSON() ([]byte, error) {
+	type documentMetadata DocumentMetadata
+	return marshalWithUnknown(documentMetadata(m), m.Unknown)
+}
+
+func (t *Tag) UnmarshalJSON(data []byte) error {
+	type tag Tag
+	return unmarshalKeepingUnknown(data, (*tag)(t), &t.Unknown)
+}
+
+func (t Tag) MarshalJSON() ([]byte, error) {
+	type tag Tag
+	return marshalWithUnknown(tag(t), t.Unknown)
+}
+
+func (t *PageTag) UnmarshalJSON(data []byte) error {
+	type pageTag PageTag
+	return unmarshalKeepingUnknown(data, (*pageTag)(t), &t.Unknown)
+}
+
+func (t PageTag) MarshalJSON() ([]byte, error) {
+	type pageTag PageTag
+	return marshalWithUnknown(pageTag(t), t.Unknown)
+}
+
+func (c *CPages) UnmarshalJSON(data []byte) error {
+	type cPages CPages
+	return unmarshalKeepingUnknown(data, (*cPages)(c), &c.Unknown)
+}
+
+func (c CPages) MarshalJSON() ([]byte, error) {
+	type cPages CPages
+	return marshalWithUnknown(cPages(c), c.Unknown)
+}
+
+func (p *CPage) UnmarshalJSON(data []byte) error {
+	type cPage CPage
+	return unmarshalKeepingUnknown(data, (*cPage)(p), &p.Unknown)
+}
+
+func (p CPage) MarshalJSON() ([]byte, error) {
+	type cPage CPage
+	return marshalWithUnknown(cPage(p), p.Unknown)
+}
+
+func (u *CPageUUID) UnmarshalJSON(data []byte) error {
+	type cPageUUID CPageUUID
+	return unmarshalKeepingUnknown(data, (*cPageUUID)(u), &u.Unknown)
+}
+
+func (u CPageUUID) MarshalJSON() ([]byte, error) {
+	type cPageUUID CPageUUID
+	return marshalWithUnknown(cPageUUID(u), u.Unknown)
+}
+
+func (v *TimestampedStr) UnmarshalJSON(data []byte) error {
+	type timestampedStr TimestampedStr
+	return unmarshalKeepingUnknown(data, (*timestampedStr)(v), &v.Unknown)
+}
+
+func (v TimestampedStr) MarshalJSON() ([]byte, error) {
+	type timestampedStr TimestampedStr
+	return marshalWithUnknown(timestampedStr(v), v.Unknown)
+}
+
+func (v *TimestampedInt) UnmarshalJSON(data []byte) error {
+	type timestampedInt TimestampedInt
+	return unmarshalKeepingUnknown(data, (*timestampedInt)(v), &v.Unknown)
+}
+
+func (v TimestampedInt) MarshalJSON() ([]byte, error) {
+	type timestampedInt TimestampedInt
+	return marshalWithUnknown(timestampedInt(v), v.Unknown)
+}
+
+func (t *Transform) UnmarshalJSON(data []byte) error {
+	type transform Transform
+	return unmarshalKeepingUnknown(data, (*transform)(t), &t.Unknown)
+}
+
+func (t Transform) MarshalJSON() ([]byte, error) {
+	type transform Transform
+	return marshalWithUnknown(transform(t), t.Unknown)
+}
+
+// unmarshalKeepingUnknown decodes data into the struct pointed by v,
+// and the fields v doesn't have into unknown.
+func unmarshalKeepingUnknown(data []byte, v interface{}, unknown *map[string]json.RawMessage) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	known := jsonFields(reflect.TypeOf(v).Elem())
+	for name := range fields {
+		// the json package matches the names regardless of their case
+		if known[strings.ToLower(name)] {
+			delete(fields, name)
+		}
+	}
+
+	*unknown = nil
+	if len(fields) > 0 {
+		*unknown = fields
+	}
+	return nil
+}
+
+// marshalWithUnknown encodes the struct v along with the unknown fields.
+func marshalWithUnknown(v interface{}, unknown map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(unknown) == 0 {
+		return data, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	for name, value := range unknown {
+		if _, ok := fields[name]; !ok {
+			fields[name] = value
+		}
+	}
+
+	return json.Marshal(fields)
+}
+
+// jsonFields returns the lower case names of the json fields of a struct type.
+func jsonFields(t reflect.Type) map[string]bool {
+	fields := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+			if n := strings.Split(tag, ",")[0]; n != "" {
+				name = n
+			}
+		}
+		fields[strings.ToLower(name)] = true
+	}
+	return fields
+}
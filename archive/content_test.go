@@ -0,0 +1,170 @@
+package archive
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const newerContent = `{
+    "coverPageNumber": 0,
+    "customZoomCenterX": 0,
+    "customZoomCenterY": 936,
+    "customZoomOrientation": "portrait",
+    "customZoomPageHeight": 1872,
+    "customZoomPageWidth": 1404,
+    "customZoomScale": 1,
+    "documentMetadata": {
+        "authors": ["Ada Lovelace"],
+        "title": "Notes",
+        "publisher": "Self"
+    },
+    "dummyDocument": false,
+    "extraMetadata": {
+        "LastBrushColor": "Black",
+        "LastBrushThicknessScale": "2",
+        "LastColor": "Black",
+        "LastEraserThicknessScale": "2",
+        "LastEraserTool": "Eraser",
+        "LastPen": "Ballpoint",
+        "LastPenColor": "Black",
+        "LastPenThicknessScale": "2",
+        "LastPencil": "SharpPencil",
+        "LastPencilColor": "Black",
+        "LastPencilThicknessScale": "2",
+        "LastTool": "SharpPencil",
+        "ThicknessScale": "2",
+        "LastFinelinerv2Size": "1",
+        "LastHighlighterv2Color": "HighlighterYellow"
+    },
+    "fileType": "pdf",
+    "fontName": "",
+    "formatVersion": 2,
+    "keyboardMetadata": {"count": 1, "timestamp": 1670000000},
+    "lastOpenedPage": 1,
+    "lineHeight": -1,
+    "margins": 125,
+    "orientation": "portrait",
+    "originalPageCount": 2,
+    "pageCount": 3,
+    "pageTags": [{"name": "todo", "pageId": "b", "timestamp": 1670000000000}],
+    "pages": ["a", "b", "c"],
+    "cPages": {
+        "lastOpened": {"timestamp": "1:1", "value": "b"},
+        "original": {"timestamp": "0:0", "value": 2},
+        "pages": [
+            {"id": "a", "idx": {"timestamp": "1:2", "value": "ba"}, "redir": {"timestamp": "1:2", "value": 0}, "template": {"timestamp": "1:2", "value": "Blank"}},
+            {"id": "b", "idx": {"timestamp": "1:2", "value": "bb"}, "redir": {"timestamp": "1:2", "value": 1}, "scrollTime": {"timestamp": "1:3", "value": "1670000000"}},
+            {"id": "c", "idx": {"timestamp": "1:4", "value": "bc"}, "template": {"timestamp": "1:4", "value": "P Grid medium"}}
+        ],
+        "uuids": [{"first": "9b7e4d0c", "second": 1}]
+    },
+    "redirectionPageMap": [0, 1, -1],
+    "sizeInBytes": "123456",
+    "tags": [{"name": "work", "timestamp": 1670000000000}],
+    "textAlignment": "justify",
+    "textScale": 1,
+    "transform": {"m11": 1, "m12": 0, "m13": 0, "m21": 0, "m22": 1, "m23": 0, "m31": 0, "m32": 0, "m33": 1},
+    "zoomMode": "bestFit"
+}`
+
+func TestContentSchema(t *testing.T) {
+	var c Content
+	if err := json.Unmarshal([]byte(newerContent), &c); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.CoverPageNumber == nil || *c.CoverPageNumber != 0 {
+		t.Error("coverPageNumber not read")
+	}
+	if c.DocumentMetadata == nil || c.DocumentMetadata.Title != "Notes" || c.DocumentMetadata.Authors[0] != "Ada Lovelace" {
+		t.Errorf("wrong document metadata %+v", c.DocumentMetadata)
+	}
+	if len(c.Tags) != 1 || c.Tags[0].Name != "work" || len(c.PageTags) != 1 || c.PageTags[0].PageID != "b" {
+		t.Errorf("wrong tags %+v %+v", c.Tags, c.PageTags)
+	}
+	if c.CPages == nil || len(c.CPages.Pages) != 3 || c.CPages.Pages[2].Template.Value != "P Grid medium" ||
+		c.CPages.Pages[1].Redirect.Value != 1 || c.CPages.Original.Value != 2 {
+		t.Errorf("wrong cPages %+v", c.CPages)
+	}
+	if c.ZoomMode != "bestFit" || c.CustomZoomCenterY == nil || *c.CustomZoomCenterY != 936 || c.CustomZoomScale == nil {
+		t.Error("zoom not read")
+	}
+	if c.SizeInBytes != "123456" || c.FormatVersion != 2 {
+		t.Errorf("wrong size or version %q %d", c.SizeInBytes, c.FormatVersion)
+	}
+	if _, ok := c.Unknown["keyboardMetadata"]; !ok || len(c.Unknown) != 1 {
+		t.Errorf("unknown fields not kept %v", c.Unknown)
+	}
+
+	out, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want, got interface{}
+	json.Unmarshal([]byte(newerContent), &want)
+	json.Unmarshal(out, &got)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("content changed by a round trip:\n%s", out)
+	}
+}
+
+func TestContentOptionalFields(t *testing.T) {
+	out, err := json.Marshal(NewZip().Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]interface{}
+	json.Unmarshal(out, &fields)
+	for _, name := range []string{"cPages", "coverPageNumber", "customZoomScale", "tags", "formatVersion"} {
+		if _, ok := fields[name]; ok {
+			t.Errorf("%s should be left out when not set", name)
+		}
+	}
+}
+
+func TestContentFractionalAndNestedUnknown(t *testing.T) {
+	const data = `{
+    "lineHeight": 1.5,
+    "margins": 62.5,
+    "textScale": 1.2,
+    "cPages": {
+        "lastOpened": {"timestamp": "1:1", "value": "a", "source": "tablet"},
+        "original": {"timestamp": "0:0", "value": 1, "source": "tablet"},
+        "pages": [],
+        "uuids": []
+    },
+    "transform": {"m11": 1, "m12": 0, "m13": 0, "m21": 0, "m22": 1, "m23": 0, "m31": 0, "m32": 0, "m33": 1, "m41": 0}
+}`
+	var c Content
+	if err := json.Unmarshal([]byte(data), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.LineHeight != 1.5 || c.Margins != 62.5 || c.TextScale != 1.2 {
+		t.Errorf("wrong values %v %v %v", c.LineHeight, c.Margins, c.TextScale)
+	}
+	if _, ok := c.CPages.LastOpened.Unknown["source"]; !ok {
+		t.Error("unknown field of a timestamped string not kept")
+	}
+	if _, ok := c.CPages.Original.Unknown["source"]; !ok {
+		t.Error("unknown field of a timestamped int not kept")
+	}
+	if _, ok := c.Transform.Unknown["m41"]; !ok {
+		t.Error("unknown field of the transform not kept")
+	}
+
+	out, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	json.Unmarshal(out, &got)
+	cPages := got["cPages"].(map[string]interface{})
+	if cPages["lastOpened"].(map[string]interface{})["source"] != "tablet" ||
+		cPages["original"].(map[string]interface{})["source"] != "tablet" ||
+		got["transform"].(map[string]interface{})["m41"] == nil || got["textScale"] != 1.2 {
+		t.Errorf("values lost by a round trip:\n%s", out)
+	}
+}
@@ -9,6 +9,13 @@
 // 384327f5-133e-49c8-82ff-30aa19f3cfa4.thumbnails/0.jpg
 // 384327f5-133e-49c8-82ff-30aa19f3cfa4.highlights//989bdc6c-7e9c-4795-84db-869a77c3beaf.json
 //
+// Newer firmwares name the files of a page after its ID instead of its index,
+// the order of the pages being given by the "pages" list of the .content file:
+// 384327f5-133e-49c8-82ff-30aa19f3cfa4/989bdc6c-7e9c-4795-84db-869a77c3beaf.rm
+// 384327f5-133e-49c8-82ff-30aa19f3cfa4/989bdc6c-7e9c-4795-84db-869a77c3beaf-metadata.json
+// 384327f5-133e-49c8-82ff-30aa19f3cfa4.thumbnails/989bdc6c-7e9c-4795-84db-869a77c3beaf.jpg
+// Both layouts are read, and pages are written with the newer one.
+//
 // As the .zip file from remarkable is simply a normal .zip file
 // containing specific file formats, this package is a helper to
 // read and write zip files with the correct format expected by
@@ -20,6 +27,14 @@
 // from an io.Reader into a Zip struct and a Zip.Write() method
 // to marshal a Zip struct into a io.Writer.
 //
+// A Zip that was read is written back losslessly: the files that
+// aren't decoded by this package are kept verbatim, and the ones
+// that weren't modified keep their name, position and bytes. This
+// allows to edit a single field of an archive.
+//
+// For large documents, Open gives access to the pages, thumbnails
+// and payload of an archive without reading them all in memory.
+//
 // In order to correctly use this package, you will have to understand
 // the format of a Remarkable zip file, and the format of the files
 // that it contains.
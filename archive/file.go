@@ -1,6 +1,8 @@
 package archive
 
 import (
+	"encoding/json"
+
 	"github.com/juruen/rmapi/encoding/rm"
 )
 
@@ -13,6 +15,9 @@ type Zip struct {
 	Pages   []Page
 	Payload []byte
 	UUID    string
+
+	// source is the archive that was read, if any
+	source *source
 }
 
 // NewZip creates a File with sane defaults.
@@ -64,6 +69,9 @@ func NewZip() *Zip {
 
 // A Page represents a note page.
 type Page struct {
+	// UUID is the ID of the page, its files are named after it
+	// in newer archives
+	UUID string
 	// Data is the rm binary encoded file representing the drawn content
 	Data *rm.Rm
 	// Metadata is a json file containing information about layers
@@ -74,6 +82,11 @@ type Page struct {
 	Pagedata string
 	// Highlights is a json file containing information about highlights
 	Highlights HighlightsData
+
+	// ref identifies a page that was read, it is its index plus one
+	ref int
+	// name is the name of the files of a page that was read
+	name string
 }
 
 // Metadata represents the structure of a .metadata json file associated to a page.
@@ -87,6 +100,8 @@ type Layer struct {
 }
 
 // Content represents the structure of a .content json file.
+// Fields that aren't modelled are kept in Unknown and written back,
+// optional fields are left out when they weren't in the original file.
 type Content struct {
 	DummyDocument bool          `json:"dummyDocument"`
 	ExtraMetadata ExtraMetadata `json:"extraMetadata"`
@@ -95,16 +110,115 @@ type Content struct {
 	FileType       string `json:"fileType"`
 	FontName       string `json:"fontName"`
 	LastOpenedPage int    `json:"lastOpenedPage"`
-	LineHeight     int    `json:"lineHeight"`
-	Margins        int    `json:"margins"`
+	// LineHeight, Margins and TextScale can be fractional.
+	LineHeight float64 `json:"lineHeight"`
+	Margins    float64 `json:"margins"`
 	// Orientation can take "portrait" or "landscape".
 	Orientation string `json:"orientation"`
 	PageCount   int    `json:"pageCount"`
 	// Pages is a list of page IDs
 	Pages     []string `json:"pages"`
-	TextScale int      `json:"textScale"`
+	TextScale float64  `json:"textScale"`
 
 	Transform Transform `json:"transform"`
+
+	// CoverPageNumber is the page shown as cover, -1 for the last opened page
+	CoverPageNumber  *int              `json:"coverPageNumber,omitempty"`
+	DocumentMetadata *DocumentMetadata `json:"documentMetadata,omitempty"`
+	// FormatVersion is 2 for archives using CPages instead of Pages
+	FormatVersion      int    `json:"formatVersion,omitempty"`
+	OriginalPageCount  *int   `json:"originalPageCount,omitempty"`
+	RedirectionPageMap []int  `json:"redirectionPageMap,omitempty"`
+	SizeInBytes        string `json:"sizeInBytes,omitempty"`
+	TextAlignment      string `json:"textAlignment,omitempty"`
+
+	Tags     []Tag     `json:"tags,omitempty"`
+	PageTags []PageTag `json:"pageTags,omitempty"`
+	CPages   *CPages   `json:"cPages,omitempty"`
+
+	// ZoomMode can take "bestFit", "fitToWidth", "fitToHeight" or "customFit"
+	ZoomMode              string   `json:"zoomMode,omitempty"`
+	CustomZoomCenterX     *float64 `json:"customZoomCenterX,omitempty"`
+	CustomZoomCenterY     *float64 `json:"customZoomCenterY,omitempty"`
+	CustomZoomOrientation string   `json:"customZoomOrientation,omitempty"`
+	CustomZoomPageHeight  *float64 `json:"customZoomPageHeight,omitempty"`
+	CustomZoomPageWidth   *float64 `json:"customZoomPageWidth,omitempty"`
+	CustomZoomScale       *float64 `json:"customZoomScale,omitempty"`
+
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// DocumentMetadata holds the metadata of a pdf or epub document.
+type DocumentMetadata struct {
+	Title   string   `json:"title,omitempty"`
+	Authors []string `json:"authors,omitempty"`
+
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// Tag is a tag of a document.
+type Tag struct {
+	Name      string `json:"name"`
+	Timestamp int64  `json:"timestamp"`
+
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// PageTag is a tag of a page of a document.
+type PageTag struct {
+	Name      string `json:"name"`
+	PageID    string `json:"pageId"`
+	Timestamp int64  `json:"timestamp"`
+
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// CPages is the page list of newer firmwares. Its values are stamped
+// with the time they were last modified at, to merge concurrent changes.
+type CPages struct {
+	Pages      []CPage         `json:"pages"`
+	LastOpened *TimestampedStr `json:"lastOpened,omitempty"`
+	Original   *TimestampedInt `json:"original,omitempty"`
+	UUIDs      []CPageUUID     `json:"uuids,omitempty"`
+
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// CPage is a page of CPages.
+type CPage struct {
+	ID string `json:"id"`
+	// Idx is a string whose sort order is the order of the pages
+	Idx      *TimestampedStr `json:"idx,omitempty"`
+	Template *TimestampedStr `json:"template,omitempty"`
+	// Redirect is the index of the page in the pdf or epub, -1 for an inserted page
+	Redirect *TimestampedInt `json:"redir,omitempty"`
+	Deleted  *TimestampedInt `json:"deleted,omitempty"`
+
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// CPageUUID identifies the devices which modified the page list.
+type CPageUUID struct {
+	First  string `json:"first"`
+	Second int    `json:"second"`
+
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// TimestampedStr is a string value of CPages.
+type TimestampedStr struct {
+	Timestamp string `json:"timestamp"`
+	Value     string `json:"value"`
+
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// TimestampedInt is an integer value of CPages.
+type TimestampedInt struct {
+	Timestamp string `json:"timestamp"`
+	Value     int    `json:"value"`
+
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // HighlightsData represents the structure of a highlights json file.
@@ -119,6 +233,8 @@ type HighlightsData struct {
 		} `json:"rects"`
 		Start int    `json:"start"`
 		Text  string `json:"text"`
+		// Color is only written by newer firmwares
+		Color rm.BrushColor `json:"color,omitempty"`
 	} `json:"highlights"`
 }
 
@@ -138,6 +254,8 @@ type ExtraMetadata struct {
 	LastTool                 string `json:"LastTool"`
 	ThicknessScale           string `json:"ThicknessScale"`
 	LastFinelinerv2Size      string `json:"LastFinelinerv2Size"`
+
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // Transform is a struct contained into a Content struct.
@@ -151,4 +269,6 @@ type Transform struct {
 	M31 float32 `json:"m31"`
 	M32 float32 `json:"m32"`
 	M33 float32 `json:"m33"`
+
+	Unknown map[string]json.RawMessage `json:"-"`
 }
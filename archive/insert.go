@@ -0,0 +1,297 @@
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// InsertPages inserts count blank pages using the given background template
+// before the page at index idx, or at the end of the document if idx is
+// the number of pages. Pages inserted in a pdf document have no pdf page,
+// the pages of the document being redirected to the ones of the pdf.
+func (z *Zip) InsertPages(idx, count int, template string) error {
+	if idx < 0 || idx > len(z.Pages) {
+		return fmt.Errorf("page %d not found", idx)
+	}
+	if count <= 0 {
+		return fmt.Errorf("invalid number of pages %d", count)
+	}
+
+	switch z.Content.FileType {
+	case "notebook":
+	case "pdf":
+		z.redirectPages(idx, count)
+	default:
+		return fmt.Errorf("pages can't be inserted in a %s document", z.Content.FileType)
+	}
+	if template == "" {
+		template = defaultPagadata
+	}
+
+	z.syncPages(true)
+
+	pages := make([]Page, count)
+	for i := range pages {
+		pages[i] = Page{UUID: uuid.New().String(), Pagedata: template}
+	}
+	z.insertCPages(idx, pages, template)
+
+	// the files of the pages named after their index would be read back
+	// as the ones of other pages, they are renamed after the page ID
+	for i := idx; i < len(z.Pages); i++ {
+		if z.Pages[i].name != z.Pages[i].UUID {
+			z.Pages[i].ref, z.Pages[i].name = 0, ""
+		}
+	}
+	z.Pages = append(z.Pages[:idx], append(pages, z.Pages[idx:]...)...)
+	z.syncPages(true)
+
+	if z.Content.LastOpenedPage >= idx {
+		z.Content.LastOpenedPage += count
+	}
+	return nil
+}
+
+// redirectPages updates the redirection of the pages to the pdf
+// for count pages inserted at idx. The pages of CPages are redirected
+// one by one, the legacy redirections are only kept up to date.
+func (z *Zip) redirectPages(idx, count int) {
+	if c := z.Content.CPages; c != nil {
+		// pages without redirection show the pdf page of their index
+		stamp := c.stamper()
+		for i := range z.Pages {
+			for j := range c.Pages {
+				if c.Pages[j].ID == z.Pages[i].UUID && c.Pages[j].Redirect == nil {
+					c.Pages[j].Redirect = &TimestampedInt{Timestamp: stamp(), Value: z.PayloadPage(i)}
+				}
+			}
+		}
+		if z.Content.RedirectionPageMap == nil {
+			return
+		}
+	}
+
+	redirections := make([]int, 0, len(z.Pages)+count)
+	for i := range z.Pages {
+		if i == idx {
+			for n := 0; n < count; n++ {
+				redirections = append(redirections, -1)
+			}
+		}
+		redirections = append(redirections, z.PayloadPage(i))
+	}
+	for len(redirections) < len(z.Pages)+count {
+		redirections = append(redirections, -1)
+	}
+
+	if z.Content.OriginalPageCount == nil {
+		original := len(z.Pages)
+		z.Content.OriginalPageCount = &original
+	}
+	z.Content.RedirectionPageMap = redirections
+}
+
+// insertCPages adds the inserted pages to the page list of the archives
+// that have one in CPages, before the page at idx.
+func (z *Zip) insertCPages(idx int, pages []Page, template string) {
+	if z.Content.CPages == nil {
+		return
+	}
+
+	// the pages are ordered by their Idx, new pages get Idx values
+	// between the ones of the pages around them
+	position := func(i int) string {
+		if i < 0 || i >= len(z.Pages) {
+			return ""
+		}
+		for _, p := range z.Content.CPages.Pages {
+			if p.ID == z.Pages[i].UUID && p.Idx != nil {
+				return p.Idx.Value
+			}
+		}
+		return ""
+	}
+	before, after := position(idx-1), position(idx)
+
+	values := make([]string, len(pages))
+	renumber := false
+	for i := range pages {
+		var ok bool
+		if values[i], ok = idxBetween(before, after); !ok {
+			renumber = true
+			break
+		}
+		before = values[i]
+	}
+
+	stamp := z.Content.CPages.stamper()
+	for i, page := range pages {
+		z.Content.CPages.Pages = append(z.Content.CPages.Pages, CPage{
+			ID:       page.UUID,
+			Idx:      &TimestampedStr{Timestamp: stamp(), Value: values[i]},
+			Template: &TimestampedStr{Timestamp: stamp(), Value: template},
+			Redirect: &TimestampedInt{Timestamp: stamp(), Value: -1},
+		})
+	}
+
+	// no room left between the pages around the new ones
+	if renumber {
+		var ids []string
+		for _, p := range z.Pages[:idx] {
+			ids = append(ids, p.UUID)
+		}
+		for _, p := range pages {
+			ids = append(ids, p.UUID)
+		}
+		for _, p := range z.Pages[idx:] {
+			ids = append(ids, p.UUID)
+		}
+		z.renumberCPages(ids)
+	}
+}
+
+// renumberCPages gives new Idx values to the pages of CPages,
+// spread evenly in the order of ids.
+func (z *Zip) renumberCPages(ids []string) {
+	values := spreadIdx(len(ids))
+	positions := make(map[string]int, len(ids))
+	for i, id := range ids {
+		positions[id] = i
+	}
+
+	stamp := z.Content.CPages.stamper()
+	for i := range z.Content.CPages.Pages {
+		p := &z.Content.CPages.Pages[i]
+		n, ok := positions[p.ID]
+		if !ok {
+			continue
+		}
+		if p.Idx == nil {
+			p.Idx = &TimestampedStr{}
+		}
+		p.Idx.Timestamp, p.Idx.Value = stamp(), values[n]
+	}
+}
+
+// stamper returns a function that returns timestamps for the values of
+// CPages, later than the ones they have, each one later than the previous.
+// The values are merged keeping the latest one, timestamps being made of
+// the id of the author of the change, listed in UUIDs, and a counter.
+func (c *CPages) stamper() func() string {
+	author, counter := 1, 0
+	later := func(timestamp string) {
+		var a, n int
+		if _, err := fmt.Sscanf(timestamp, "%d:%d", &a, &n); err != nil {
+			return
+		}
+		if a > author {
+			author = a
+		}
+		if n > counter {
+			counter = n
+		}
+	}
+
+	var unknown struct {
+		Timestamp string `json:"timestamp"`
+	}
+	values := func(fields map[string]json.RawMessage) {
+		for _, raw := range fields {
+			unknown.Timestamp = ""
+			if json.Unmarshal(raw, &unknown) == nil {
+				later(unknown.Timestamp)
+			}
+		}
+	}
+
+	if c.LastOpened != nil {
+		later(c.LastOpened.Timestamp)
+	}
+	if c.Original != nil {
+		later(c.Original.Timestamp)
+	}
+	values(c.Unknown)
+	for _, p := range c.Pages {
+		for _, v := range []*TimestampedStr{p.Idx, p.Template} {
+			if v != nil {
+				later(v.Timestamp)
+			}
+		}
+		for _, v := range []*TimestampedInt{p.Redirect, p.Deleted} {
+			if v != nil {
+				later(v.Timestamp)
+			}
+		}
+		values(p.Unknown)
+	}
+
+	return func() string {
+		counter++
+		return fmt.Sprintf("%d:%d", author, counter)
+	}
+}
+
+// spreadIdx returns count Idx values of the same length,
+// in order and evenly spread.
+func spreadIdx(count int) []string {
+	length, span := 2, 26*26
+	for span <= 2*count {
+		length++
+		span *= 26
+	}
+
+	values := make([]string, count)
+	for i := range values {
+		n := (i + 1) * span / (count + 1)
+		b := make([]byte, length)
+		for j := length - 1; j >= 0; j-- {
+			b[j] = byte('a' + n%26)
+			n /= 26
+		}
+		values[i] = string(b)
+	}
+	return values
+}
+
+// idxBetween returns a string sorting between a and b, made of lowercase
+// letters like the Idx of CPages. An empty b has no upper bound, as well as
+// a b that doesn't sort after a. It returns false if there is no such
+// string: none sorts before "a", or between "a" and "aa".
+func idxBetween(a, b string) (string, bool) {
+	// digits are 1 for 'a' to 26 for 'z', 0 past the end of a string
+	digit := func(s string, i int) int {
+		if i >= len(s) {
+			return 0
+		}
+		return int(s[i]-'a') + 1
+	}
+
+	var out []byte
+	bounded := b > a
+	for i := 0; ; i++ {
+		lo, hi := digit(a, i), 27
+		if bounded {
+			hi = digit(b, i)
+			// out is b, nothing is left between a and b
+			if hi == 0 {
+				return "", false
+			}
+		}
+
+		if hi-lo > 1 {
+			return string(append(out, byte('a'+(lo+hi)/2-1))), true
+		}
+
+		// keep the digit of a, or the lowest one past its end
+		d := lo
+		if d == 0 {
+			d = 1
+		}
+		out = append(out, byte('a'+d-1))
+		if d < hi {
+			bounded = false
+		}
+	}
+}
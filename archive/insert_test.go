@@ -0,0 +1,247 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/juruen/rmapi/encoding/rm"
+)
+
+func TestInsertPagesNotebook(t *testing.T) {
+	z := NewNotebook()
+	z.AddPage()
+	z.AddPage()
+	z.Content.LastOpenedPage = 1
+	first, second := z.Pages[0].UUID, z.Pages[1].UUID
+
+	if err := z.InsertPages(1, 2, "P Grid small"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(z.Pages) != 4 || z.Content.PageCount != 4 || len(z.Content.Pages) != 4 {
+		t.Fatalf("wrong pages %+v", z.Content)
+	}
+	if z.Content.Pages[0] != first || z.Content.Pages[3] != second {
+		t.Error("the pages around the inserted ones should be kept")
+	}
+	if z.Pages[1].Pagedata != "P Grid small" || z.Pages[2].Pagedata != "P Grid small" || z.Pages[1].UUID == z.Pages[2].UUID {
+		t.Errorf("wrong inserted pages %+v", z.Pages[1:3])
+	}
+	if z.Content.LastOpenedPage != 3 {
+		t.Error("the last opened page should follow the insertion")
+	}
+	if z.Content.RedirectionPageMap != nil {
+		t.Error("notebook pages aren't redirected")
+	}
+
+	if err := z.InsertPages(5, 1, ""); err == nil {
+		t.Error("expected an error for a page out of range")
+	}
+}
+
+func TestInsertPagesPdf(t *testing.T) {
+	z := makePdfDoc(t, 100, 200, 300)
+
+	if err := z.InsertPages(1, 1, "Blank"); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.InsertPages(4, 1, "Blank"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []int{0, -1, 1, 2, -1}
+	if !reflect.DeepEqual(z.Content.RedirectionPageMap, want) || *z.Content.OriginalPageCount != 3 {
+		t.Errorf("got redirections %v, want %v", z.Content.RedirectionPageMap, want)
+	}
+	if z.Pages[1].Data != nil || len(z.Pages[2].Data.Layers) != 2 {
+		t.Error("the drawings should stay on their pdf page")
+	}
+
+	// the pages of newer archives are only redirected in their cPages
+	c := readFiles(t, map[string][]byte{
+		"doc.content": []byte(`{"fileType": "pdf", "formatVersion": 2, "cPages": {"pages": [
+			{"id": "a", "idx": {"timestamp": "1:2", "value": "ba"}, "redir": {"timestamp": "1:2", "value": 0}},
+			{"id": "b", "idx": {"timestamp": "1:2", "value": "bb"}}
+		]}}`),
+		"doc.pagedata": []byte("Blank\nBlank\n"),
+		"doc.pdf":      z.Payload,
+	})
+	if err := c.InsertPages(0, 1, "Blank"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Content.RedirectionPageMap != nil || c.Content.OriginalPageCount != nil {
+		t.Errorf("legacy redirections added to a cPages document: %v", c.Content.RedirectionPageMap)
+	}
+	for i, want := range []int{-1, 0, 1} {
+		if c.PayloadPage(i) != want {
+			t.Errorf("page %d shows pdf page %d, want %d", i, c.PayloadPage(i), want)
+		}
+	}
+
+	// the inserted pages stay blank when splitting the document
+	docs, err := z.Split(PageRange{1, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(docs[0].Content.RedirectionPageMap, []int{-1, 0}) {
+		t.Errorf("wrong redirections %v", docs[0].Content.RedirectionPageMap)
+	}
+}
+
+func TestInsertPagesCPages(t *testing.T) {
+	z := readFiles(t, map[string][]byte{
+		"doc.content": []byte(`{"fileType": "notebook", "formatVersion": 2, "cPages": {"pages": [
+			{"id": "b", "idx": {"timestamp": "1:2", "value": "bb"}},
+			{"id": "a", "idx": {"timestamp": "1:2", "value": "ba"}}
+		]}}`),
+		"doc.pagedata": []byte("Blank\nBlank\n"),
+	})
+
+	if err := z.InsertPages(1, 2, "P Lines small"); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := z.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	r := NewZip()
+	if err := r.Read(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil {
+		t.Fatal(err)
+	}
+
+	// the order given by the cPages has to match the page list
+	c := r.Content
+	c.Pages = nil
+	if ids := c.PageIDs(); !reflect.DeepEqual(ids, r.Content.Pages) || ids[0] != "a" || ids[3] != "b" {
+		t.Errorf("got pages %v, want %v", ids, r.Content.Pages)
+	}
+	for _, p := range c.CPages.Pages[2:] {
+		b, _ := json.Marshal(p)
+		if p.Template == nil || p.Template.Value != "P Lines small" || p.Redirect == nil || p.Redirect.Value != -1 {
+			t.Errorf("wrong inserted page %s", b)
+		}
+		// the values are merged keeping the latest one
+		for _, stamp := range []string{p.Idx.Timestamp, p.Template.Timestamp, p.Redirect.Timestamp} {
+			if !laterStamp(stamp, "1:2") {
+				t.Errorf("timestamp %q of an inserted page isn't later than the ones of the document", stamp)
+			}
+		}
+	}
+}
+
+// laterStamp tells whether the timestamp a of a CPages value is later than b.
+func laterStamp(a, b string) bool {
+	var aAuthor, aCounter, bAuthor, bCounter int
+	if _, err := fmt.Sscanf(a, "%d:%d", &aAuthor, &aCounter); err != nil {
+		return false
+	}
+	if _, err := fmt.Sscanf(b, "%d:%d", &bAuthor, &bCounter); err != nil {
+		return false
+	}
+	return aAuthor >= bAuthor && aCounter > bCounter
+}
+
+func TestCPagesStamper(t *testing.T) {
+	z := readFiles(t, map[string][]byte{
+		"doc.content": []byte(`{"fileType": "notebook", "formatVersion": 2, "cPages": {
+			"lastOpened": {"timestamp": "1:9", "value": "a"},
+			"pages": [
+				{"id": "a", "idx": {"timestamp": "2:3", "value": "ba"}, "scrollTime": {"timestamp": "1:12", "value": "1670000000"}}
+			]
+		}}`),
+		"doc.pagedata": []byte("Blank\n"),
+	})
+
+	stamp := z.Content.CPages.stamper()
+	first, second := stamp(), stamp()
+	if first != "2:13" || !laterStamp(second, first) {
+		t.Errorf("got timestamps %q and %q, want them later than 2:3 and 1:12, and increasing", first, second)
+	}
+}
+
+func TestInsertPagesIndexNames(t *testing.T) {
+	page := func(layers int) []byte {
+		data, err := (&rm.Rm{Version: rm.V5, Layers: make([]rm.Layer, layers)}).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	z := readFiles(t, map[string][]byte{
+		"doc.content":  []byte(`{"fileType": "notebook", "pageCount": 2}`),
+		"doc.pagedata": []byte("Blank\nBlank\n"),
+		"doc/0.rm":     page(1),
+		"doc/1.rm":     page(2),
+	})
+
+	if err := z.InsertPages(0, 1, ""); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := z.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	r := NewZip()
+	if err := r.Read(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil {
+		t.Fatal(err)
+	}
+
+	// the drawings follow their pages
+	if len(r.Pages) != 3 || r.Pages[0].Data != nil {
+		t.Fatalf("the inserted page should be blank")
+	}
+	for i, layers := range []int{1, 2} {
+		if data := r.Pages[i+1].Data; data == nil || len(data.Layers) != layers {
+			t.Errorf("page %d lost its drawing", i+1)
+		}
+	}
+}
+
+func TestIdxBetween(t *testing.T) {
+	for _, c := range [][2]string{
+		{"", ""}, {"ba", "bb"}, {"ba", ""}, {"", "ba"}, {"b", "bb"}, {"az", "b"}, {"zz", ""}, {"ba", "bab"},
+	} {
+		idx, ok := idxBetween(c[0], c[1])
+		if !ok || idx <= c[0] || (c[1] != "" && idx >= c[1]) {
+			t.Errorf("idxBetween(%q, %q) = %q", c[0], c[1], idx)
+		}
+	}
+
+	for _, c := range [][2]string{{"", "a"}, {"a", "aa"}, {"ba", "baa"}} {
+		if idx, ok := idxBetween(c[0], c[1]); ok {
+			t.Errorf("idxBetween(%q, %q) = %q, nothing sorts between them", c[0], c[1], idx)
+		}
+	}
+}
+
+func TestInsertPagesRenumber(t *testing.T) {
+	z := readFiles(t, map[string][]byte{
+		"doc.content": []byte(`{"fileType": "notebook", "formatVersion": 2, "cPages": {"pages": [
+			{"id": "a", "idx": {"timestamp": "1:2", "value": "a"}},
+			{"id": "b", "idx": {"timestamp": "1:2", "value": "b"}}
+		]}}`),
+		"doc.pagedata": []byte("Blank\nBlank\n"),
+	})
+
+	// nothing sorts before "a"
+	if err := z.InsertPages(0, 2, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	c := z.Content
+	c.Pages = nil
+	ids := c.PageIDs()
+	if len(ids) != 4 || ids[2] != "a" || ids[3] != "b" || ids[0] != z.Pages[0].UUID || ids[1] != z.Pages[1].UUID {
+		t.Errorf("wrong page order %v", ids)
+	}
+	// the new order wins over the one of the document
+	for _, p := range c.CPages.Pages {
+		if !laterStamp(p.Idx.Timestamp, "1:2") {
+			t.Errorf("page %s renumbered with the timestamp %q", p.ID, p.Idx.Timestamp)
+		}
+	}
+}
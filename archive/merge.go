@@ -0,0 +1,252 @@
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/uuid"
+	"github.com/juruen/rmapi/pdfbox"
+	"github.com/juruen/rmapi/util"
+	"github.com/phpdave/gofpdf"
+	"github.com/phpdave/gofpdf/contrib/gofpdi"
+)
+
+// PageRange is a range of pages from Start to End excluded,
+// pages being numbered from 0.
+type PageRange struct {
+	Start, End int
+}
+
+// pageRef is a page of a document.
+type pageRef struct {
+	doc *Zip
+	idx int
+}
+
+// Merge makes a new document made of the pages of docs, in order.
+// Notebooks can be merged with pdf documents, their pages being inserted
+// between the ones of the pdf.
+//
+// The drawings, templates, highlights and thumbnails of the pages are kept,
+// the pages of the pdf files being copied as shown, cropped and rotated,
+// without their links and outline.
+func Merge(docs ...*Zip) (*Zip, error) {
+	var refs []pageRef
+	for _, doc := range docs {
+		for idx := range doc.Pages {
+			refs = append(refs, pageRef{doc, idx})
+		}
+	}
+
+	return assemble(docs, refs)
+}
+
+// Extract makes a new document made of the given pages of z, in order.
+// See Merge for what is kept of the pages.
+func (z *Zip) Extract(pages ...int) (*Zip, error) {
+	refs := make([]pageRef, len(pages))
+	for i, idx := range pages {
+		if err := z.checkPage(idx); err != nil {
+			return nil, err
+		}
+		refs[i] = pageRef{z, idx}
+	}
+
+	return assemble([]*Zip{z}, refs)
+}
+
+// Split makes one document per range of pages of z.
+func (z *Zip) Split(ranges ...PageRange) ([]*Zip, error) {
+	var docs []*Zip
+	for _, r := range ranges {
+		if r.Start < 0 || r.End > len(z.Pages) || r.Start >= r.End {
+			return nil, fmt.Errorf("invalid page range %d-%d", r.Start+1, r.End)
+		}
+
+		var pages []int
+		for idx := r.Start; idx < r.End; idx++ {
+			pages = append(pages, idx)
+		}
+
+		doc, err := z.Extract(pages...)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+
+	return docs, nil
+}
+
+// PayloadPage returns the index of the page of the pdf shown on
+// a page of the document, -1 for pages inserted in the document.
+func (z *Zip) PayloadPage(idx int) int {
+	if idx < len(z.Content.RedirectionPageMap) {
+		return z.Content.RedirectionPageMap[idx]
+	}
+
+	if z.Content.CPages != nil && idx < len(z.Pages) {
+		for _, p := range z.Content.CPages.Pages {
+			if p.ID == z.Pages[idx].UUID && p.Redirect != nil {
+				return p.Redirect.Value
+			}
+		}
+	}
+
+	return idx
+}
+
+// assemble makes a new document from pages of docs.
+func assemble(docs []*Zip, refs []pageRef) (*Zip, error) {
+	if len(docs) == 0 {
+		return nil, errors.New("no document")
+	}
+
+	fileType := "notebook"
+	for _, doc := range docs {
+		switch doc.Content.FileType {
+		case "pdf":
+			fileType = "pdf"
+		case "notebook":
+		default:
+			return nil, fmt.Errorf("unsupported file type %q, only pdf documents and notebooks are supported", doc.Content.FileType)
+		}
+	}
+
+	z := NewZip()
+	z.Content = derivedContent(docs[0].Content)
+	z.Content.FileType = fileType
+
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		page := ref.doc.Pages[ref.idx]
+		page.ref, page.name = 0, ""
+
+		// a page can be taken twice, or be in two copies of a document
+		id := page.UUID
+		if id == "" || seen[id] {
+			page.UUID = uuid.New().String()
+		}
+		seen[page.UUID] = true
+		z.Pages = append(z.Pages, page)
+
+		for _, tag := range ref.doc.Content.PageTags {
+			if id != "" && tag.PageID == id {
+				tag.PageID = page.UUID
+				z.Content.PageTags = append(z.Content.PageTags, tag)
+			}
+		}
+	}
+	z.syncPages(true)
+
+	if fileType == "pdf" {
+		if err := z.assemblePayload(refs); err != nil {
+			return nil, err
+		}
+	}
+
+	return z, nil
+}
+
+// assemblePayload makes the pdf file of a document from the pages of the
+// pdf files of the documents the pages come from. Pages that don't have
+// a pdf page are redirected to -1.
+func (z *Zip) assemblePayload(refs []pageRef) error {
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{UnitStr: "pt"})
+	importer := gofpdi.NewImporter()
+	streams := make(map[*Zip]*io.ReadSeeker)
+	boxes := make(map[*Zip]map[int]pdfbox.Box)
+
+	redirections := make([]int, len(refs))
+	inserted := false
+	count := 0
+
+	err := util.Safely(func() error {
+		for i, ref := range refs {
+			pdfPage := -1
+			if ref.doc.Content.FileType == "pdf" && ref.doc.Payload != nil {
+				pdfPage = ref.doc.PayloadPage(ref.idx)
+			}
+			if pdfPage < 0 {
+				redirections[i] = -1
+				inserted = true
+				continue
+			}
+
+			rs, ok := streams[ref.doc]
+			if !ok {
+				reader, err := pdfbox.Open(ref.doc.Payload)
+				if err != nil {
+					return err
+				}
+				if boxes[ref.doc], err = pdfbox.Read(reader); err != nil {
+					return err
+				}
+
+				r := io.ReadSeeker(bytes.NewReader(ref.doc.Payload))
+				rs = &r
+				streams[ref.doc] = rs
+			}
+			box, ok := boxes[ref.doc][pdfPage+1]
+			if !ok {
+				return fmt.Errorf("missing pdf page %d", pdfPage+1)
+			}
+
+			// the pages are copied as shown, cropped and rotated, like the
+			// drawings over them: gofpdi imports them with the media box of
+			// the first page, and they are cropped when drawn
+			tpl := importer.ImportPageFromStream(pdf, rs, pdfPage+1, "/MediaBox")
+			w, h := box.Size()
+			// gofpdf would swap the width and the height of a landscape page
+			pdf.AddPageFormat("P", gofpdf.SizeType{Wd: w, Ht: h})
+			tx, ty, tw, th := box.TemplateRect(boxes[ref.doc][1])
+			pdf.ClipRect(0, 0, w, h, false)
+			importer.UseImportedTemplate(pdf, tpl, tx, ty, tw, th)
+			pdf.ClipEnd()
+
+			redirections[i] = count
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Can't copy the pdf pages: %v", err)
+	}
+
+	// a pdf file needs a page
+	if count == 0 {
+		pdf.AddPage()
+		count++
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return err
+	}
+	z.Payload = buf.Bytes()
+
+	if inserted {
+		z.Content.RedirectionPageMap = redirections
+		z.Content.OriginalPageCount = &count
+	}
+	return nil
+}
+
+// derivedContent returns the content of a document made from the pages of
+// the document of content c: the fields describing its pages are reset.
+func derivedContent(c Content) Content {
+	c.Pages = nil
+	c.PageCount = 0
+	c.PageTags = nil
+	c.CPages = nil
+	c.RedirectionPageMap = nil
+	c.OriginalPageCount = nil
+	c.CoverPageNumber = nil
+	c.LastOpenedPage = 0
+	c.SizeInBytes = ""
+	c.FormatVersion = 0
+	c.Unknown = nil
+	return c
+}
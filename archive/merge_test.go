@@ -0,0 +1,261 @@
+package archive
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/phpdave/gofpdf"
+	pdfmodel "github.com/unidoc/unipdf/v3/model"
+	pdfrender "github.com/unidoc/unipdf/v3/render"
+)
+
+// makePdfDoc makes a pdf document whose pages have the given widths,
+// each page having a drawing with as many layers as its index plus one.
+func makePdfDoc(t *testing.T, widths ...float64) *Zip {
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{UnitStr: "pt"})
+	for _, w := range widths {
+		pdf.AddPageFormat("P", gofpdf.SizeType{Wd: w, Ht: 800})
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	z := NewZip()
+	z.Content.FileType = "pdf"
+	z.Payload = buf.Bytes()
+	for i := range widths {
+		idx := z.AddPage()
+		z.SetTemplate(idx, "Blank")
+		z.SetData(idx, &rm.Rm{Version: rm.V5, Layers: make([]rm.Layer, i+1)})
+		z.Pages[idx].Thumbnail = []byte{byte(i)}
+	}
+	return z
+}
+
+// pdfWidths returns the widths of the pages of a pdf.
+func pdfWidths(t *testing.T, pdf []byte) []float64 {
+	reader, err := pdfmodel.NewPdfReader(bytes.NewReader(pdf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := reader.GetNumPages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var widths []float64
+	for i := 1; i <= count; i++ {
+		page, err := reader.GetPage(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		box, err := page.GetMediaBox()
+		if err != nil {
+			t.Fatal(err)
+		}
+		widths = append(widths, box.Width())
+	}
+	return widths
+}
+
+// shownPdf makes a pdf document of pages with the given attributes,
+// each page drawing a blue square at 100 100.
+func shownPdf(t *testing.T, pageAttrs ...string) *Zip {
+	objects := []string{"<< /Type /Catalog /Pages 2 0 R >>", ""}
+	kids := ""
+	for _, attrs := range pageAttrs {
+		page, content := len(objects)+1, len(objects)+2
+		kids += fmt.Sprintf("%d 0 R ", page)
+		stream := "0 0 1 rg 100 100 50 50 re f"
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /Resources << >> /Contents %d 0 R %s >>", content, attrs),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, len(pageAttrs))
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	z := NewZip()
+	z.Content.FileType = "pdf"
+	z.Payload = buf.Bytes()
+	for range pageAttrs {
+		z.AddPage()
+	}
+	return z
+}
+
+// checkSquare renders a page of a pdf and checks its size and that the
+// square of shownPdf is drawn with its top left corner at x y, from the
+// top left corner of the page.
+func checkSquare(t *testing.T, pdf []byte, num int, w, h, x, y float64) {
+	t.Helper()
+
+	reader, err := pdfmodel.NewPdfReader(bytes.NewReader(pdf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := reader.GetPage(num)
+	if err != nil {
+		t.Fatal(err)
+	}
+	box, err := page.GetMediaBox()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(box.Width()-w) > 0.5 || math.Abs(box.Height()-h) > 0.5 {
+		t.Errorf("page %d is %vx%v, want %vx%v", num, box.Width(), box.Height(), w, h)
+	}
+
+	img, err := pdfrender.NewImageDevice().Render(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blue := color.RGBA{B: 255, A: 255}
+	for _, p := range [][2]float64{{5, 5}, {45, 5}, {5, 45}, {45, 45}} {
+		if c := color.RGBAModel.Convert(img.At(int(x+p[0]), int(y+p[1]))); c != blue {
+			t.Errorf("page %d: the square should be at %v %v, found %v at %v", num, x, y, c, p)
+		}
+	}
+	for _, p := range [][2]float64{{-5, 25}, {55, 25}} {
+		if c := color.RGBAModel.Convert(img.At(int(x+p[0]), int(y+p[1]))); c == blue {
+			t.Errorf("page %d: the square should be at %v %v, found it at %v", num, x, y, p)
+		}
+	}
+}
+
+func equalWidths(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i]-b[i] > 0.5 || b[i]-a[i] > 0.5 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSplit(t *testing.T) {
+	doc := makePdfDoc(t, 100, 200, 300, 400)
+
+	docs, err := doc.Split(PageRange{0, 1}, PageRange{1, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("got %d documents", len(docs))
+	}
+
+	second := docs[1]
+	if !equalWidths(pdfWidths(t, second.Payload), []float64{200, 300, 400}) {
+		t.Errorf("wrong pdf pages %v", pdfWidths(t, second.Payload))
+	}
+	if second.Content.PageCount != 3 || len(second.Content.Pages) != 3 || second.Content.RedirectionPageMap != nil {
+		t.Errorf("wrong content %+v", second.Content)
+	}
+	for i, page := range second.Pages {
+		if len(page.Data.Layers) != i+2 || page.Thumbnail[0] != byte(i+1) || page.UUID != doc.Pages[i+1].UUID {
+			t.Errorf("page %d not aligned with its pdf page", i)
+		}
+	}
+
+	if _, err := doc.Split(PageRange{2, 5}); err == nil {
+		t.Error("expected an error for a range out of the document")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a := makePdfDoc(t, 100, 200)
+	b := makePdfDoc(t, 300)
+
+	notebook := NewNotebook()
+	notebook.SetTemplate(notebook.AddPage(), "P Grid small")
+
+	z, err := Merge(a, notebook, b, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !equalWidths(pdfWidths(t, z.Payload), []float64{100, 200, 300, 100, 200}) {
+		t.Errorf("wrong pdf pages %v", pdfWidths(t, z.Payload))
+	}
+	want := []int{0, 1, -1, 2, 3, 4}
+	if len(z.Content.RedirectionPageMap) != len(want) || *z.Content.OriginalPageCount != 5 {
+		t.Fatalf("wrong redirections %v", z.Content.RedirectionPageMap)
+	}
+	for i, p := range want {
+		if z.PayloadPage(i) != p {
+			t.Errorf("page %d shows pdf page %d, want %d", i, z.PayloadPage(i), p)
+		}
+	}
+	if z.Pages[2].Pagedata != "P Grid small" {
+		t.Error("the template of the notebook page should be kept")
+	}
+
+	ids := make(map[string]bool)
+	for _, id := range z.Content.Pages {
+		ids[id] = true
+	}
+	if len(ids) != 6 {
+		t.Error("the pages of a document merged twice should get new ids")
+	}
+
+	// the merged document can be written and read back
+	var buf bytes.Buffer
+	if err := z.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	r := NewZip()
+	if err := r.Read(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Pages) != 6 || r.PayloadPage(5) != 4 || len(r.Pages[4].Data.Layers) != 1 {
+		t.Errorf("wrong document read back %+v", r.Content)
+	}
+
+	if _, err := Merge(a, &Zip{Content: Content{FileType: "epub"}}); err == nil {
+		t.Error("epub documents can't be merged")
+	}
+}
+
+func TestMergeLandscape(t *testing.T) {
+	z, err := Merge(shownPdf(t, "/MediaBox [0 0 800 400]"), shownPdf(t, "/MediaBox [0 0 400 800]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkSquare(t, z.Payload, 1, 800, 400, 100, 250)
+	checkSquare(t, z.Payload, 2, 400, 800, 100, 650)
+}
+
+func TestExtractRotatedAndCropped(t *testing.T) {
+	doc := shownPdf(t,
+		"/MediaBox [0 0 595 842] /Rotate 90",
+		"/MediaBox [0 0 595 842] /CropBox [50 60 545 792]",
+	)
+	z, err := doc.Extract(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the pages are copied as shown, like the drawings over them
+	checkSquare(t, z.Payload, 1, 495, 732, 50, 642)
+	checkSquare(t, z.Payload, 2, 842, 595, 100, 100)
+}
@@ -0,0 +1,176 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// ErrNoPayload is returned by Archive.Payload for documents without
+// a pdf or epub file, such as notebooks.
+var ErrNoPayload = errors.New("archive does not contain a payload")
+
+// Archive gives access to the files of a Remarkable archive without
+// reading them upfront, unlike Zip.Read: only the .content and .pagedata
+// files are read by Open, pages, thumbnails and the payload being
+// decoded when they are asked for.
+type Archive struct {
+	UUID    string
+	Content Content
+
+	r       io.ReaderAt
+	z       *Zip
+	payload *zip.File
+	pages   []pageFiles
+}
+
+// pageFiles are the files of a page.
+type pageFiles struct {
+	data       *zip.File
+	metadata   *zip.File
+	highlights *zip.File
+	thumbnail  *zip.File
+}
+
+// Open opens a Remarkable archive file for random access.
+// The reader has to stay open as long as the archive is used.
+func Open(r io.ReaderAt, size int64) (*Archive, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	z := NewZip()
+	if err := z.readContent(zr); err != nil {
+		return nil, err
+	}
+
+	a := &Archive{r: r, z: z}
+	if z.makePages() {
+		if err := z.readPagedata(zr); err != nil {
+			return nil, err
+		}
+	}
+	a.pages = make([]pageFiles, len(z.Pages))
+
+	for _, file := range zr.File {
+		kind, idx := z.fileKind(file.Name)
+		if idx >= 0 && kind != "highlights" {
+			z.Pages[idx].name = pageFileName(file.Name)
+		}
+
+		switch kind {
+		case "payload":
+			a.payload = file
+		case "rm":
+			a.pages[idx].data = file
+		case "metadata":
+			a.pages[idx].metadata = file
+		case "highlights":
+			a.pages[idx].highlights = file
+		case "thumbnail":
+			a.pages[idx].thumbnail = file
+		}
+	}
+
+	a.UUID = z.UUID
+	a.Content = z.Content
+	return a, nil
+}
+
+// PageCount returns the number of pages of the document.
+func (a *Archive) PageCount() int {
+	return len(a.pages)
+}
+
+// Page decodes a page of the archive, its drawing, metadata and highlights.
+// The thumbnail of the page isn't read, see Thumbnail.
+func (a *Archive) Page(idx int) (*Page, error) {
+	if err := a.z.checkPage(idx); err != nil {
+		return nil, err
+	}
+
+	page := a.z.Pages[idx]
+	files := a.pages[idx]
+
+	if files.data != nil {
+		data, err := decodePage(files.data, idx)
+		if err != nil {
+			return nil, err
+		}
+		page.Data = data
+	}
+
+	if files.metadata != nil {
+		if err := readJSON(files.metadata, &page.Metadata); err != nil {
+			return nil, fmt.Errorf("Can't read the metadata of page %d: %v", idx, err)
+		}
+	}
+
+	if files.highlights != nil {
+		if err := readJSON(files.highlights, &page.Highlights); err != nil {
+			return nil, fmt.Errorf("Can't read the highlights of page %d: %v", idx, err)
+		}
+	}
+
+	return &page, nil
+}
+
+// Thumbnail returns the thumbnail of a page, nil if it has none.
+func (a *Archive) Thumbnail(idx int) ([]byte, error) {
+	if err := a.z.checkPage(idx); err != nil {
+		return nil, err
+	}
+
+	if a.pages[idx].thumbnail == nil {
+		return nil, nil
+	}
+	return readFile(a.pages[idx].thumbnail)
+}
+
+// Payload returns the pdf or epub file of the document. A stored payload,
+// as written by this package, is read from the archive as it is sought,
+// a compressed one has to be read in memory first.
+func (a *Archive) Payload() (io.ReadSeeker, error) {
+	if a.payload == nil {
+		return nil, ErrNoPayload
+	}
+
+	if a.payload.Method == zip.Store {
+		offset, err := a.payload.DataOffset()
+		if err != nil {
+			return nil, err
+		}
+		return io.NewSectionReader(a.r, offset, int64(a.payload.UncompressedSize64)), nil
+	}
+
+	data, err := readFile(a.payload)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// readFile reads a file of an archive.
+func readFile(file *zip.File) ([]byte, error) {
+	r, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return ioutil.ReadAll(r)
+}
+
+// readJSON decodes a json file of an archive into v.
+func readJSON(file *zip.File, v interface{}) error {
+	data, err := readFile(file)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,127 @@
+package archive
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestOpen(t *testing.T) {
+	data := fixture(t)
+	a, err := Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.UUID != fixtureUUID || a.Content.FileType != "epub" || a.PageCount() != 2 {
+		t.Fatalf("wrong archive %s %+v", a.UUID, a.Content)
+	}
+
+	page, err := a.Page(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.UUID != fixturePage || page.Pagedata != "Blank" || page.Data == nil {
+		t.Errorf("wrong page %+v", page)
+	}
+	if len(page.Metadata.Layers) != 1 || len(page.Highlights.LayerHighlights) != 1 {
+		t.Error("metadata and highlights of the page should be read")
+	}
+	if page.Thumbnail != nil {
+		t.Error("the thumbnail should only be read by Thumbnail")
+	}
+
+	if page, err = a.Page(1); err != nil || page.Data == nil || len(page.Highlights.LayerHighlights) != 0 {
+		t.Errorf("wrong second page %+v %v", page, err)
+	}
+	if _, err := a.Page(2); err == nil {
+		t.Error("expected an error for a page out of range")
+	}
+
+	if thumbnail, err := a.Thumbnail(0); err != nil || string(thumbnail) != "jpg" {
+		t.Errorf("wrong thumbnail %q %v", thumbnail, err)
+	}
+	if thumbnail, err := a.Thumbnail(1); err != nil || thumbnail != nil {
+		t.Errorf("the second page has no thumbnail, got %q %v", thumbnail, err)
+	}
+
+	payload, err := a.Payload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(payload); string(b) != "epub" {
+		t.Errorf("wrong payload %q", b)
+	}
+}
+
+func TestOpenStoredPayload(t *testing.T) {
+	z := NewZip()
+	z.Content.FileType = "pdf"
+	z.Payload = []byte("%PDF-1.4 payload")
+	z.Pages = []Page{{}}
+
+	var buf bytes.Buffer
+	if err := z.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := Open(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := a.Payload()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := payload.Seek(9, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(payload); string(b) != "payload" {
+		t.Errorf("wrong payload %q", b)
+	}
+}
+
+func TestOpenLikeRead(t *testing.T) {
+	file, err := os.Open("test.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	z := NewZip()
+	if err := z.Read(file, fi.Size()); err != nil {
+		t.Fatal(err)
+	}
+	a, err := Open(file, fi.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.PageCount() != len(z.Pages) {
+		t.Fatalf("got %d pages, want %d", a.PageCount(), len(z.Pages))
+	}
+	for idx := range z.Pages {
+		page, err := a.Page(idx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Thumbnail, err = a.Thumbnail(idx); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(*page, z.Pages[idx]) {
+			t.Errorf("page %d differs from the one of Read", idx)
+		}
+	}
+
+	if _, err := a.Payload(); err != ErrNoPayload {
+		t.Errorf("expected no payload, got %v", err)
+	}
+}
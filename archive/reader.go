@@ -17,12 +17,24 @@ import (
 )
 
 // Read fills a Zip parsing a Remarkable archive file.
+// What is needed to write it back losslessly is kept: the files that
+// aren't handled by this package and the ones that Write wouldn't
+// reproduce byte for byte.
 func (z *Zip) Read(r io.ReaderAt, size int64) error {
 	zr, err := zip.NewReader(r, size)
 	if err != nil {
 		return err
 	}
 
+	z.source = nil
+	if err := z.read(zr); err != nil {
+		return err
+	}
+
+	return z.keepSource(zr)
+}
+
+func (z *Zip) read(zr *zip.Reader) error {
 	// reading content first because it contains the number of pages
 	if err := z.readContent(zr); err != nil {
 		return err
@@ -33,12 +45,10 @@ func (z *Zip) Read(r io.ReaderAt, size int64) error {
 	}
 
 	//uploading and then downloading a file results in 0 pages
-	if z.Content.PageCount <= 0 {
+	if !z.makePages() {
 		log.Warning.Printf("PageCount is 0")
 		return nil
 	}
-	// instantiate the slice of pages
-	z.Pages = make([]Page, z.Content.PageCount)
 
 	if err := z.readMetadata(zr); err != nil {
 		return err
@@ -63,6 +73,28 @@ func (z *Zip) Read(r io.ReaderAt, size int64) error {
 	return nil
 }
 
+// makePages instantiates the pages listed by the content, it returns
+// false if there are none.
+func (z *Zip) makePages() bool {
+	ids := z.Content.PageIDs()
+	pageCount := z.Content.PageCount
+	if len(ids) > pageCount {
+		pageCount = len(ids)
+	}
+
+	if pageCount <= 0 {
+		return false
+	}
+	z.Pages = make([]Page, pageCount)
+	for idx := range z.Pages {
+		z.Pages[idx].ref = idx + 1
+		if idx < len(ids) {
+			z.Pages[idx].UUID = ids[idx]
+		}
+	}
+	return true
+}
+
 // readContent reads the .content file contained in an archive and the UUID
 func (z *Zip) readContent(zr *zip.Reader) error {
 	files, err := zipExtFinder(zr, ".content")
@@ -116,7 +148,7 @@ func (z *Zip) readPagedata(zr *zip.Reader) error {
 	// iterate pagedata file lines
 	sc := bufio.NewScanner(file)
 	var i int = 0
-	for sc.Scan() {
+	for sc.Scan() && i < len(z.Pages) {
 		line := sc.Text()
 		z.Pages[i].Pagedata = line
 		i++
@@ -166,33 +198,37 @@ func (z *Zip) readData(zr *zip.Reader) error {
 	for _, file := range files {
 		name, _ := splitExt(file.FileInfo().Name())
 
-		idx, err := strconv.Atoi(name)
-		if err != nil {
-			return errors.New("error in .rm filename")
-		}
-
-		if len(z.Pages) <= idx {
-			return errors.New("page not found")
+		idx, ok := z.filePage(file, name)
+		if !ok {
+			continue
 		}
+		z.Pages[idx].name = name
 
-		r, err := file.Open()
+		z.Pages[idx].Data, err = decodePage(file, idx)
 		if err != nil {
 			return err
 		}
+	}
 
-		bytes, err := ioutil.ReadAll(r)
-		if err != nil {
-			return err
-		}
+	return nil
+}
 
-		z.Pages[idx].Data = rm.New()
-		z.Pages[idx].Data.UnmarshalBinary(bytes)
-		if err != nil {
-			return err
-		}
+// decodePage decodes the drawing of a page. A corrupted page shouldn't
+// prevent reading the rest of the notebook, what could be decoded is kept.
+func decodePage(file *zip.File, idx int) (*rm.Rm, error) {
+	r, err := file.Open()
+	if err != nil {
+		return nil, err
 	}
+	defer r.Close()
 
-	return nil
+	data := rm.New()
+	d := rm.NewDecoder(r)
+	d.Lenient = true
+	if err := d.Decode(data); err != nil {
+		log.Warning.Printf("page %d: %v", idx, err)
+	}
+	return data, nil
 }
 
 // readThumbnails extracts existing thumbnails from an archive.
@@ -205,14 +241,11 @@ func (z *Zip) readThumbnails(zr *zip.Reader) error {
 	for _, file := range files {
 		name, _ := splitExt(file.FileInfo().Name())
 
-		idx, err := strconv.Atoi(name)
-		if err != nil {
-			return errors.New("error in .jpg filename")
-		}
-
-		if len(z.Pages) <= idx {
-			return errors.New("page not found")
+		idx, ok := z.filePage(file, name)
+		if !ok {
+			continue
 		}
+		z.Pages[idx].name = name
 
 		r, err := file.Open()
 		if err != nil {
@@ -242,15 +275,12 @@ func (z *Zip) readMetadata(zr *zip.Reader) error {
 			continue
 		}
 
-		// name is 0-metadata.json
-		idx, err := strconv.Atoi(strings.Split(name, "-")[0])
-		if err != nil {
-			return errors.New("error in metadata .json filename")
-		}
-
-		if len(z.Pages) <= idx {
-			return errors.New("page not found")
+		// name is 0-metadata.json or <page id>-metadata.json
+		idx, ok := z.filePage(file, strings.TrimSuffix(name, "-metadata"))
+		if !ok {
+			continue
 		}
+		z.Pages[idx].name = strings.TrimSuffix(name, "-metadata")
 
 		r, err := file.Open()
 		if err != nil {
@@ -279,7 +309,7 @@ func (z *Zip) readHighlights(zr *zip.Reader) error {
 	}
 
 	for _, file := range files {
-		name, _ := splitExt(file.FileHeader.Name)
+		name, _ := splitExt(file.FileInfo().Name())
 
 		dir := filepath.Dir(file.FileHeader.Name)
 
@@ -288,29 +318,64 @@ func (z *Zip) readHighlights(zr *zip.Reader) error {
 		}
 
 		// name is pageID.json
-		for idx, p := range z.Content.Pages {
-			if strings.Contains(name, p) {
-				r, err := file.Open()
-				if err != nil {
-					return err
-				}
-
-				bytes, err := ioutil.ReadAll(r)
-				if err != nil {
-					return err
-				}
-
-				err = json.Unmarshal(bytes, &z.Pages[idx].Highlights)
-				if err != nil {
-					return err
-				}
-			}
+		idx, err := z.pageIndex(name)
+		if err != nil {
+			continue
+		}
+
+		r, err := file.Open()
+		if err != nil {
+			return err
+		}
+
+		bytes, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+
+		err = json.Unmarshal(bytes, &z.Pages[idx].Highlights)
+		if err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+// filePage returns the index of the page a file is named after. The files
+// of a page that isn't in the document, reported by Validate, are skipped.
+func (z *Zip) filePage(file *zip.File, name string) (int, bool) {
+	idx, err := z.pageIndex(name)
+	if err != nil {
+		log.Warning.Printf("skipping %s: %v", file.Name, err)
+		return 0, false
+	}
+	return idx, true
+}
+
+// pageIndex returns the index of the page whose files are named after name.
+// Newer archives name them after the ID of the page, its position being
+// given by the page list of the content, and older ones after its index.
+func (z *Zip) pageIndex(name string) (int, error) {
+	for idx, page := range z.Pages {
+		if page.UUID == name {
+			return idx, nil
+		}
+	}
+
+	idx, err := strconv.Atoi(name)
+	if err != nil {
+		return 0, errors.New("unknown page")
+	}
+
+	if idx < 0 || len(z.Pages) <= idx {
+		return 0, errors.New("page not found")
+	}
+
+	return idx, nil
+}
+
 // splitExt splits the extension from a filename
 func splitExt(name string) (string, string) {
 	ext := filepath.Ext(name)
@@ -1,8 +1,13 @@
 package archive
 
 import (
+	"archive/zip"
+	"bytes"
 	"os"
 	"testing"
+
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/juruen/rmapi/log"
 )
 
 func TestRead(t *testing.T) {
@@ -26,3 +31,111 @@ func TestRead(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestReadCorruptedPage(t *testing.T) {
+	log.InitLog()
+
+	page := &rm.Rm{Version: rm.V5, Layers: []rm.Layer{{Strokes: []rm.Stroke{
+		{BrushType: rm.BallPointV5, Segments: []rm.Segment{{X: 1, Y: 2}, {X: 3, Y: 4}}},
+		{BrushType: rm.MarkerV5, Segments: []rm.Segment{{X: 5, Y: 6}}},
+	}}}}
+	data, err := page.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	z := readFiles(t, map[string][]byte{
+		"doc.content":  []byte(`{"fileType": "notebook", "pageCount": 2, "pages": ["a", "b"]}`),
+		"doc.pagedata": []byte("Blank\nBlank\n"),
+		"doc/0.rm":     data[:len(data)-4],
+		"doc/1.rm":     data,
+	})
+
+	if len(z.Pages[0].Data.Layers[0].Strokes) != 1 {
+		t.Error("strokes before the corruption should be kept")
+	}
+	if len(z.Pages[1].Data.Layers[0].Strokes) != 2 {
+		t.Error("other pages should be read")
+	}
+}
+
+// readFiles reads an archive made of files.
+func readFiles(t *testing.T, files map[string][]byte) *Zip {
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(content)
+	}
+	w.Close()
+
+	z := NewZip()
+	if err := z.Read(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil {
+		t.Fatal(err)
+	}
+	return z
+}
+
+func TestReadUUIDLayout(t *testing.T) {
+	first, second := "3d5ee1c8-0f7a-4b55-9a64-9b1d2e0cf1a1", "1"
+
+	page := func(layers int) []byte {
+		data, err := (&rm.Rm{Version: rm.V5, Layers: make([]rm.Layer, layers)}).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	// the page ids give the order of the pages, even if an id looks like an index
+	z := readFiles(t, map[string][]byte{
+		"doc.content":                       []byte(`{"fileType": "notebook", "pageCount": 2, "pages": ["` + first + `", "` + second + `"]}`),
+		"doc.pagedata":                      []byte("Blank\nP Grid small\n"),
+		"doc/" + first + ".rm":              page(1),
+		"doc/" + first + "-metadata.json":   []byte(`{"layers": [{"name": "First"}]}`),
+		"doc/" + second + ".rm":             page(2),
+		"doc/" + second + "-metadata.json":  []byte(`{"layers": [{"name": "A"}, {"name": "B"}]}`),
+		"doc.thumbnails/" + second + ".jpg": []byte("jpg"),
+	})
+
+	if len(z.Pages) != 2 || z.Pages[0].UUID != first || z.Pages[1].UUID != second {
+		t.Fatalf("wrong pages %+v", z.Pages)
+	}
+	if len(z.Pages[0].Data.Layers) != 1 || len(z.Pages[1].Data.Layers) != 2 {
+		t.Error("drawings not matched with their pages")
+	}
+	if z.Pages[0].Metadata.Layers[0].Name != "First" || len(z.Pages[1].Metadata.Layers) != 2 {
+		t.Error("metadata not matched with their pages")
+	}
+	if string(z.Pages[1].Thumbnail) != "jpg" || z.Pages[0].Thumbnail != nil {
+		t.Error("thumbnails not matched with their pages")
+	}
+}
+
+func TestReadUnknownPage(t *testing.T) {
+	log.InitLog()
+
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for name, content := range map[string]string{
+		"doc.content":  `{"fileType": "notebook", "pageCount": 1, "pages": ["a"]}`,
+		"doc.pagedata": "Blank\n",
+		"doc/b.rm":     "",
+	} {
+		f, _ := w.Create(name)
+		f.Write([]byte(content))
+	}
+	w.Close()
+
+	// the file is skipped, to be reported by Validate
+	z := NewZip()
+	if err := z.Read(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil {
+		t.Fatal(err)
+	}
+	if len(z.Pages) != 1 || z.Pages[0].Data != nil {
+		t.Errorf("the file of a page missing from the content should be skipped, got %+v", z.Pages)
+	}
+}
@@ -0,0 +1,259 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/juruen/rmapi/encoding/rm"
+)
+
+// entry is a file of an archive as it is stored.
+type entry struct {
+	name string
+	data []byte
+}
+
+// entries lists the files of an archive in order.
+func entries(t *testing.T, data []byte) []entry {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []entry
+	for _, file := range zr.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry{file.Name, b})
+	}
+	return entries
+}
+
+// roundTrip reads an archive and writes it back after calling edit.
+func roundTrip(t *testing.T, data []byte, edit func(z *Zip)) []byte {
+	z := NewZip()
+	if err := z.Read(bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	if edit != nil {
+		edit(z)
+	}
+
+	var buf bytes.Buffer
+	if err := z.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// diffEntries returns the names of the files that differ between two archives,
+// failing if they don't hold the same files in the same order.
+func diffEntries(t *testing.T, want, got []byte) []string {
+	w, g := entries(t, want), entries(t, got)
+	if len(w) != len(g) {
+		t.Fatalf("got %d files, want %d", len(g), len(w))
+	}
+
+	var changed []string
+	for i := range w {
+		if w[i].name != g[i].name {
+			t.Fatalf("file %d is %s, want %s", i, g[i].name, w[i].name)
+		}
+		if !bytes.Equal(w[i].data, g[i].data) {
+			changed = append(changed, w[i].name)
+		}
+	}
+	return changed
+}
+
+const (
+	fixtureUUID = "0a5c1c6e-5d2b-4bd0-a1f4-2b1d8a0e7c11"
+	fixturePage = "b7e2a4f0-9c3d-4e5f-8a6b-1c2d3e4f5a6b"
+)
+
+// fixture is an epub archive with the layout of newer firmwares, and files
+// this package doesn't know about.
+func fixture(t *testing.T) []byte {
+	data, err := ioutil.ReadFile("testfiles/epub.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestRoundTrip(t *testing.T) {
+	testZip, err := ioutil.ReadFile("test.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pdfZip, err := ioutil.ReadFile("testfiles/pdf.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string][]byte{"test.zip": testZip, "epub.zip": fixture(t), "pdf.zip": pdfZip} {
+		if changed := diffEntries(t, data, roundTrip(t, data, nil)); len(changed) > 0 {
+			t.Errorf("%s: files changed by a round trip: %v", name, changed)
+		}
+	}
+}
+
+func TestRoundTripEditContent(t *testing.T) {
+	data := fixture(t)
+	out := roundTrip(t, data, func(z *Zip) {
+		z.Content.Orientation = "landscape"
+	})
+
+	changed := diffEntries(t, data, out)
+	if len(changed) != 1 || changed[0] != fixtureUUID+".content" {
+		t.Fatalf("only the content should change, got %v", changed)
+	}
+
+	var content struct {
+		Orientation  string
+		SomeNewField struct{ Kept bool }
+	}
+	if err := json.Unmarshal(entries(t, out)[0].data, &content); err != nil {
+		t.Fatal(err)
+	}
+	if content.Orientation != "landscape" || !content.SomeNewField.Kept {
+		t.Errorf("wrong content %s", entries(t, out)[0].data)
+	}
+}
+
+func TestRoundTripEditPage(t *testing.T) {
+	data := fixture(t)
+	out := roundTrip(t, data, func(z *Zip) {
+		z.Pages[1].Data.Layers[0].Strokes = nil
+	})
+
+	changed := diffEntries(t, data, out)
+	if len(changed) != 1 || changed[0] != fixtureUUID+"/1.rm" {
+		t.Fatalf("only the edited page should change, got %v", changed)
+	}
+}
+
+func TestRoundTripAddPage(t *testing.T) {
+	data := fixture(t)
+	out := roundTrip(t, data, func(z *Zip) {
+		idx := z.AddPage()
+		z.SetData(idx, &rm.Rm{Version: rm.V5, Layers: make([]rm.Layer, 1)})
+	})
+
+	z := NewZip()
+	if err := z.Read(bytes.NewReader(out), int64(len(out))); err != nil {
+		t.Fatal(err)
+	}
+	if len(z.Pages) != 3 || z.Pages[2].Data == nil {
+		t.Fatalf("page not added: %+v", z.Pages)
+	}
+	if len(z.Pages[0].Highlights.LayerHighlights) != 1 || z.Pages[0].Thumbnail == nil {
+		t.Error("files of the other pages should be kept")
+	}
+}
+
+func TestWriteHighlights(t *testing.T) {
+	z := NewZip()
+	z.Content.FileType = "pdf"
+	z.Payload = []byte("pdf")
+	z.Pages = []Page{{}, {}}
+	err := json.Unmarshal([]byte(`{"highlights": [[{"length": 5, "start": 0, "text": "hello", "color": 3}]]}`),
+		&z.Pages[1].Highlights)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := z.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewZip()
+	if err := r.Read(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Pages[0].Highlights.LayerHighlights) != 0 {
+		t.Error("highlights read on the wrong page")
+	}
+	hl := r.Pages[1].Highlights.LayerHighlights
+	if len(hl) != 1 || len(hl[0]) != 1 || hl[0][0].Text != "hello" || hl[0][0].Color != rm.Yellow {
+		t.Errorf("wrong highlights %+v", hl)
+	}
+}
+
+func TestRoundTripNewUUID(t *testing.T) {
+	data := fixture(t)
+	out := roundTrip(t, data, func(z *Zip) {
+		z.UUID = "new"
+	})
+
+	in := entries(t, data)
+	for i, e := range entries(t, out) {
+		if !strings.HasPrefix(e.name, "new") || strings.TrimPrefix(e.name, "new") != strings.TrimPrefix(in[i].name, fixtureUUID) {
+			t.Errorf("%s not renamed after the new uuid", e.name)
+		}
+		if !bytes.Equal(e.data, in[i].data) {
+			t.Errorf("%s changed", e.name)
+		}
+	}
+}
+
+func TestReadKeepsChangedFiles(t *testing.T) {
+	data, err := ioutil.ReadFile("testfiles/pdf.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := NewZip()
+	if err := z.Read(bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	if len(z.Pages) != 3 || z.Pages[1].Data == nil || z.Pages[1].Data.Version != rm.V6 ||
+		len(z.Pages[0].Highlights.LayerHighlights) != 1 || z.Pages[1].Thumbnail == nil {
+		t.Fatalf("wrong pages %+v", z.Pages)
+	}
+
+	// the payload, the drawings and the thumbnails are written back from the Zip
+	for _, e := range z.source.entries {
+		kept := e.data != nil
+		switch kind, _ := z.fileKind(e.header.Name); kind {
+		case "payload", "rm", "thumbnail":
+			if kept {
+				t.Errorf("%s shouldn't be kept", e.header.Name)
+			}
+		case "":
+			if !kept {
+				t.Errorf("%s should be kept", e.header.Name)
+			}
+		}
+	}
+}
+
+func TestNamedAfter(t *testing.T) {
+	for name, expected := range map[string]bool{
+		"doc.content":            true,
+		"doc/page.rm":            true,
+		"doc.thumbnails/0.jpg":   true,
+		"doc":                    false,
+		"document.content":       false,
+		"other.thumbnails/0.jpg": false,
+	} {
+		if namedAfter(name, "doc") != expected {
+			t.Errorf("namedAfter(%q, \"doc\") != %v", name, expected)
+		}
+	}
+	if namedAfter(".content", "") {
+		t.Error("no file is named after an empty document id")
+	}
+}
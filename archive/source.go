@@ -0,0 +1,133 @@
+package archive
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"fmt"
+	"hash/crc32"
+	"path"
+	"strings"
+)
+
+// source keeps what is needed to write back an archive that was read
+// losslessly: the files that aren't handled by this package, and the
+// parts that wouldn't be written back as they were read.
+type source struct {
+	uuid    string
+	entries []sourceEntry
+}
+
+// A sourceEntry is a file of the archive that was read.
+type sourceEntry struct {
+	header zip.FileHeader
+	// data is nil for the parts that are written back from the Zip
+	// as they were read, like the payload or the thumbnails
+	data []byte
+	// key is the part the file was read into, empty
+	// for files that aren't handled by this package
+	key string
+	// sum is the hash of the part as it would have been written
+	// right after reading, to tell if it was modified since
+	sum [sha256.Size]byte
+}
+
+// keepSource saves the files of the archive read by Read. The parts are
+// compared to the files by their checksum, so that only the files that
+// differ are read again.
+func (z *Zip) keepSource(zr *zip.Reader) error {
+	parts, err := z.parts()
+	if err != nil {
+		return err
+	}
+	byKey := make(map[string]part, len(parts))
+	for _, p := range parts {
+		byKey[p.key] = p
+	}
+
+	src := &source{uuid: z.UUID}
+	for _, file := range zr.File {
+		e := sourceEntry{
+			header: zip.FileHeader{
+				Name:     file.Name,
+				Comment:  file.Comment,
+				Method:   file.Method,
+				Modified: file.Modified,
+				// kept for the archives without extended timestamps
+				ModifiedTime: file.ModifiedTime,
+				ModifiedDate: file.ModifiedDate,
+			},
+		}
+
+		p, ok := byKey[z.sourceKey(file.Name)]
+		if ok {
+			e.key = p.key
+			e.sum = sha256.Sum256(p.data)
+		}
+		if !ok || uint64(len(p.data)) != file.UncompressedSize64 || crc32.ChecksumIEEE(p.data) != file.CRC32 {
+			if e.data, err = readFile(file); err != nil {
+				return err
+			}
+		}
+		src.entries = append(src.entries, e)
+	}
+
+	z.source = src
+	return nil
+}
+
+// sourceKey returns the key of the part a file of the archive
+// is read into, it mirrors the names given by parts.
+func (z *Zip) sourceKey(name string) string {
+	kind, idx := z.fileKind(name)
+	if idx < 0 {
+		return kind
+	}
+	return fmt.Sprintf("%s:%d", kind, z.Pages[idx].ref)
+}
+
+// fileKind tells what a file of the archive holds, and the index of its page
+// for the files of a page. The kind is empty for files that aren't
+// handled by this package, and the index is -1 for the others.
+func (z *Zip) fileKind(name string) (string, int) {
+	kind, page := z.fileType(name)
+	switch kind {
+	case "rm", "metadata", "thumbnail", "highlights":
+		idx, err := z.pageIndex(page)
+		if err != nil {
+			return "", -1
+		}
+		return kind, idx
+	}
+	return kind, -1
+}
+
+// fileType tells what a file of the archive holds from its name, and the
+// name of its page for the files of a page, whether the page exists or not.
+func (z *Zip) fileType(name string) (kind, page string) {
+	dir, base := path.Split(name)
+	stem, ext := splitExt(base)
+
+	switch {
+	case ext == ".content" && dir == "":
+		return "content", ""
+	case ext == ".pagedata" && dir == "":
+		return "pagedata", ""
+	case z.Content.FileType != "" && name == z.UUID+"."+z.Content.FileType:
+		return "payload", ""
+	case ext == ".rm":
+		return "rm", stem
+	case ext == ".json" && strings.HasSuffix(stem, "-metadata"):
+		return "metadata", pageFileName(name)
+	case ext == ".jpg" && strings.Contains(dir, "thumbnails"):
+		return "thumbnail", stem
+	case ext == ".json" && strings.Contains(dir, "highlights"):
+		return "highlights", stem
+	}
+	return "", ""
+}
+
+// pageFileName returns the name of the page a file of the archive belongs to.
+func pageFileName(name string) string {
+	stem, _ := splitExt(path.Base(name))
+	return strings.TrimSuffix(stem, "-metadata")
+}
@@ -0,0 +1,315 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/juruen/rmapi/log"
+	"github.com/juruen/rmapi/render"
+	"github.com/juruen/rmapi/util"
+	"github.com/nfnt/resize"
+	pdfmodel "github.com/unidoc/unipdf/v3/model"
+	pdfrender "github.com/unidoc/unipdf/v3/render"
+)
+
+// Size of the thumbnails shown by the tablet.
+const (
+	thumbnailWidth  = 280
+	thumbnailHeight = 374
+)
+
+// unipdf can hang or exhaust the memory on some files: the rendering of
+// a pdf page is given up after pdfRenderTimeout, and the pages larger than
+// maxPdfPageSize, in points, aren't rendered as they are rendered at 72 dpi.
+const (
+	pdfRenderTimeout = 10 * time.Second
+	maxPdfPageSize   = 3500
+)
+
+var errTimeout = errors.New("timed out")
+
+// ThumbnailsEnabled tells if thumbnails are generated on upload,
+// they can be disabled with RMAPI_THUMBNAILS=0.
+func ThumbnailsEnabled() bool {
+	return os.Getenv("RMAPI_THUMBNAILS") != "0"
+}
+
+// withTimeout runs f, recovering from its panics, and gives up after
+// timeout, f being left running in the background.
+func withTimeout(timeout time.Duration, f func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- util.Safely(f)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return errTimeout
+	}
+}
+
+// MakeThumbnails generates the thumbnails that are missing: the ones of
+// the pages of a pdf, the cover of an epub and the first page of a notebook.
+// A thumbnail that can't be generated is skipped with a warning.
+func (z *Zip) MakeThumbnails() {
+	if len(z.Pages) == 0 {
+		return
+	}
+
+	var thumbnails [][]byte
+	switch z.Content.FileType {
+	case "notebook":
+		if z.Pages[0].Data != nil {
+			thumbnails = makeThumbnails("rm", nil, z.Pages[0].Data)
+		}
+	default:
+		thumbnails = makeThumbnails(z.Content.FileType, z.Payload, nil)
+	}
+
+	for idx, thumbnail := range thumbnails {
+		if idx < len(z.Pages) && z.Pages[idx].Thumbnail == nil {
+			z.Pages[idx].Thumbnail = thumbnail
+		}
+	}
+}
+
+// makeThumbnails returns the thumbnails of the pages of a document, nil for
+// the pages that couldn't be rendered. A notebook page is passed decoded,
+// or as the .rm file in doc.
+func makeThumbnails(fileType string, doc []byte, page *rm.Rm) [][]byte {
+	var thumbnails [][]byte
+	err := util.Safely(func() error {
+		switch fileType {
+		case "pdf":
+			var err error
+			thumbnails, err = pdfThumbnails(doc)
+			return err
+		case "epub":
+			thumbnail, err := epubThumbnail(doc)
+			thumbnails = [][]byte{thumbnail}
+			return err
+		case "rm":
+			if page == nil {
+				page = rm.New()
+				d := rm.NewDecoder(bytes.NewReader(doc))
+				d.Lenient = true
+				if err := d.Decode(page); err != nil {
+					log.Warning.Printf("thumbnail of a corrupted page: %v", err)
+				}
+			}
+			thumbnail, err := encodeThumbnail(render.Raster(page, render.RasterOptions{DPI: render.DeviceDPI / 2}))
+			thumbnails = [][]byte{thumbnail}
+			return err
+		}
+		return nil
+	})
+
+	if err != nil {
+		log.Warning.Println("cannot generate thumbnail", err)
+		return nil
+	}
+	return thumbnails
+}
+
+// pdfThumbnails renders the thumbnails of the pages of a pdf. As a page
+// that timed out is still being rendered, the next ones are skipped.
+func pdfThumbnails(pdf []byte) ([][]byte, error) {
+	var reader *pdfmodel.PdfReader
+	var count int
+	err := withTimeout(pdfRenderTimeout, func() error {
+		r, err := pdfmodel.NewPdfReader(bytes.NewReader(pdf))
+		if err != nil {
+			return err
+		}
+		n, err := r.GetNumPages()
+		if err != nil {
+			return err
+		}
+		reader, count = r, n
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	device := pdfrender.NewImageDevice()
+	thumbnails := make([][]byte, count)
+	for i := range thumbnails {
+		var thumbnail []byte
+		err := withTimeout(pdfRenderTimeout, func() error {
+			page, err := reader.GetPage(i + 1)
+			if err != nil {
+				return err
+			}
+			box, err := page.GetMediaBox()
+			if err != nil {
+				return err
+			}
+			if box.Width() > maxPdfPageSize || box.Height() > maxPdfPageSize {
+				return fmt.Errorf("page too large, %.0fx%.0f points", box.Width(), box.Height())
+			}
+
+			img, err := device.Render(page)
+			if err != nil {
+				return err
+			}
+
+			thumbnail, err = encodeThumbnail(img)
+			return err
+		})
+		if err == errTimeout {
+			log.Warning.Printf("cannot generate the thumbnails of pages %d to %d: %v", i+1, count, err)
+			break
+		}
+		if err != nil {
+			log.Warning.Printf("cannot generate the thumbnail of page %d: %v", i+1, err)
+			continue
+		}
+		thumbnails[i] = thumbnail
+	}
+
+	return thumbnails, nil
+}
+
+// epubThumbnail makes a thumbnail of the cover image of an epub.
+func epubThumbnail(epub []byte) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(epub), int64(len(epub)))
+	if err != nil {
+		return nil, err
+	}
+
+	var container struct {
+		Rootfiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := readEpubXML(zr, "META-INF/container.xml", &container); err != nil {
+		return nil, err
+	}
+	if len(container.Rootfiles) == 0 {
+		return nil, errors.New("epub without package file")
+	}
+	opfPath := container.Rootfiles[0].FullPath
+
+	var opf epubPackage
+	if err := readEpubXML(zr, opfPath, &opf); err != nil {
+		return nil, err
+	}
+
+	href := opf.cover()
+	if href == "" {
+		return nil, errors.New("epub without cover")
+	}
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+
+	file := findEpubFile(zr, path.Join(path.Dir(opfPath), href))
+	if file == nil {
+		return nil, fmt.Errorf("epub cover %s not found", href)
+	}
+	data, err := readFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return encodeThumbnail(img)
+}
+
+// epubPackage is the part of the package file (.opf) of an epub
+// that tells which image is the cover.
+type epubPackage struct {
+	Metas []struct {
+		Name    string `xml:"name,attr"`
+		Content string `xml:"content,attr"`
+	} `xml:"metadata>meta"`
+	Items []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+}
+
+// cover returns the path of the cover image, relative to the package file.
+// It is flagged as such in epub 3, and given by a meta in epub 2.
+func (p *epubPackage) cover() string {
+	for _, item := range p.Items {
+		for _, property := range strings.Fields(item.Properties) {
+			if property == "cover-image" {
+				return item.Href
+			}
+		}
+	}
+
+	for _, meta := range p.Metas {
+		if meta.Name != "cover" {
+			continue
+		}
+		for _, item := range p.Items {
+			if item.ID == meta.Content {
+				return item.Href
+			}
+		}
+	}
+
+	// fallback on an image named after the cover
+	for _, item := range p.Items {
+		if strings.HasPrefix(item.MediaType, "image/") && strings.Contains(strings.ToLower(item.ID+item.Href), "cover") {
+			return item.Href
+		}
+	}
+	return ""
+}
+
+func findEpubFile(zr *zip.Reader, name string) *zip.File {
+	for _, file := range zr.File {
+		if file.Name == name {
+			return file
+		}
+	}
+	return nil
+}
+
+func readEpubXML(zr *zip.Reader, name string, v interface{}) error {
+	file := findEpubFile(zr, name)
+	if file == nil {
+		return fmt.Errorf("epub without %s", name)
+	}
+
+	data, err := readFile(file)
+	if err != nil {
+		return err
+	}
+	return xml.Unmarshal(data, v)
+}
+
+// encodeThumbnail resizes an image to the size of a thumbnail.
+func encodeThumbnail(img image.Image) ([]byte, error) {
+	thumbnail := resize.Resize(thumbnailWidth, thumbnailHeight, img, resize.Lanczos3)
+	out := &bytes.Buffer{}
+	if err := jpeg.Encode(out, thumbnail, nil); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
@@ -0,0 +1,181 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/juruen/rmapi/log"
+	"github.com/phpdave/gofpdf"
+)
+
+// checkThumbnail decodes a thumbnail and returns the color of its center.
+func checkThumbnail(t *testing.T, thumbnail []byte) color.Color {
+	t.Helper()
+
+	img, err := jpeg.Decode(bytes.NewReader(thumbnail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != thumbnailWidth || b.Dy() != thumbnailHeight {
+		t.Fatalf("wrong thumbnail size %v", b)
+	}
+	return img.At(thumbnailWidth/2, thumbnailHeight/2)
+}
+
+func isDark(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r+g+b < 3*0x8000
+}
+
+func TestNotebookThumbnail(t *testing.T) {
+	// a thick stroke across the middle of the page
+	var segments []rm.Segment
+	for y := 0; y < rm.Height; y += 10 {
+		segments = append(segments, rm.Segment{X: float32(rm.Width) / 2, Y: float32(y), Width: 60, Pressure: 1})
+	}
+	z := NewNotebook()
+	z.AddPage()
+	z.SetData(0, &rm.Rm{Version: rm.V5, Layers: []rm.Layer{{Strokes: []rm.Stroke{
+		{BrushType: rm.FinelinerV5, BrushColor: rm.Black, BrushSize: rm.Large, Segments: segments},
+	}}}})
+	z.AddPage()
+
+	z.MakeThumbnails()
+	if !isDark(checkThumbnail(t, z.Pages[0].Thumbnail)) {
+		t.Error("the stroke should be drawn")
+	}
+	if z.Pages[1].Thumbnail != nil {
+		t.Error("only the first page of a notebook has a thumbnail")
+	}
+}
+
+func TestPdfThumbnails(t *testing.T) {
+	log.InitLog()
+
+	pdf, err := ioutil.ReadFile("zipdoc_test.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	thumbnails := makeThumbnails("pdf", pdf, nil)
+	if len(thumbnails) != 1 {
+		t.Fatalf("got %d thumbnails, want 1", len(thumbnails))
+	}
+	checkThumbnail(t, thumbnails[0])
+
+	if thumbnails := makeThumbnails("pdf", []byte("%PDF-1.4 broken"), nil); thumbnails != nil {
+		t.Error("no thumbnail expected for a broken pdf")
+	}
+}
+
+func TestPdfThumbnailsLargePage(t *testing.T) {
+	log.InitLog()
+
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{UnitStr: "pt"})
+	pdf.AddPageFormat("P", gofpdf.SizeType{Wd: 595, Ht: 842})
+	pdf.AddPageFormat("P", gofpdf.SizeType{Wd: 595, Ht: maxPdfPageSize + 1})
+	pdf.AddPageFormat("P", gofpdf.SizeType{Wd: 595, Ht: 842})
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	thumbnails := makeThumbnails("pdf", buf.Bytes(), nil)
+	if len(thumbnails) != 3 || thumbnails[0] == nil || thumbnails[2] == nil {
+		t.Fatalf("every page but the large one should get a thumbnail, got %d", len(thumbnails))
+	}
+	if thumbnails[1] != nil {
+		t.Error("the large page shouldn't be rendered")
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	if err := withTimeout(time.Second, func() error { return nil }); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := withTimeout(time.Second, func() error { panic("boom") }); err == nil {
+		t.Error("the panic should be an error")
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	err := withTimeout(10*time.Millisecond, func() error {
+		<-release
+		return nil
+	})
+	if err != errTimeout {
+		t.Errorf("got %v, want a timeout", err)
+	}
+}
+
+// makeEpub builds an epub whose cover is a black image, the package file
+// being described by opf.
+func makeEpub(t *testing.T, opf string) []byte {
+	cover := image.NewGray(image.Rect(0, 0, 60, 80))
+	var img bytes.Buffer
+	if err := png.Encode(&img, cover); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for name, data := range map[string][]byte{
+		"mimetype": []byte("application/epub+zip"),
+		"META-INF/container.xml": []byte(`<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>`),
+		"OEBPS/content.opf":        []byte(opf),
+		"OEBPS/images/cover 1.png": img.Bytes(),
+	} {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(data)
+	}
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestEpubThumbnail(t *testing.T) {
+	log.InitLog()
+
+	for name, opf := range map[string]string{
+		"epub3": `<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <manifest>
+    <item id="img" href="images/cover%201.png" media-type="image/png" properties="cover-image"/>
+  </manifest>
+</package>`,
+		"epub2": `<package xmlns="http://www.idpf.org/2007/opf" version="2.0">
+  <metadata><meta name="cover" content="img"/></metadata>
+  <manifest>
+    <item id="img" href="images/cover 1.png" media-type="image/png"/>
+  </manifest>
+</package>`,
+	} {
+		thumbnails := makeThumbnails("epub", makeEpub(t, opf), nil)
+		if len(thumbnails) != 1 || thumbnails[0] == nil {
+			t.Errorf("%s: cover not found", name)
+			continue
+		}
+		if !isDark(checkThumbnail(t, thumbnails[0])) {
+			t.Errorf("%s: wrong cover", name)
+		}
+	}
+
+	epub := makeEpub(t, `<package><manifest></manifest></package>`)
+	if thumbnails := makeThumbnails("epub", epub, nil); thumbnails != nil {
+		t.Error("no thumbnail expected for an epub without cover")
+	}
+}
@@ -0,0 +1,214 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/juruen/rmapi/util"
+	pdfmodel "github.com/unidoc/unipdf/v3/model"
+)
+
+// A Problem is an inconsistency of an archive that may prevent
+// the tablet from opening it.
+type Problem struct {
+	// File is the file of the archive the problem was found in, if any.
+	File string
+	// Page is the index of the page the problem concerns, -1 if none.
+	Page    int
+	Message string
+}
+
+func (p Problem) String() string {
+	s := p.Message
+	if p.Page >= 0 {
+		s = fmt.Sprintf("page %d: %s", p.Page+1, s)
+	}
+	if p.File != "" {
+		s = fmt.Sprintf("%s: %s", p.File, s)
+	}
+	return s
+}
+
+// ValidateArchive reads an archive file and validates it. An archive
+// that can't be read is reported as a problem, the error being kept
+// for the ones that aren't zip files.
+func ValidateArchive(r io.ReaderAt, size int64) ([]Problem, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read fails on a missing or duplicated file
+	names := make([]string, len(zr.File))
+	for i, file := range zr.File {
+		names[i] = file.Name
+	}
+	counted := validateFileCounts(names)
+
+	z := NewZip()
+	if err := z.Read(r, size); err != nil {
+		if len(counted) > 0 {
+			return counted, nil
+		}
+		return []Problem{{Page: -1, Message: fmt.Sprintf("can't read the archive: %v", err)}}, nil
+	}
+
+	return Validate(z), nil
+}
+
+// validateFileCounts checks that the files of an archive, given
+// by their names, hold a single .content and .pagedata file.
+func validateFileCounts(names []string) []Problem {
+	counts := make(map[string]int)
+	for _, name := range names {
+		_, ext := splitExt(path.Base(name))
+		counts[ext]++
+	}
+
+	var problems []Problem
+	for _, ext := range []string{".content", ".pagedata"} {
+		switch {
+		case counts[ext] == 0:
+			problems = append(problems, Problem{Page: -1, Message: fmt.Sprintf("missing %s file", ext)})
+		case counts[ext] > 1:
+			problems = append(problems, Problem{Page: -1, Message: fmt.Sprintf("%d %s files instead of one", counts[ext], ext)})
+		}
+	}
+	return problems
+}
+
+// Validate checks that a Zip is consistent with what the tablet expects.
+// The files of an archive filled by Read are checked as well.
+func Validate(z *Zip) []Problem {
+	var problems []Problem
+	add := func(file string, page int, format string, args ...interface{}) {
+		problems = append(problems, Problem{File: file, Page: page, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if z.UUID == "" && z.source != nil {
+		add("", -1, "the content file isn't named after the document id")
+	}
+
+	switch z.Content.FileType {
+	case "pdf", "epub":
+		if z.Payload == nil {
+			add("", -1, "missing %s file", z.Content.FileType)
+		}
+	// older notebooks have no file type
+	case "notebook", "":
+	default:
+		add("", -1, "unknown file type %q", z.Content.FileType)
+	}
+
+	// the page list gives the number of pages of a notebook
+	ids := z.Content.PageIDs()
+	if len(ids) > 0 && z.Content.PageCount != len(ids) && z.Content.FileType != "pdf" && z.Content.FileType != "epub" {
+		add("", -1, "page count %d doesn't match the %d pages listed", z.Content.PageCount, len(ids))
+	}
+	// the pdf gives the one of a pdf document, but for the pages inserted
+	// in it, while the pages of an epub depend on how it is laid out
+	if z.Content.FileType == "pdf" && z.Payload != nil && z.Content.PageCount > 0 {
+		expected := z.Content.PageCount
+		if z.Content.OriginalPageCount != nil {
+			expected = *z.Content.OriginalPageCount
+		}
+		count, err := pdfPageCount(z.Payload)
+		switch {
+		case err != nil:
+			add("", -1, "can't read the pdf file: %v", err)
+		case count != expected:
+			add("", -1, "the pdf file has %d pages, expected %d", count, expected)
+		}
+	}
+	seen := make(map[string]bool)
+	for idx, id := range ids {
+		if seen[id] {
+			add("", idx, "page id %s listed twice", id)
+		}
+		seen[id] = true
+	}
+
+	for idx, page := range z.Pages {
+		if page.Data == nil || len(page.Metadata.Layers) == 0 {
+			continue
+		}
+		if len(page.Metadata.Layers) != len(page.Data.Layers) {
+			add("", idx, "%d layers in the metadata but %d in the drawing", len(page.Metadata.Layers), len(page.Data.Layers))
+		}
+	}
+
+	if z.source != nil {
+		problems = append(problems, z.validateFiles()...)
+	}
+
+	return problems
+}
+
+// validateFiles checks the files of an archive that was read.
+func (z *Zip) validateFiles() []Problem {
+	var problems []Problem
+	add := func(file string, page int, format string, args ...interface{}) {
+		problems = append(problems, Problem{File: file, Page: page, Message: fmt.Sprintf(format, args...)})
+	}
+
+	names := make([]string, len(z.source.entries))
+	for i, e := range z.source.entries {
+		names[i] = e.header.Name
+	}
+	problems = append(problems, validateFileCounts(names)...)
+
+	for _, e := range z.source.entries {
+		name := e.header.Name
+
+		// files of the document are named after it
+		if z.UUID != "" && !namedAfter(name, z.UUID) {
+			add(name, -1, "file not named after the document id %s", z.UUID)
+		}
+
+		// the files of a page that isn't in the document are skipped by Read
+		kind, idx := z.fileKind(name)
+		if k, page := z.fileType(name); kind == "" && k != "" {
+			add(name, -1, "%s file of the unknown page %s", k, page)
+		}
+
+		// the parts that aren't kept are written back as they were read
+		if e.data == nil {
+			continue
+		}
+		switch kind {
+		case "rm":
+			// pages are decoded leniently by Read
+			if err := rm.NewDecoder(bytes.NewReader(e.data)).Decode(rm.New()); err != nil {
+				add(name, idx, "can't decode the drawing: %v", err)
+			}
+		case "pagedata":
+			lines := bytes.Count(e.data, []byte("\n"))
+			if len(e.data) > 0 && !bytes.HasSuffix(e.data, []byte("\n")) {
+				lines++
+			}
+			if lines > len(z.Pages) {
+				add(name, -1, "%d templates for %d pages", lines, len(z.Pages))
+			}
+		}
+	}
+
+	return problems
+}
+
+// pdfPageCount returns the number of pages of a pdf.
+func pdfPageCount(pdf []byte) (int, error) {
+	var count int
+	err := util.Safely(func() error {
+		reader, err := pdfmodel.NewPdfReader(bytes.NewReader(pdf))
+		if err != nil {
+			return err
+		}
+		count, err = reader.GetNumPages()
+		return err
+	})
+	return count, err
+}
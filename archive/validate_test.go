@@ -0,0 +1,135 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/juruen/rmapi/log"
+)
+
+func TestValidate(t *testing.T) {
+	file, err := os.Open("test.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := ValidateArchive(file, fi.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) > 0 {
+		t.Errorf("test.zip: unexpected problems %v", problems)
+	}
+
+	data := fixture(t)
+	if problems, _ := ValidateArchive(bytes.NewReader(data), int64(len(data))); len(problems) > 0 {
+		t.Errorf("fixture: unexpected problems %v", problems)
+	}
+
+	z := NewNotebook()
+	z.SetLayerNames(z.AddPage(), "A", "B")
+	if problems := Validate(z); len(problems) > 0 {
+		t.Errorf("notebook: unexpected problems %v", problems)
+	}
+}
+
+func TestValidateProblems(t *testing.T) {
+	log.InitLog()
+
+	page, err := (&rm.Rm{Version: rm.V5, Layers: make([]rm.Layer, 2)}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	z := readFiles(t, map[string][]byte{
+		"doc.content":            []byte(`{"fileType": "pdf", "pageCount": 3, "pages": ["a", "b", "a"]}`),
+		"doc.pagedata":           []byte("Blank\nBlank\nBlank\nBlank\n"),
+		"doc/a.rm":               page,
+		"doc/a-metadata.json":    []byte(`{"layers": [{"name": "Layer 1"}]}`),
+		"doc/b.rm":               page[:len(page)-3],
+		"doc/c.rm":               page,
+		"doc/c-metadata.json":    []byte(`{"layers": [{"name": "Layer 1"}]}`),
+		"other.thumbnails/0.jpg": []byte("jpg"),
+	})
+
+	want := []string{
+		"missing pdf file",
+		"page 3: page id a listed twice",
+		"page 1: 1 layers in the metadata but 2 in the drawing",
+		"doc/b.rm: page 2: can't decode the drawing",
+		"doc.pagedata: 4 templates for 3 pages",
+		"doc/c.rm: rm file of the unknown page c",
+		"doc/c-metadata.json: metadata file of the unknown page c",
+		"other.thumbnails/0.jpg: file not named after the document id doc",
+	}
+	problems := Validate(z)
+	for _, w := range want {
+		found := false
+		for _, p := range problems {
+			found = found || strings.HasPrefix(p.String(), w)
+		}
+		if !found {
+			t.Errorf("problem %q not found in %v", w, problems)
+		}
+	}
+	if len(problems) != len(want) {
+		t.Errorf("got %d problems, want %d: %v", len(problems), len(want), problems)
+	}
+}
+
+func TestValidateUnreadable(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for _, name := range []string{"a.content", "b.content"} {
+		f, _ := w.Create(name)
+		f.Write([]byte("{}"))
+	}
+	w.Close()
+
+	problems, err := ValidateArchive(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	want := []string{"2 .content files instead of one", "missing .pagedata file"}
+	if err != nil || len(problems) != len(want) || problems[0].String() != want[0] || problems[1].String() != want[1] {
+		t.Errorf("got %v %v, want %v", problems, err, want)
+	}
+
+	if _, err := ValidateArchive(strings.NewReader("not a zip"), 9); err == nil {
+		t.Error("expected an error for a file that isn't a zip")
+	}
+}
+
+func TestValidatePdfPageCount(t *testing.T) {
+	pdf, err := ioutil.ReadFile("zipdoc_test.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for content, want := range map[string]string{
+		`{"fileType": "pdf", "pageCount": 1}`:                         "",
+		`{"fileType": "pdf", "pageCount": 2}`:                         "the pdf file has 1 pages, expected 2",
+		`{"fileType": "pdf", "pageCount": 3, "originalPageCount": 1}`: "",
+		`{"fileType": "pdf", "pageCount": 3, "originalPageCount": 2}`: "the pdf file has 1 pages, expected 2",
+	} {
+		z := readFiles(t, map[string][]byte{
+			"doc.content":  []byte(content),
+			"doc.pdf":      pdf,
+			"doc.pagedata": []byte("Blank\n"),
+		})
+		var got []string
+		for _, p := range Validate(z) {
+			got = append(got, p.String())
+		}
+		if (want == "" && len(got) > 0) || (want != "" && (len(got) != 1 || got[0] != want)) {
+			t.Errorf("%s: got problems %v, want %q", content, got, want)
+		}
+	}
+}
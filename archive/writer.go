@@ -2,11 +2,14 @@ package archive
 
 import (
 	"archive/zip"
-	"bufio"
+	"bytes"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,220 +17,229 @@ import (
 
 // Write writes an archive file from a Zip struct.
 // It automatically generates a uuid if not already
-// defined in the struct.
+// defined in the struct, as well as the missing page IDs.
+//
+// A Zip filled by Read is written back losslessly: files that
+// aren't handled by this package are copied verbatim, and the
+// parts of the archive that weren't modified keep their name
+// and their original bytes. Setting a new UUID renames them.
 func (z *Zip) Write(w io.Writer) error {
 	// generate random uuid if not defined
 	if z.UUID == "" {
 		z.UUID = uuid.New().String()
 	}
+	z.syncPages(false)
 
-	archive := zip.NewWriter(w)
-
-	if err := z.writeContent(archive); err != nil {
+	parts, err := z.parts()
+	if err != nil {
 		return err
 	}
 
-	if err := z.writePayload(archive); err != nil {
-		return err
-	}
+	archive := zip.NewWriter(w)
 
-	if err := z.writePagedata(archive); err != nil {
-		return err
-	}
+	written := make(map[string]bool)
+	if z.source != nil {
+		byKey := make(map[string]part, len(parts))
+		for _, p := range parts {
+			byKey[p.key] = p
+		}
 
-	if err := z.writeThumbnails(archive); err != nil {
-		return err
+		for _, e := range z.source.entries {
+			// files are named after the document
+			if namedAfter(e.header.Name, z.source.uuid) {
+				e.header.Name = z.UUID + strings.TrimPrefix(e.header.Name, z.source.uuid)
+			}
+
+			data := e.data
+			if e.key != "" {
+				p, ok := byKey[e.key]
+				// the part was removed, or found twice
+				if !ok || written[e.key] {
+					continue
+				}
+				if e.data == nil || sha256.Sum256(p.data) != e.sum {
+					data = p.data
+				}
+				written[e.key] = true
+			}
+
+			if err := writeEntry(archive, e.header, data); err != nil {
+				return err
+			}
+		}
 	}
 
-	if err := z.writeMetadata(archive); err != nil {
-		return err
-	}
+	for _, p := range parts {
+		if written[p.key] {
+			continue
+		}
 
-	if err := z.writeData(archive); err != nil {
-		return err
+		if err := writeEntry(archive, newHeader(p.name), p.data); err != nil {
+			return err
+		}
 	}
 
-	archive.Close()
-
-	return nil
+	return archive.Close()
 }
 
-// writeContent writes the .content file to the archive.
-func (z *Zip) writeContent(zw *zip.Writer) error {
-	bytes, err := json.MarshalIndent(&z.Content, "", "    ")
-	if err != nil {
-		return err
-	}
-
-	name := fmt.Sprintf("%s.content", z.UUID)
-
-	w, err := addToZip(zw, name)
-	if err != nil {
-		return err
-	}
-
-	if _, err := w.Write(bytes); err != nil {
-		return err
-	}
-
-	return nil
+// A part is a file of the archive handled by this package, the key
+// identifying it whatever its name.
+type part struct {
+	key  string
+	name string
+	data []byte
 }
 
-// writePdf writes a pdf file to the archive if existing in the struct.
-func (z *Zip) writePayload(zw *zip.Writer) error {
-	// skip if no pdf
-	if z.Payload == nil {
-		return nil
-	}
-
-	name := fmt.Sprintf("%s.%s", z.UUID, z.Content.FileType)
+// parts returns the files of the archive in the order they are written,
+// named as they are in newer archives.
+func (z *Zip) parts() ([]part, error) {
+	var parts []part
 
-	w, err := addToZip(zw, name)
+	content, err := z.contentPart()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	parts = append(parts, content)
 
-	if _, err := w.Write(z.Payload); err != nil {
-		return err
+	// skip if no pdf
+	if z.Payload != nil {
+		parts = append(parts, part{"payload", fmt.Sprintf("%s.%s", z.UUID, z.Content.FileType), z.Payload})
 	}
 
-	return nil
-}
-
-// writePagedata writes a .pagedata file containing
-// the name of background templates for each page (one per line).
-func (z *Zip) writePagedata(zw *zip.Writer) error {
 	// don't add pagedata file if no pages
-	if len(z.Pages) == 0 {
-		return nil
+	if len(z.Pages) > 0 {
+		parts = append(parts, part{"pagedata", fmt.Sprintf("%s.pagedata", z.UUID), z.pagedata()})
 	}
 
-	name := fmt.Sprintf("%s.pagedata", z.UUID)
-
-	w, err := addToZip(zw, name)
-	if err != nil {
-		return err
+	for idx, page := range z.Pages {
+		if page.Thumbnail == nil {
+			continue
+		}
+		folder := fmt.Sprintf("%s.thumbnails", z.UUID)
+		name := fmt.Sprintf("%s.jpg", z.pageName(idx))
+		parts = append(parts, part{z.pageKey("thumbnail", idx), filepath.Join(folder, name), page.Thumbnail})
 	}
 
-	bw := bufio.NewWriter(w)
-	for _, page := range z.Pages {
-		template := page.Pagedata
+	for idx, page := range z.Pages {
+		// if no layers available, don't write the metadata file
+		if len(page.Metadata.Layers) == 0 {
+			continue
+		}
 
-		// set default if empty
-		if template == "" {
-			template = defaultPagadata
+		bytes, err := json.MarshalIndent(&page.Metadata, "", "    ")
+		if err != nil {
+			return nil, err
 		}
 
-		bw.WriteString(template + "\n")
+		name := fmt.Sprintf("%s-metadata.json", z.pageName(idx))
+		parts = append(parts, part{z.pageKey("metadata", idx), filepath.Join(z.UUID, name), bytes})
 	}
 
-	// write to the underlying io.Writer
-	bw.Flush()
-
-	return nil
-}
-
-// writeThumbnails writes thumbnail files for each page
-// in the archive.
-func (z *Zip) writeThumbnails(zw *zip.Writer) error {
 	for idx, page := range z.Pages {
-		if page.Thumbnail == nil {
+		if page.Data == nil {
 			continue
 		}
 
-		folder := fmt.Sprintf("%s.thumbnail", z.UUID)
-		name := fmt.Sprintf("%d.jpg", idx)
-		fn := filepath.Join(folder, name)
-
-		w, err := addToZip(zw, fn)
+		bytes, err := page.Data.MarshalBinary()
 		if err != nil {
-			return err
-		}
-
-		if _, err := w.Write(page.Thumbnail); err != nil {
-			return err
+			return nil, err
 		}
 
+		name := fmt.Sprintf("%s.rm", z.pageName(idx))
+		parts = append(parts, part{z.pageKey("rm", idx), filepath.Join(z.UUID, name), bytes})
 	}
 
-	return nil
-}
-
-// writeMetadata writes .json metadata files for each page
-// in the archive.
-func (z *Zip) writeMetadata(zw *zip.Writer) error {
 	for idx, page := range z.Pages {
-		// if no layers available, don't write the metadata file
-		if len(page.Metadata.Layers) == 0 {
+		if len(page.Highlights.LayerHighlights) == 0 {
 			continue
 		}
 
-		name := fmt.Sprintf("%d-metadata.json", idx)
-		fn := filepath.Join(z.UUID, name)
-
-		w, err := addToZip(zw, fn)
+		bytes, err := json.MarshalIndent(&page.Highlights, "", "    ")
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		bytes, err := json.MarshalIndent(&page.Metadata, "", "    ")
-		if err != nil {
-			return err
-		}
+		folder := fmt.Sprintf("%s.highlights", z.UUID)
+		name := fmt.Sprintf("%s.json", z.pageName(idx))
+		parts = append(parts, part{z.pageKey("highlights", idx), filepath.Join(folder, name), bytes})
+	}
 
-		if _, err := w.Write(bytes); err != nil {
-			return err
-		}
+	return parts, nil
+}
 
+// contentPart returns the .content file.
+func (z *Zip) contentPart() (part, error) {
+	bytes, err := json.MarshalIndent(&z.Content, "", "    ")
+	if err != nil {
+		return part{}, err
 	}
 
-	return nil
+	return part{"content", fmt.Sprintf("%s.content", z.UUID), bytes}, nil
 }
 
-// writeData writes .rm data files for each page
-// in the archive.
-func (z *Zip) writeData(zw *zip.Writer) error {
-	for idx, page := range z.Pages {
-		if page.Data == nil {
-			continue
-		}
-
-		name := fmt.Sprintf("%d.rm", idx)
-		fn := filepath.Join(z.UUID, name)
+// pagedata returns a .pagedata file containing
+// the name of background templates for each page (one per line).
+func (z *Zip) pagedata() []byte {
+	var b bytes.Buffer
+	for _, page := range z.Pages {
+		template := page.Pagedata
 
-		w, err := addToZip(zw, fn)
-		if err != nil {
-			return err
+		// set default if empty
+		if template == "" {
+			template = defaultPagadata
 		}
 
-		bytes, err := page.Data.MarshalBinary()
-		if err != nil {
-			return err
-		}
+		b.WriteString(template + "\n")
+	}
+	return b.Bytes()
+}
 
-		if _, err := w.Write(bytes); err != nil {
-			return err
-		}
+// pageName returns the name of the files of a page: the one they had
+// in the archive that was read, the page ID or the page index.
+func (z *Zip) pageName(idx int) string {
+	if z.Pages[idx].name != "" {
+		return z.Pages[idx].name
+	}
+	if z.Pages[idx].UUID != "" {
+		return z.Pages[idx].UUID
+	}
+	return strconv.Itoa(idx)
+}
 
+// pageKey identifies a file of a page, pages that were read being
+// identified by their position at the time.
+func (z *Zip) pageKey(kind string, idx int) string {
+	if ref := z.Pages[idx].ref; ref > 0 {
+		return fmt.Sprintf("%s:%d", kind, ref)
 	}
+	return fmt.Sprintf("%s:new:%d", kind, idx)
+}
 
-	return nil
+// namedAfter tells if the name of a file of an archive
+// is made of the document id, followed by a folder or an extension.
+func namedAfter(name, uuid string) bool {
+	if uuid == "" || !strings.HasPrefix(name, uuid) || len(name) == len(uuid) {
+		return false
+	}
+	return name[len(uuid)] == '/' || name[len(uuid)] == '.'
 }
 
-// addToZip takes a zip.Writer in parameter and creates an io.Writer
-// to write the content of a file to add to the zip.
-func addToZip(zw *zip.Writer, name string) (io.Writer, error) {
-	h := &zip.FileHeader{
+func newHeader(name string) zip.FileHeader {
+	return zip.FileHeader{
 		Name:         name,
 		Method:       zip.Store,
 		ModifiedTime: uint16(time.Now().UnixNano()),
 		ModifiedDate: uint16(time.Now().UnixNano()),
 	}
+}
 
-	writer, err := zw.CreateHeader(h)
+// writeEntry adds a file to the zip.
+func writeEntry(zw *zip.Writer, header zip.FileHeader, data []byte) error {
+	w, err := zw.CreateHeader(&header)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return writer, nil
+	_, err = w.Write(data)
+	return err
 }
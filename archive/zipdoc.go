@@ -5,42 +5,16 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"image/jpeg"
 	"io/ioutil"
 	"os"
+	"strconv"
 
+	"github.com/juruen/rmapi/convert"
 	"github.com/juruen/rmapi/log"
 	"github.com/juruen/rmapi/util"
-	"github.com/nfnt/resize"
 	uuid "github.com/satori/go.uuid"
-	pdfmodel "github.com/unidoc/unipdf/v3/model"
-	"github.com/unidoc/unipdf/v3/render"
 )
 
-func makeThumbnail(pdf []byte) ([]byte, error) {
-	reader, err := pdfmodel.NewPdfReader(bytes.NewReader(pdf))
-	if err != nil {
-		return nil, err
-	}
-	page, err := reader.GetPage(1)
-	if err != nil {
-		return nil, err
-	}
-
-	device := render.NewImageDevice()
-
-	image, err := device.Render(page)
-	if err != nil {
-		return nil, err
-	}
-
-	thumbnail := resize.Resize(280, 374, image, resize.Lanczos3)
-	out := &bytes.Buffer{}
-	jpeg.Encode(out, thumbnail, nil)
-
-	return out.Bytes(), nil
-}
-
 // GetIdFromZip tries to get the Document UUID from an archive
 func GetIdFromZip(srcPath string) (id string, err error) {
 	file, err := os.Open(srcPath)
@@ -75,6 +49,17 @@ func CreateZipDocument(id, srcPath string) (zipPath string, err error) {
 		log.Error.Println("failed to open source document file to read", err)
 		return
 	}
+
+	// images and text files are uploaded as pdf
+	if convert.IsSupported(ext) {
+		var buf bytes.Buffer
+		if err = convert.ToPdf(&buf, srcPath); err != nil {
+			log.Error.Println("failed to convert document to pdf", err)
+			return
+		}
+		doc = buf.Bytes()
+		ext, fileType = "pdf", "pdf"
+	}
 	// Create document (pdf or epub) file
 	tmp, err := ioutil.TempFile("", "rmapizip")
 	if err != nil {
@@ -115,16 +100,20 @@ func CreateZipDocument(id, srcPath string) (zipPath string, err error) {
 	}
 	f.Write(doc)
 
-	//try to create a thumbnail
-	//due to a bug somewhere in unipdf the generation is opt-in
-	if ext == "pdf" && os.Getenv("RMAPI_THUMBNAILS") != "" {
-		thumbnail, err := makeThumbnail(doc)
-		if err != nil {
-			log.Error.Println("cannot generate thumbnail", err)
-		} else {
-			f, err := w.Create(fmt.Sprintf("%s.thumbnails/0.jpg", id))
+	// thumbnails are shown in the grid view of the tablet
+	if ThumbnailsEnabled() {
+		for i, thumbnail := range makeThumbnails(ext, doc, nil) {
+			if thumbnail == nil {
+				continue
+			}
+
+			name := strconv.Itoa(i)
+			if i < len(pages) {
+				name = pages[i]
+			}
+			f, err := w.Create(fmt.Sprintf("%s.thumbnails/%s.jpg", id, name))
 			if err != nil {
-				log.Error.Println("failed to create doc entry in zip file", err)
+				log.Error.Println("failed to create thumbnail entry in zip file", err)
 				return "", err
 			}
 			f.Write(thumbnail)
@@ -0,0 +1,134 @@
+// Package convert turns images and text files into pdf documents
+// sized for the tablet, so that they can be uploaded like any pdf.
+package convert
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/phpdave/gofpdf"
+)
+
+// PageSize is the size of the screen of the tablet, in points.
+var PageSize = gofpdf.SizeType{Wd: 1404 * 72 / 226.0, Ht: 1872 * 72 / 226.0}
+
+var imageExt = map[string]bool{
+	"png":  true,
+	"jpg":  true,
+	"jpeg": true,
+	"gif":  true,
+}
+
+var textExt = map[string]bool{
+	"txt":      true,
+	"md":       true,
+	"markdown": true,
+}
+
+// IsImage tells if files with the extension ext (without .) are images.
+func IsImage(ext string) bool {
+	return imageExt[strings.ToLower(ext)]
+}
+
+// IsSupported tells if files with the extension ext (without .) can be
+// converted to pdf.
+func IsSupported(ext string) bool {
+	ext = strings.ToLower(ext)
+	return imageExt[ext] || textExt[ext]
+}
+
+// ToPdf converts an image, a text file or a Markdown file to pdf.
+func ToPdf(w io.Writer, path string) error {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	if imageExt[ext] {
+		return ImagesToPdf(w, path)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch ext {
+	case "txt":
+		return TextToPdf(w, string(data))
+	case "md", "markdown":
+		return MarkdownToPdf(w, string(data))
+	}
+	return fmt.Errorf("unsupported file extension: %s", ext)
+}
+
+// newPdf creates a pdf whose pages have the size of the screen.
+func newPdf() *gofpdf.Fpdf {
+	return gofpdf.NewCustom(&gofpdf.InitType{UnitStr: "pt", Size: PageSize})
+}
+
+// ImagesToPdf makes a pdf with one page per image, such as the pages of
+// a scan. Images are scaled to fit the page.
+func ImagesToPdf(w io.Writer, paths ...string) error {
+	pdf := newPdf()
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+
+	for i, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		data, imageType, err := pdfImage(data)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+
+		name := fmt.Sprintf("image%d", i)
+		options := gofpdf.ImageOptions{ImageType: imageType}
+		info := pdf.RegisterImageOptionsReader(name, options, bytes.NewReader(data))
+		if err := pdf.Error(); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+
+		// fit the page, centered
+		iw, ih := info.Width(), info.Height()
+		scale := PageSize.Wd / iw
+		if PageSize.Ht/ih < scale {
+			scale = PageSize.Ht / ih
+		}
+		x, y := (PageSize.Wd-iw*scale)/2, (PageSize.Ht-ih*scale)/2
+
+		pdf.AddPage()
+		pdf.ImageOptions(name, x, y, iw*scale, ih*scale, false, options, 0, "")
+	}
+
+	return pdf.Output(w)
+}
+
+// pdfImage returns an image in a format that can be embedded in a pdf:
+// jpeg files are kept, other images are converted to 8 bits png.
+func pdfImage(data []byte) ([]byte, string, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if format == "jpeg" {
+		return data, "JPG", nil
+	}
+
+	nrgba := image.NewNRGBA(img.Bounds())
+	draw.Draw(nrgba, nrgba.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, nrgba); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "PNG", nil
+}
@@ -0,0 +1,172 @@
+package convert
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	pdfmodel "github.com/unidoc/unipdf/v3/model"
+)
+
+// pageCount returns the number of pages of a pdf.
+func pageCount(t *testing.T, pdf []byte) int {
+	reader, err := pdfmodel.NewPdfReader(bytes.NewReader(pdf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := reader.GetNumPages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func writeImage(t *testing.T, dir, name string, w, h int) string {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		img.Set(x, x%h, color.RGBA{R: 200, A: 255})
+	}
+
+	var buf bytes.Buffer
+	var err error
+	if filepath.Ext(name) == ".jpg" {
+		err = jpeg.Encode(&buf, img, nil)
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestImagesToPdf(t *testing.T) {
+	dir, err := ioutil.TempDir("", "convert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	images := []string{
+		writeImage(t, dir, "1.png", 300, 400),
+		writeImage(t, dir, "2.jpg", 800, 200),
+		writeImage(t, dir, "3.png", 10, 10),
+	}
+
+	var buf bytes.Buffer
+	if err := ImagesToPdf(&buf, images...); err != nil {
+		t.Fatal(err)
+	}
+	if count := pageCount(t, buf.Bytes()); count != len(images) {
+		t.Errorf("%d pages, expected %d", count, len(images))
+	}
+
+	buf.Reset()
+	if err := ToPdf(&buf, images[0]); err != nil {
+		t.Fatal(err)
+	}
+	if count := pageCount(t, buf.Bytes()); count != 1 {
+		t.Errorf("%d pages, expected 1", count)
+	}
+}
+
+func TestImagesToPdfInvalidImage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "convert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "bad.png")
+	if err := ioutil.WriteFile(path, []byte("not an image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ImagesToPdf(ioutil.Discard, path); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestTextToPdf(t *testing.T) {
+	var text bytes.Buffer
+	for i := 0; i < 200; i++ {
+		text.WriteString("a line of text\twith a tab, some accents: é à, and other scripts: Ελληνικά Кириллица\n")
+	}
+
+	var buf bytes.Buffer
+	if err := TextToPdf(&buf, text.String()); err != nil {
+		t.Fatal(err)
+	}
+	if count := pageCount(t, buf.Bytes()); count < 2 {
+		t.Errorf("%d pages, expected the text to span several pages", count)
+	}
+}
+
+func TestMarkdownToPdf(t *testing.T) {
+	markdown := `# Title
+
+A paragraph with **bold**, *italic*, ` + "`code`" + ` and a [link](https://example.com),
+continued on a second line.
+After a hard break.
+
+## Lists
+
+- first
+- second
+  - nested
+1. one
+2. two
+
+> a quote
+
+---
+
+` + "```" + `
+func main() {
+	fmt.Println("hello")
+}
+` + "```" + `
+`
+
+	var buf bytes.Buffer
+	if err := MarkdownToPdf(&buf, markdown); err != nil {
+		t.Fatal(err)
+	}
+	if count := pageCount(t, buf.Bytes()); count != 1 {
+		t.Errorf("%d pages, expected 1", count)
+	}
+}
+
+func TestParseInline(t *testing.T) {
+	tests := []struct {
+		text  string
+		spans []span
+	}{
+		{"plain", []span{{text: "plain"}}},
+		{"a **b** c", []span{{text: "a "}, {text: "b", bold: true}, {text: " c"}}},
+		{"*i* and _j_", []span{{text: "i", italic: true}, {text: " and "}, {text: "j", italic: true}}},
+		{"snake_case_name", []span{{text: "snake_case_name"}}},
+		{"run `go test`", []span{{text: "run "}, {text: "go test", code: true}}},
+		{"see [docs](http://x.y/z)!", []span{{text: "see "}, {text: "docs", link: "http://x.y/z"}, {text: "!"}}},
+		{`\*not italic\*`, []span{{text: "*not italic*"}}},
+		{"unclosed ` and [", []span{{text: "unclosed ` and ["}}},
+	}
+
+	for _, tt := range tests {
+		if spans := parseInline(tt.text); !reflect.DeepEqual(spans, tt.spans) {
+			t.Errorf("parseInline(%q) = %+v, expected %+v", tt.text, spans, tt.spans)
+		}
+	}
+}
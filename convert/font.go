@@ -0,0 +1,21 @@
+package convert
+
+import (
+	"github.com/juruen/rmapi/pdffont"
+	"github.com/phpdave/gofpdf"
+)
+
+// Fonts of the text files.
+const (
+	textFont = pdffont.Text
+	codeFont = pdffont.Code
+)
+
+// newTextPdf creates a pdf whose pages have the size of the screen,
+// with the fonts of the text files.
+func newTextPdf() *gofpdf.Fpdf {
+	pdf := newPdf()
+	pdffont.Add(pdf, textFont)
+	pdffont.Add(pdf, codeFont)
+	return pdf
+}
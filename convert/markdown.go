@@ -0,0 +1,313 @@
+package convert
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/juruen/rmapi/pdffont"
+	"github.com/phpdave/gofpdf"
+)
+
+const (
+	margin   = 36.0
+	fontSize = 11.0
+	// lineSpacing is the height of a line relative to the font size
+	lineSpacing = 1.35
+)
+
+// TextToPdf makes a pdf out of plain text, keeping its lines.
+func TextToPdf(w io.Writer, text string) error {
+	pdffont.WarnMissingGlyphs(text)
+
+	pdf := newTextPdf()
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetAutoPageBreak(true, margin)
+	pdf.SetFont(textFont, "", fontSize)
+
+	pdf.AddPage()
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		line = strings.Replace(line, "\t", "    ", -1)
+		pdf.MultiCell(0, fontSize*lineSpacing, line, "", "L", false)
+	}
+
+	return pdf.Output(w)
+}
+
+// MarkdownToPdf makes a pdf out of Markdown text. The common syntax is
+// supported: headings, paragraphs, lists, quotes, code blocks, horizontal
+// rules, emphasis, code spans and links.
+func MarkdownToPdf(w io.Writer, markdown string) error {
+	pdffont.WarnMissingGlyphs(markdown)
+
+	m := &mdWriter{pdf: newTextPdf()}
+	m.pdf.SetMargins(margin, margin, margin)
+	m.pdf.SetAutoPageBreak(true, margin)
+	m.pdf.AddPage()
+
+	markdown = strings.Replace(markdown, "\r\n", "\n", -1)
+	m.write(strings.Split(markdown, "\n"))
+
+	return m.pdf.Output(w)
+}
+
+var (
+	headingRe = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	ruleRe    = regexp.MustCompile(`^\s*([-*_])(\s*[-*_]){2,}\s*$`)
+	listRe    = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+(.*)$`)
+	quoteRe   = regexp.MustCompile(`^\s*>\s?(.*)$`)
+	fenceRe   = regexp.MustCompile("^\\s*(```|~~~)")
+)
+
+var headingSizes = []float64{20, 16, 14, 12, 11, 11}
+
+type mdWriter struct {
+	pdf *gofpdf.Fpdf
+	// paragraph holds the lines of the paragraph being read
+	paragraph []string
+}
+
+func (m *mdWriter) write(lines []string) {
+	inCode := false
+	for _, line := range lines {
+		if fenceRe.MatchString(line) {
+			m.flush()
+			inCode = !inCode
+			if !inCode {
+				m.pdf.Ln(fontSize / 2)
+			}
+			continue
+		}
+		if inCode {
+			m.code(line)
+			continue
+		}
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			m.flush()
+		case headingRe.MatchString(line):
+			m.flush()
+			match := headingRe.FindStringSubmatch(line)
+			m.heading(len(match[1]), match[2])
+		case ruleRe.MatchString(line):
+			m.flush()
+			m.rule()
+		case listRe.MatchString(line):
+			m.flush()
+			match := listRe.FindStringSubmatch(line)
+			m.item(len(strings.Replace(match[1], "\t", "    ", -1))/2, match[2], match[3])
+		case quoteRe.MatchString(line):
+			m.flush()
+			m.quote(quoteRe.FindStringSubmatch(line)[1])
+		default:
+			m.paragraph = append(m.paragraph, line)
+		}
+	}
+	m.flush()
+}
+
+// flush writes the current paragraph. Lines are joined, unless they
+// end with two spaces.
+func (m *mdWriter) flush() {
+	if len(m.paragraph) == 0 {
+		return
+	}
+
+	var text strings.Builder
+	for i, line := range m.paragraph {
+		text.WriteString(strings.TrimSpace(line))
+		if i == len(m.paragraph)-1 {
+			break
+		}
+		if strings.HasSuffix(line, "  ") {
+			text.WriteString("\n")
+		} else {
+			text.WriteString(" ")
+		}
+	}
+	m.paragraph = nil
+
+	m.inline(text.String(), fontSize, "")
+	m.pdf.Ln(fontSize * lineSpacing)
+	m.pdf.Ln(fontSize / 2)
+}
+
+func (m *mdWriter) heading(level int, text string) {
+	size := headingSizes[level-1]
+	m.pdf.Ln(size / 2)
+	m.inline(text, size, "B")
+	m.pdf.Ln(size * lineSpacing)
+	m.pdf.Ln(size / 4)
+}
+
+func (m *mdWriter) rule() {
+	left, _, right, _ := m.pdf.GetMargins()
+	y := m.pdf.GetY() + fontSize/2
+	m.pdf.SetDrawColor(160, 160, 160)
+	m.pdf.Line(left, y, PageSize.Wd-right, y)
+	m.pdf.Ln(fontSize)
+}
+
+func (m *mdWriter) item(level int, marker, text string) {
+	indent := margin + float64(level+1)*fontSize*1.5
+	if marker == "-" || marker == "*" || marker == "+" {
+		marker = "•"
+	}
+
+	m.pdf.SetFont(textFont, "", fontSize)
+	m.pdf.SetX(indent - m.pdf.GetStringWidth(marker) - fontSize/2)
+	m.pdf.Write(fontSize*lineSpacing, marker)
+
+	// wrapped lines are aligned with the text of the item
+	m.pdf.SetLeftMargin(indent)
+	m.pdf.SetX(indent)
+	m.inline(text, fontSize, "")
+	m.pdf.Ln(fontSize * lineSpacing)
+	m.pdf.SetLeftMargin(margin)
+}
+
+func (m *mdWriter) quote(text string) {
+	indent := margin + fontSize*1.5
+	y := m.pdf.GetY()
+
+	m.pdf.SetLeftMargin(indent)
+	m.pdf.SetX(indent)
+	m.pdf.SetTextColor(90, 90, 90)
+	m.inline(text, fontSize, "I")
+	m.pdf.Ln(fontSize * lineSpacing)
+	m.pdf.SetTextColor(0, 0, 0)
+	m.pdf.SetLeftMargin(margin)
+
+	// a bar along the quote, on the same page
+	if m.pdf.GetY() > y {
+		m.pdf.SetDrawColor(160, 160, 160)
+		m.pdf.SetLineWidth(2)
+		m.pdf.Line(margin+fontSize/2, y, margin+fontSize/2, m.pdf.GetY())
+		m.pdf.SetLineWidth(0.5)
+	}
+}
+
+func (m *mdWriter) code(line string) {
+	m.pdf.SetFont(codeFont, "", fontSize*0.9)
+	m.pdf.SetFillColor(240, 240, 240)
+	line = strings.Replace(line, "\t", "    ", -1)
+	m.pdf.MultiCell(0, fontSize*lineSpacing, line, "", "L", true)
+}
+
+// inline writes text with its emphasis, code spans and links.
+func (m *mdWriter) inline(text string, size float64, style string) {
+	for _, s := range parseInline(text) {
+		st := style
+		if s.bold && !strings.Contains(st, "B") {
+			st += "B"
+		}
+		if s.italic && !strings.Contains(st, "I") {
+			st += "I"
+		}
+
+		if s.code {
+			m.pdf.SetFont(codeFont, st, size*0.9)
+		} else {
+			m.pdf.SetFont(textFont, st, size)
+		}
+
+		if s.link != "" {
+			m.pdf.SetTextColor(30, 80, 180)
+			m.pdf.WriteLinkString(size*lineSpacing, s.text, s.link)
+			m.pdf.SetTextColor(0, 0, 0)
+		} else {
+			m.pdf.Write(size*lineSpacing, s.text)
+		}
+	}
+}
+
+// A span is a part of a line of text with the same style.
+type span struct {
+	text         string
+	bold, italic bool
+	code         bool
+	link         string
+}
+
+// parseInline splits a line of Markdown text into spans.
+func parseInline(text string) []span {
+	var spans []span
+	var cur strings.Builder
+	bold, italic := false, false
+
+	flush := func() {
+		if cur.Len() > 0 {
+			spans = append(spans, span{text: cur.String(), bold: bold, italic: italic})
+			cur.Reset()
+		}
+	}
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		rest := string(runes[i:])
+
+		switch {
+		case r == '\\' && i+1 < len(runes):
+			i++
+			cur.WriteRune(runes[i])
+
+		case r == '`':
+			end := strings.IndexRune(rest[1:], '`')
+			if end < 0 {
+				cur.WriteRune(r)
+				continue
+			}
+			flush()
+			code := rest[1 : end+1]
+			spans = append(spans, span{text: code, bold: bold, italic: italic, code: true})
+			i += len([]rune(code)) + 1
+
+		case r == '[':
+			close := strings.Index(rest, "](")
+			if close < 0 {
+				cur.WriteRune(r)
+				continue
+			}
+			end := strings.IndexRune(rest[close:], ')')
+			if end < 0 {
+				cur.WriteRune(r)
+				continue
+			}
+			flush()
+			label, url := rest[1:close], rest[close+2:close+end]
+			spans = append(spans, span{text: label, bold: bold, italic: italic, link: url})
+			i += len([]rune(rest[:close+end]))
+
+		case strings.HasPrefix(rest, "**") || strings.HasPrefix(rest, "__"):
+			flush()
+			bold = !bold
+			i++
+
+		case r == '*' || (r == '_' && emphasisBoundary(runes, i)):
+			flush()
+			italic = !italic
+
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+
+	return spans
+}
+
+// emphasisBoundary tells if an underscore starts or ends emphasis,
+// rather than being part of a word like snake_case.
+func emphasisBoundary(runes []rune, i int) bool {
+	before := i == 0 || !isWordRune(runes[i-1])
+	after := i == len(runes)-1 || !isWordRune(runes[i+1])
+	return before || after
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
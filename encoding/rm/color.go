@@ -0,0 +1,95 @@
+package rm
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// Colors written by newer firmwares and the highlighters of the reMarkable 2,
+// the first three ones being defined with the BrushColor type.
+const (
+	Yellow      BrushColor = 3
+	Green       BrushColor = 4
+	Pink        BrushColor = 5
+	Blue        BrushColor = 6
+	Red         BrushColor = 7
+	GreyOverlap BrushColor = 8
+	// HighlightYellow is the default color of the highlighter.
+	HighlightYellow BrushColor = 9
+	HighlightGreen  BrushColor = 10
+	Cyan            BrushColor = 11
+	Magenta         BrushColor = 12
+	Yellow2         BrushColor = 13
+)
+
+var colorNames = map[BrushColor]string{
+	Black:           "black",
+	Grey:            "grey",
+	White:           "white",
+	Yellow:          "yellow",
+	Green:           "green",
+	Pink:            "pink",
+	Blue:            "blue",
+	Red:             "red",
+	GreyOverlap:     "grey-overlap",
+	HighlightYellow: "highlight-yellow",
+	HighlightGreen:  "highlight-green",
+	Cyan:            "cyan",
+	Magenta:         "magenta",
+	Yellow2:         "yellow2",
+}
+
+func (c BrushColor) String() string {
+	if name, ok := colorNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("color(%d)", uint32(c))
+}
+
+// A Palette maps the brush colors to RGBA colors.
+type Palette map[BrushColor]color.RGBA
+
+// DefaultPalette returns a new palette close to the colors of the device,
+// it can be modified freely.
+func DefaultPalette() Palette {
+	return Palette{
+		Black:           {R: 0, G: 0, B: 0, A: 255},
+		Grey:            {R: 144, G: 144, B: 144, A: 255},
+		White:           {R: 255, G: 255, B: 255, A: 255},
+		Yellow:          {R: 251, G: 247, B: 25, A: 255},
+		Green:           {R: 0, G: 255, B: 0, A: 255},
+		Pink:            {R: 255, G: 192, B: 203, A: 255},
+		Blue:            {R: 78, G: 105, B: 201, A: 255},
+		Red:             {R: 179, G: 62, B: 57, A: 255},
+		GreyOverlap:     {R: 125, G: 125, B: 125, A: 255},
+		HighlightYellow: {R: 255, G: 237, B: 117, A: 255},
+		HighlightGreen:  {R: 145, G: 218, B: 113, A: 255},
+		Cyan:            {R: 116, G: 210, B: 232, A: 255},
+		Magenta:         {R: 192, G: 127, B: 210, A: 255},
+		Yellow2:         {R: 247, G: 232, B: 81, A: 255},
+	}
+}
+
+// RGBA returns the color of a brush. Colors missing from the palette
+// are taken from the default palette, and unknown colors are black.
+func (p Palette) RGBA(c BrushColor) color.RGBA {
+	if rgba, ok := p[c]; ok {
+		return rgba
+	}
+	if rgba, ok := defaultPalette[c]; ok {
+		return rgba
+	}
+	return defaultPalette[Black]
+}
+
+// Highlight returns the color of a highlighter stroke drawn with the
+// brush color c. Older firmwares don't record the color of highlighters,
+// so black, grey and white are drawn in HighlightYellow.
+func (p Palette) Highlight(c BrushColor) color.RGBA {
+	if c == Black || c == Grey || c == White {
+		c = HighlightYellow
+	}
+	return p.RGBA(c)
+}
+
+var defaultPalette = DefaultPalette()
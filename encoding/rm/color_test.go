@@ -0,0 +1,31 @@
+package rm
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestPalette(t *testing.T) {
+	p := Palette{Blue: {R: 1, G: 2, B: 3, A: 255}}
+
+	if c := p.RGBA(Blue); c != (color.RGBA{R: 1, G: 2, B: 3, A: 255}) {
+		t.Errorf("custom color not used, got %v", c)
+	}
+	if c := p.RGBA(Red); c != DefaultPalette()[Red] {
+		t.Errorf("missing colors should come from the default palette, got %v", c)
+	}
+	if c := p.RGBA(BrushColor(99)); c != DefaultPalette()[Black] {
+		t.Errorf("unknown colors should be black, got %v", c)
+	}
+
+	if c := p.Highlight(Black); c != DefaultPalette()[HighlightYellow] {
+		t.Errorf("old highlighters should be yellow, got %v", c)
+	}
+	if c := p.Highlight(HighlightGreen); c != DefaultPalette()[HighlightGreen] {
+		t.Errorf("highlighter color not kept, got %v", c)
+	}
+
+	if Blue.String() != "blue" || BrushColor(99).String() != "color(99)" {
+		t.Error("wrong color names")
+	}
+}
@@ -0,0 +1,428 @@
+package rm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Values returned by the functions of a Visitor.
+const (
+	// StopVisiting ends the walk.
+	StopVisiting = true
+	// ContinueVisiting goes on with the next layer or stroke.
+	ContinueVisiting = false
+)
+
+// blockHeaderLen is the size of the header preceding each v6 block.
+const blockHeaderLen = 8
+
+// A Decoder reads a .rm page from a stream one stroke at a time,
+// so that tools only looking at the strokes (counting them, computing
+// bounding boxes, filtering by brush...) don't need to hold every
+// segment of a page in memory.
+//
+// v6 pages are read whole before their first layer: the layers and the
+// order of their strokes are only known once every block has been read.
+type Decoder struct {
+	// Lenient makes Decode keep the layers and the complete strokes
+	// read before an error, so that a truncated or corrupted page
+	// still gives what could be read. The error is returned anyway.
+	Lenient bool
+
+	r       *countingReader
+	version Version
+	started bool
+	err     error
+
+	// v3 and v5 state
+	nbLayers  uint32
+	layer     uint32
+	nbStrokes uint32
+	stroke    int
+	segment   int
+
+	// v6 state
+	pending []event
+	// sceneErr is returned once the events of the scene are read
+	sceneErr error
+}
+
+// An event is either the start of a layer or a stroke of the layer.
+type event struct {
+	layer  int
+	name   string
+	stroke *Stroke
+}
+
+// Visitor holds the functions called by Walk. A nil function is skipped.
+// Returning StopVisiting ends the walk.
+type Visitor struct {
+	// Layer is called when the decoder enters a new layer.
+	// The name is only known for v6 pages.
+	Layer func(index int, name string) bool
+	// Stroke is called for each stroke with the index of its layer.
+	Stroke func(layer int, stroke *Stroke) bool
+}
+
+// NewDecoder returns a Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		r:       &countingReader{r: bufio.NewReader(r)},
+		stroke:  -1,
+		segment: -1,
+	}
+}
+
+// Version reads the header if needed and returns the version of the page.
+func (d *Decoder) Version() (Version, error) {
+	if err := d.readHeader(); err != nil {
+		return d.version, err
+	}
+	return d.version, nil
+}
+
+// Decode reads the whole page into rm. It must be called before
+// any stroke has been read from the decoder.
+// Errors are returned as a *DecodeError.
+func (d *Decoder) Decode(rm *Rm) error {
+	rm.Layers = nil
+	rm.Scene = nil
+
+	if err := d.readHeader(); err != nil {
+		return err
+	}
+	rm.Version = d.version
+
+	if d.version == V6 {
+		scene := &Scene{}
+		var err error
+		for {
+			var block Block
+			block, err = d.readBlock()
+			if err != nil {
+				break
+			}
+			scene.Blocks = append(scene.Blocks, block)
+		}
+		if err != io.EOF && !d.Lenient {
+			return err
+		}
+		rm.Scene = scene
+		rm.Layers = scene.Layers()
+		if err != io.EOF {
+			return err
+		}
+		return nil
+	}
+
+	rm.Layers = make([]Layer, 0, minUint32(d.nbLayers, 1<<8))
+	for {
+		ev, err := d.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if !d.Lenient {
+				rm.Layers = nil
+			}
+			return err
+		}
+
+		if ev.stroke == nil {
+			rm.Layers = append(rm.Layers, Layer{Name: ev.name, Strokes: make([]Stroke, 0, minUint32(d.nbStrokes, 1<<16))})
+			continue
+		}
+		rm.Layers[ev.layer].Strokes = append(rm.Layers[ev.layer].Strokes, *ev.stroke)
+	}
+}
+
+// NextStroke returns the next stroke of the page and the index of its layer.
+// It returns io.EOF when there are no more strokes.
+func (d *Decoder) NextStroke() (int, *Stroke, error) {
+	for {
+		ev, err := d.next()
+		if err != nil {
+			return 0, nil, err
+		}
+		if ev.stroke != nil {
+			return ev.layer, ev.stroke, nil
+		}
+	}
+}
+
+// Walk reads the rest of the page and calls the functions of the
+// visitor for each layer and stroke.
+func (d *Decoder) Walk(v Visitor) error {
+	for {
+		ev, err := d.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if ev.stroke == nil {
+			if v.Layer != nil && v.Layer(ev.layer, ev.name) {
+				return nil
+			}
+			continue
+		}
+
+		if v.Stroke != nil && v.Stroke(ev.layer, ev.stroke) {
+			return nil
+		}
+	}
+}
+
+func (d *Decoder) readHeader() error {
+	if d.started {
+		return d.err
+	}
+	d.started = true
+
+	buf := make([]byte, HeaderLen)
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		return d.fail("header", ErrWrongHeaderSize)
+	}
+
+	switch string(buf) {
+	case HeaderV6:
+		d.version = V6
+		return nil
+	case HeaderV5:
+		d.version = V5
+	case HeaderV3:
+		d.version = V3
+	default:
+		d.r.n = 0
+		return d.fail("header", ErrUnknownHeader)
+	}
+
+	nbLayers, err := d.readNumber("header")
+	if err != nil {
+		return err
+	}
+	d.nbLayers = nbLayers
+
+	return nil
+}
+
+// fail records the error with the current position, any
+// further read returns the same error.
+func (d *Decoder) fail(op string, err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	layer := int(d.layer) - 1
+	if op == "header" || op == "block" {
+		layer = -1
+	}
+
+	d.err = &DecodeError{
+		Op:      op,
+		Offset:  d.r.n,
+		Layer:   layer,
+		Stroke:  d.stroke,
+		Segment: d.segment,
+		Err:     err,
+	}
+	return d.err
+}
+
+// read reads data and turns a failure into a DecodeError.
+func (d *Decoder) read(op string, data interface{}) error {
+	offset := d.r.n
+	if err := binary.Read(d.r, binary.LittleEndian, data); err != nil {
+		d.r.n = offset
+		return d.fail(op, err)
+	}
+	return nil
+}
+
+// next returns the next layer or stroke of the page.
+func (d *Decoder) next() (event, error) {
+	if err := d.readHeader(); err != nil {
+		return event{}, err
+	}
+	if d.err != nil {
+		return event{}, d.err
+	}
+
+	if d.version == V6 {
+		return d.nextV6()
+	}
+
+	if d.nbStrokes == 0 {
+		if d.layer == d.nbLayers {
+			return event{}, io.EOF
+		}
+
+		d.layer++
+		d.stroke = -1
+		nbLines, err := d.readNumber("layer")
+		if err != nil {
+			return event{}, err
+		}
+		d.nbStrokes = nbLines
+
+		return event{layer: int(d.layer) - 1}, nil
+	}
+
+	d.stroke++
+	line, err := d.readStroke()
+	if err != nil {
+		return event{}, err
+	}
+	d.nbStrokes--
+
+	return event{layer: int(d.layer) - 1, stroke: &line}, nil
+}
+
+func (d *Decoder) readNumber(op string) (uint32, error) {
+	var nb uint32
+	if err := d.read(op, &nb); err != nil {
+		return 0, err
+	}
+	return nb, nil
+}
+
+func (d *Decoder) readStroke() (Stroke, error) {
+	var line Stroke
+
+	if err := d.read("line", &line.BrushType); err != nil {
+		return line, err
+	}
+
+	if err := d.read("line", &line.BrushColor); err != nil {
+		return line, err
+	}
+
+	if err := d.read("line", &line.Width); err != nil {
+		return line, err
+	}
+
+	if err := d.read("line", &line.BrushSize); err != nil {
+		return line, err
+	}
+
+	// this new attribute has been added in v5
+	if d.version == V5 {
+		if err := d.read("line", &line.Unknown); err != nil {
+			return line, err
+		}
+	}
+
+	nbPoints, err := d.readNumber("line")
+	if err != nil {
+		return line, err
+	}
+
+	if nbPoints == 0 {
+		return line, nil
+	}
+
+	// don't trust the count for the allocation, a corrupted
+	// file would make us allocate gigabytes
+	line.Segments = make([]Segment, 0, minUint32(nbPoints, 1<<16))
+
+	for i := uint32(0); i < nbPoints; i++ {
+		d.segment = int(i)
+		p, err := d.readSegment()
+		if err != nil {
+			return line, err
+		}
+
+		line.Segments = append(line.Segments, p)
+	}
+	d.segment = -1
+
+	return line, nil
+}
+
+func (d *Decoder) readSegment() (Segment, error) {
+	var point Segment
+
+	if err := d.read("point", &point); err != nil {
+		return point, err
+	}
+
+	return point, nil
+}
+
+// readBlock reads the next v6 block, it returns io.EOF
+// when the end of the page is reached.
+func (d *Decoder) readBlock() (Block, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	offset := d.r.n
+	header := make([]byte, blockHeaderLen)
+	if n, err := io.ReadFull(d.r, header); err != nil {
+		if n == 0 && err == io.EOF {
+			return nil, io.EOF
+		}
+		d.r.n = offset
+		return nil, d.fail("block", err)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(header)
+	length := binary.LittleEndian.Uint32(header)
+	if _, err := io.CopyN(&buf, d.r, int64(length)); err != nil {
+		d.r.n = offset
+		return nil, d.fail("block", err)
+	}
+
+	r := newReader(buf.Bytes())
+	block, err := r.readBlock()
+	if err != nil {
+		d.r.n = offset
+		return nil, d.fail("block", fmt.Errorf("%w: %v", ErrInvalidBlock, err))
+	}
+	return block, nil
+}
+
+// nextV6 returns the next layer or line of the scene, reading the whole
+// scene on the first call so that the layers are the ones of Scene.Layers.
+func (d *Decoder) nextV6() (event, error) {
+	if d.sceneErr == nil {
+		scene := &Scene{}
+		for {
+			block, err := d.readBlock()
+			if err != nil {
+				d.sceneErr = err
+				break
+			}
+			scene.Blocks = append(scene.Blocks, block)
+		}
+
+		// the layers and the complete lines read before an error
+		// are returned before it
+		for i, layer := range scene.Layers() {
+			d.pending = append(d.pending, event{layer: i, name: layer.Name})
+			for j := range layer.Strokes {
+				d.pending = append(d.pending, event{layer: i, stroke: &layer.Strokes[j]})
+			}
+		}
+	}
+
+	if len(d.pending) == 0 {
+		return event{}, d.sceneErr
+	}
+	ev := d.pending[0]
+	d.pending = d.pending[1:]
+	return ev, nil
+}
+
+func minUint32(a, b uint32) uint32 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,152 @@
+package rm
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDecoderNextStroke(t *testing.T) {
+	for fn, ver := range map[string]Version{"test_v3.rm": V3, "test_v5.rm": V5} {
+		file, err := os.Open(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+
+		want := testUnmarshalBinary(t, fn, ver)
+
+		d := NewDecoder(file)
+		counts := make(map[int]int)
+		points := 0
+		for {
+			layer, stroke, err := d.NextStroke()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			counts[layer]++
+			points += len(stroke.Segments)
+		}
+
+		wantPoints := 0
+		for i, layer := range want.Layers {
+			if counts[i] != len(layer.Strokes) {
+				t.Errorf("%s layer %d: got %d strokes, want %d", fn, i, counts[i], len(layer.Strokes))
+			}
+			for _, stroke := range layer.Strokes {
+				wantPoints += len(stroke.Segments)
+			}
+		}
+		if points != wantPoints {
+			t.Errorf("%s: got %d points, want %d", fn, points, wantPoints)
+		}
+	}
+}
+
+func TestDecoderWalk(t *testing.T) {
+	page := &Rm{
+		Version: V6,
+		Layers: []Layer{
+			{Name: "First", Strokes: []Stroke{{BrushType: Pencil}, {BrushType: Marker}}},
+			{Name: "Second", Strokes: []Stroke{{BrushType: BallPoint}}},
+		},
+	}
+
+	for _, version := range []Version{V3, V5, V6} {
+		page.Version = version
+		data, err := page.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d := NewDecoder(bytes.NewReader(data))
+		if v, err := d.Version(); err != nil || v != version {
+			t.Fatalf("got version %d (%v), want %d", v, err, version)
+		}
+
+		var names []string
+		var brushes []BrushType
+		err = d.Walk(Visitor{
+			Layer: func(index int, name string) bool {
+				names = append(names, name)
+				return ContinueVisiting
+			},
+			Stroke: func(layer int, stroke *Stroke) bool {
+				brushes = append(brushes, stroke.BrushType)
+				return len(brushes) == 2
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(names) != 1 || (version == V6 && names[0] != "First") {
+			t.Errorf("v%d: walk should stop in the first layer, got %v", version, names)
+		}
+		if len(brushes) != 2 || brushes[0] != Pencil || brushes[1] != Marker {
+			t.Errorf("v%d: got brushes %v", version, brushes)
+		}
+	}
+}
+
+func TestDecoderWalkMatchesDecodeV6(t *testing.T) {
+	fixture, err := ioutil.ReadFile("test_v6.rm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a layer only attached by the tree, whose line is stored before
+	// the group items of the other layers
+	scene := NewScene([]Layer{
+		{Name: "First", Strokes: []Stroke{{BrushType: Pencil}}},
+		{Name: "Second", Strokes: []Stroke{{BrushType: Marker}}},
+	})
+	extra := CrdtID{2, 1}
+	scene.Blocks = append([]Block{
+		&SceneTreeBlock{BlockInfo: BlockInfo{MinVersion: 1, Version: 1}, TreeID: extra, ParentID: RootID},
+		&SceneLineItemBlock{
+			BlockInfo: BlockInfo{MinVersion: 2, Version: 2},
+			SceneItem: SceneItem{ParentID: extra, ItemID: CrdtID{2, 2}, HasValue: true, ItemType: lineItemType},
+			Line:      Line{Tool: BallPoint},
+		},
+	}, scene.Blocks...)
+	treeOnly, err := (&Rm{Version: V6, Scene: scene}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for desc, data := range map[string][]byte{"fixture": fixture, "tree only layer": treeOnly} {
+		var page Rm
+		if err := NewDecoder(bytes.NewReader(data)).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+
+		var walked []Layer
+		err := NewDecoder(bytes.NewReader(data)).Walk(Visitor{
+			Layer: func(index int, name string) bool {
+				if index != len(walked) {
+					t.Errorf("%s: layer %d entered as %d", desc, len(walked), index)
+				}
+				walked = append(walked, Layer{Name: name})
+				return ContinueVisiting
+			},
+			Stroke: func(layer int, stroke *Stroke) bool {
+				walked[layer].Strokes = append(walked[layer].Strokes, *stroke)
+				return ContinueVisiting
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(walked, page.Layers) {
+			t.Errorf("%s: walked %+v, decoded %+v", desc, walked, page.Layers)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package rm
+
+import (
+	"math"
+	"sort"
+)
+
+// ResolveErasers returns a copy of the page where the Eraser and EraseArea
+// strokes are applied to the strokes drawn before them in the same layer.
+// Erased parts are cut out, splitting strokes in several pieces when needed,
+// and the eraser strokes themselves are dropped. Strokes drawn after an eraser
+// are left untouched by it.
+//
+// A point is erased when the center line of a stroke passes under the eraser,
+// the width of the erased stroke isn't taken into account.
+func ResolveErasers(page *Rm) *Rm {
+	out := *page
+	out.Layers = make([]Layer, len(page.Layers))
+	for i, layer := range page.Layers {
+		out.Layers[i] = resolveLayer(layer)
+	}
+	return &out
+}
+
+func resolveLayer(layer Layer) Layer {
+	out := layer
+	out.Strokes = nil
+
+	for _, stroke := range layer.Strokes {
+		var covered func(a, b Segment) []interval
+		switch stroke.BrushType {
+		case Eraser:
+			eraser := stroke
+			covered = func(a, b Segment) []interval { return eraserIntervals(a, b, eraser) }
+		case EraseArea:
+			area := stroke
+			covered = func(a, b Segment) []interval { return areaIntervals(a, b, area) }
+		default:
+			out.Strokes = append(out.Strokes, stroke)
+			continue
+		}
+
+		if len(stroke.Segments) == 0 {
+			continue
+		}
+		bbox := eraserBounds(stroke)
+
+		var kept []Stroke
+		for _, s := range out.Strokes {
+			if !overlaps(bbox, s.BoundingBox()) {
+				kept = append(kept, s)
+				continue
+			}
+			kept = append(kept, clipStroke(s, covered)...)
+		}
+		out.Strokes = kept
+	}
+
+	return out
+}
+
+// interval is a part [Start, End] of a segment, from 0 at its first point
+// to 1 at its last point.
+type interval struct {
+	Start, End float64
+}
+
+// clipStroke removes from s the parts covered by an eraser,
+// covered returning the erased intervals of a segment.
+func clipStroke(s Stroke, covered func(a, b Segment) []interval) []Stroke {
+	var pieces []Stroke
+	var current []Segment
+
+	flush := func() {
+		if len(current) > 0 {
+			piece := s
+			piece.Segments = current
+			pieces = append(pieces, piece)
+		}
+		current = nil
+	}
+
+	if len(s.Segments) == 1 {
+		if len(covered(s.Segments[0], s.Segments[0])) == 0 {
+			return []Stroke{s}
+		}
+		return nil
+	}
+
+	for i := 1; i < len(s.Segments); i++ {
+		a, b := s.Segments[i-1], s.Segments[i]
+
+		// visible intervals are the complement of the erased ones
+		start := 0.0
+		for _, e := range append(mergeIntervals(covered(a, b)), interval{1, 1}) {
+			if e.Start > start {
+				// a piece goes on when the previous segment ended visible
+				if start > 0 || len(current) == 0 {
+					flush()
+					current = append(current, lerpSegment(a, b, start))
+				}
+				current = append(current, lerpSegment(a, b, e.Start))
+			}
+			if e.Start < 1 {
+				flush()
+			}
+			start = math.Max(start, e.End)
+		}
+	}
+	flush()
+
+	return pieces
+}
+
+// mergeIntervals sorts and merges overlapping intervals, ignoring the
+// empty ones where an eraser only touches a segment.
+func mergeIntervals(intervals []interval) []interval {
+	var list []interval
+	for _, in := range intervals {
+		if in.End > in.Start {
+			list = append(list, in)
+		}
+	}
+	if len(list) == 0 {
+		return nil
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Start < list[j].Start })
+
+	merged := []interval{list[0]}
+	for _, in := range list[1:] {
+		last := &merged[len(merged)-1]
+		if in.Start <= last.End {
+			last.End = math.Max(last.End, in.End)
+		} else {
+			merged = append(merged, in)
+		}
+	}
+	return merged
+}
+
+func lerpSegment(a, b Segment, t float64) Segment {
+	if t <= 0 {
+		return a
+	}
+	if t >= 1 {
+		return b
+	}
+	lerp := func(x, y float32) float32 { return x + float32(t)*(y-x) }
+	return Segment{
+		X:         lerp(a.X, b.X),
+		Y:         lerp(a.Y, b.Y),
+		Speed:     lerp(a.Speed, b.Speed),
+		Direction: b.Direction,
+		Width:     lerp(a.Width, b.Width),
+		Pressure:  lerp(a.Pressure, b.Pressure),
+	}
+}
+
+// eraserIntervals returns the parts of the segment [a, b] passing under
+// the eraser, the eraser being made of round capsules as wide as its segments.
+func eraserIntervals(a, b Segment, eraser Stroke) []interval {
+	var list []interval
+	segment := Rect{MinX: min32(a.X, b.X), MinY: min32(a.Y, b.Y), MaxX: max32(a.X, b.X), MaxY: max32(a.Y, b.Y)}
+	for i := range eraser.Segments {
+		p, q := eraser.Segments[i], eraser.Segments[i]
+		if i > 0 {
+			p = eraser.Segments[i-1]
+		}
+		half := max32(p.Width, q.Width) / 2
+		radius := float64(half)
+
+		capsule := Rect{MinX: min32(p.X, q.X) - half, MinY: min32(p.Y, q.Y) - half,
+			MaxX: max32(p.X, q.X) + half, MaxY: max32(p.Y, q.Y) + half}
+		if !overlaps(segment, capsule) {
+			continue
+		}
+
+		dist := func(t float64) float64 {
+			return segmentDistance(lerpSegment(a, b, t), p, q) - radius
+		}
+		if in, ok := convexInterval(dist); ok {
+			list = append(list, in)
+		}
+	}
+	return list
+}
+
+// convexInterval returns where the convex function f is negative on [0, 1].
+func convexInterval(f func(float64) float64) (interval, bool) {
+	// ternary search of the minimum
+	lo, hi := 0.0, 1.0
+	for i := 0; i < 60; i++ {
+		m1, m2 := lo+(hi-lo)/3, hi-(hi-lo)/3
+		if f(m1) < f(m2) {
+			hi = m2
+		} else {
+			lo = m1
+		}
+	}
+	tmin := (lo + hi) / 2
+	if f(tmin) > 0 {
+		return interval{}, false
+	}
+
+	// bisection of both boundaries
+	bound := func(in, out float64) float64 {
+		if f(out) <= 0 {
+			return out
+		}
+		for i := 0; i < 40; i++ {
+			m := (in + out) / 2
+			if f(m) <= 0 {
+				in = m
+			} else {
+				out = m
+			}
+		}
+		return (in + out) / 2
+	}
+	return interval{bound(tmin, 0), bound(tmin, 1)}, true
+}
+
+// areaIntervals returns the parts of the segment [a, b] inside the polygon
+// drawn by an erase area stroke.
+func areaIntervals(a, b Segment, area Stroke) []interval {
+	poly := area.Segments
+	n := len(poly)
+	if n < 3 {
+		return nil
+	}
+
+	// the segment is cut wherever it crosses an edge of the polygon
+	cuts := []float64{0, 1}
+	for i := range poly {
+		p, q := poly[i], poly[(i+1)%n]
+		if t, ok := intersect(a, b, p, q); ok {
+			cuts = append(cuts, t)
+		}
+	}
+	sort.Float64s(cuts)
+
+	var list []interval
+	for i := 1; i < len(cuts); i++ {
+		if cuts[i] == cuts[i-1] {
+			continue
+		}
+		mid := lerpSegment(a, b, (cuts[i-1]+cuts[i])/2)
+		if inPolygon(mid, poly) {
+			list = append(list, interval{cuts[i-1], cuts[i]})
+		}
+	}
+	return list
+}
+
+// intersect returns where [a, b] crosses [p, q] as a position on [a, b].
+func intersect(a, b, p, q Segment) (float64, bool) {
+	rx, ry := float64(b.X-a.X), float64(b.Y-a.Y)
+	sx, sy := float64(q.X-p.X), float64(q.Y-p.Y)
+
+	denom := rx*sy - ry*sx
+	if denom == 0 {
+		return 0, false
+	}
+
+	px, py := float64(p.X-a.X), float64(p.Y-a.Y)
+	t := (px*sy - py*sx) / denom
+	u := (px*ry - py*rx) / denom
+	if t < 0 || t > 1 || u < 0 || u > 1 {
+		return 0, false
+	}
+	return t, true
+}
+
+// inPolygon tells if p is inside the polygon using the even-odd rule.
+func inPolygon(p Segment, poly []Segment) bool {
+	inside := false
+	for i, j := 0, len(poly)-1; i < len(poly); j, i = i, i+1 {
+		a, b := poly[i], poly[j]
+		if (a.Y > p.Y) != (b.Y > p.Y) &&
+			p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// eraserBounds returns the area an eraser can reach.
+func eraserBounds(s Stroke) Rect {
+	bbox := s.BoundingBox()
+	if s.BrushType == EraseArea {
+		return bbox
+	}
+	// the width of a capsule is the widest of its ends
+	var half float32
+	for _, seg := range s.Segments {
+		half = max32(half, seg.Width/2)
+	}
+	return Rect{MinX: bbox.MinX - half, MinY: bbox.MinY - half, MaxX: bbox.MaxX + half, MaxY: bbox.MaxY + half}
+}
+
+func overlaps(a, b Rect) bool {
+	return a.MinX <= b.MaxX && b.MinX <= a.MaxX && a.MinY <= b.MaxY && b.MinY <= a.MaxY
+}
@@ -0,0 +1,73 @@
+package rm
+
+import (
+	"math"
+	"testing"
+)
+
+func TestResolveEraser(t *testing.T) {
+	eraser := line(50, -10, 50, 10)
+	eraser.BrushType = Eraser
+	for i := range eraser.Segments {
+		eraser.Segments[i].Width = 10
+	}
+
+	page := &Rm{Layers: []Layer{{Strokes: []Stroke{
+		line(0, 0, 100, 0),
+		line(0, 50, 100, 50),
+		eraser,
+		line(0, 0, 100, 0),
+	}}}}
+
+	out := ResolveErasers(page)
+	strokes := out.Layers[0].Strokes
+
+	if len(strokes) != 4 {
+		t.Fatalf("got %d strokes, want 4", len(strokes))
+	}
+
+	left, right := strokes[0], strokes[1]
+	if left.PointCount() != 2 || right.PointCount() != 2 {
+		t.Fatalf("erased stroke not split in two: %+v", strokes)
+	}
+	if x := left.Segments[1].X; math.Abs(float64(x)-45) > 0.01 {
+		t.Errorf("left piece ends at %f, want 45", x)
+	}
+	if x := right.Segments[0].X; math.Abs(float64(x)-55) > 0.01 {
+		t.Errorf("right piece starts at %f, want 55", x)
+	}
+	if right.Segments[1].X != 100 {
+		t.Errorf("right piece should end at the end of the stroke")
+	}
+
+	if strokes[2].PointCount() != 2 || strokes[3].PointCount() != 2 {
+		t.Error("strokes away from or after the eraser should be kept")
+	}
+
+	if len(page.Layers[0].Strokes) != 4 || page.Layers[0].Strokes[0].PointCount() != 2 {
+		t.Error("the original page was modified")
+	}
+}
+
+func TestResolveEraseArea(t *testing.T) {
+	area := line(40, -10, 60, -10, 60, 10, 40, 10)
+	area.BrushType = EraseArea
+
+	page := &Rm{Layers: []Layer{{Strokes: []Stroke{
+		line(0, 0, 30, 0, 70, 0, 100, 0),
+		line(45, 5),
+		area,
+	}}}}
+
+	strokes := ResolveErasers(page).Layers[0].Strokes
+
+	if len(strokes) != 2 {
+		t.Fatalf("got %d strokes, want 2: %+v", len(strokes), strokes)
+	}
+	if n := strokes[0].PointCount(); n != 3 || strokes[0].Segments[2].X != 40 {
+		t.Errorf("wrong left piece %+v", strokes[0].Segments)
+	}
+	if n := strokes[1].PointCount(); n != 3 || strokes[1].Segments[0].X != 60 {
+		t.Errorf("wrong right piece %+v", strokes[1].Segments)
+	}
+}
@@ -0,0 +1,77 @@
+package rm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Errors wrapped by a DecodeError, they can be checked with errors.Is.
+var (
+	// ErrWrongHeaderSize is returned when the data is shorter than a header.
+	ErrWrongHeaderSize = errors.New("Wrong header size")
+	// ErrUnknownHeader is returned for data that isn't a supported .rm file.
+	ErrUnknownHeader = errors.New("Unknown header")
+	// ErrInvalidBlock is returned when a v6 block can't be decoded.
+	ErrInvalidBlock = errors.New("Invalid block")
+)
+
+// A DecodeError tells where the decoding of a page failed.
+// Indexes that don't apply are set to -1.
+type DecodeError struct {
+	// Op is what was being read: "header", "layer", "line", "point" or "block".
+	Op string
+	// Offset is the position of the failure in the data.
+	Offset  int64
+	Layer   int
+	Stroke  int
+	Segment int
+	// Err is the underlying error, io.ErrUnexpectedEOF for a truncated page.
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	var o strings.Builder
+
+	fmt.Fprintf(&o, "Failed to read %s at offset %d", e.Op, e.Offset)
+
+	var pos []string
+	if e.Layer >= 0 {
+		pos = append(pos, fmt.Sprintf("layer %d", e.Layer))
+	}
+	if e.Stroke >= 0 {
+		pos = append(pos, fmt.Sprintf("stroke %d", e.Stroke))
+	}
+	if e.Segment >= 0 {
+		pos = append(pos, fmt.Sprintf("segment %d", e.Segment))
+	}
+	if len(pos) > 0 {
+		fmt.Fprintf(&o, " (%s)", strings.Join(pos, ", "))
+	}
+
+	fmt.Fprintf(&o, ": %v", e.Err)
+	return o.String()
+}
+
+// Unwrap returns the underlying error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// IsTruncated tells if err is caused by a page ending too early.
+func IsTruncated(err error) bool {
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// countingReader counts the bytes read to report offsets in errors.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
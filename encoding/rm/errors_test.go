@@ -0,0 +1,92 @@
+package rm
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+func TestDecodeErrorTruncated(t *testing.T) {
+	b, err := ioutil.ReadFile("test_v5.rm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	full := New()
+	if err := full.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+
+	// cut the page in the middle of the second point of the second stroke
+	offset := HeaderLen + 4 + 4
+	first := full.Layers[0].Strokes[0]
+	offset += 5*4 + 4 + len(first.Segments)*6*4
+	offset += 5*4 + 4 + 6*4 + 10
+
+	rm := New()
+	err = rm.UnmarshalBinary(b[:offset])
+
+	var derr *DecodeError
+	if !errors.As(err, &derr) {
+		t.Fatalf("expected a DecodeError, got %v", err)
+	}
+	if !IsTruncated(err) {
+		t.Errorf("expected a truncation, got %v", err)
+	}
+	if derr.Op != "point" || derr.Layer != 0 || derr.Stroke != 1 || derr.Segment != 1 {
+		t.Errorf("wrong position %+v", derr)
+	}
+	if derr.Offset != int64(offset-10) {
+		t.Errorf("got offset %d, want %d", derr.Offset, offset-10)
+	}
+	if rm.Layers != nil {
+		t.Error("strict decoding should not return partial layers")
+	}
+
+	d := NewDecoder(bytes.NewReader(b[:offset]))
+	d.Lenient = true
+	err = d.Decode(rm)
+	if !IsTruncated(err) {
+		t.Errorf("expected a truncation, got %v", err)
+	}
+	if len(rm.Layers) != 1 || len(rm.Layers[0].Strokes) != 1 {
+		t.Fatalf("expected the first stroke only, got %+v", rm.Layers)
+	}
+	if len(rm.Layers[0].Strokes[0].Segments) != len(first.Segments) {
+		t.Error("first stroke not fully decoded")
+	}
+}
+
+func TestDecodeErrorHeader(t *testing.T) {
+	err := New().UnmarshalBinary([]byte("reMarkable .lines file, version=9          "))
+	if !errors.Is(err, ErrUnknownHeader) {
+		t.Errorf("expected ErrUnknownHeader, got %v", err)
+	}
+
+	err = New().UnmarshalBinary([]byte("reMarkable"))
+	if !errors.Is(err, ErrWrongHeaderSize) {
+		t.Errorf("expected ErrWrongHeaderSize, got %v", err)
+	}
+}
+
+func TestDecodeErrorV6Lenient(t *testing.T) {
+	page := &Rm{Version: V6, Layers: []Layer{{Strokes: []Stroke{{BrushType: Pencil}, {BrushType: Marker}}}}}
+	data, err := page.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rm := New()
+	d := NewDecoder(bytes.NewReader(data[:len(data)-5]))
+	d.Lenient = true
+	err = d.Decode(rm)
+
+	var derr *DecodeError
+	if !errors.As(err, &derr) || derr.Op != "block" || !IsTruncated(err) {
+		t.Fatalf("expected a truncated block, got %v", err)
+	}
+	if len(rm.Layers) != 1 || len(rm.Layers[0].Strokes) != 1 {
+		t.Errorf("expected the first stroke only, got %+v", rm.Layers)
+	}
+}
@@ -0,0 +1,244 @@
+package rm
+
+import "math"
+
+// Rect is an axis aligned rectangle in page coordinates.
+type Rect struct {
+	MinX, MinY float32
+	MaxX, MaxY float32
+}
+
+// Width of the rectangle.
+func (r Rect) Width() float32 {
+	return r.MaxX - r.MinX
+}
+
+// Height of the rectangle.
+func (r Rect) Height() float32 {
+	return r.MaxY - r.MinY
+}
+
+// Empty tells if the rectangle has no area.
+func (r Rect) Empty() bool {
+	return r.MinX >= r.MaxX || r.MinY >= r.MaxY
+}
+
+// Union returns the smallest rectangle containing both rectangles.
+func (r Rect) Union(b Rect) Rect {
+	return Rect{
+		MinX: min32(r.MinX, b.MinX),
+		MinY: min32(r.MinY, b.MinY),
+		MaxX: max32(r.MaxX, b.MaxX),
+		MaxY: max32(r.MaxY, b.MaxY),
+	}
+}
+
+// BoundingBox returns the area inked by the stroke, the width of
+// each segment included. It is the zero Rect for a stroke without segments.
+func (s Stroke) BoundingBox() Rect {
+	var r Rect
+	for i, seg := range s.Segments {
+		half := seg.Width / 2
+		b := Rect{MinX: seg.X - half, MinY: seg.Y - half, MaxX: seg.X + half, MaxY: seg.Y + half}
+		if i == 0 {
+			r = b
+		} else {
+			r = r.Union(b)
+		}
+	}
+	return r
+}
+
+// Length returns the length of the path followed by the stroke.
+func (s Stroke) Length() float64 {
+	var l float64
+	for i := 1; i < len(s.Segments); i++ {
+		l += distance(s.Segments[i-1], s.Segments[i])
+	}
+	return l
+}
+
+// PointCount returns the number of points of the stroke.
+func (s Stroke) PointCount() int {
+	return len(s.Segments)
+}
+
+// Simplify returns a copy of the stroke without the points closer than
+// tolerance to the path, using the Ramer-Douglas-Peucker algorithm.
+// The first and last points are always kept.
+func (s Stroke) Simplify(tolerance float64) Stroke {
+	if len(s.Segments) < 3 || tolerance <= 0 {
+		return s.transform(func(seg Segment) Segment { return seg })
+	}
+
+	keep := make([]bool, len(s.Segments))
+	keep[0], keep[len(s.Segments)-1] = true, true
+
+	// iterative to avoid deep recursions on long strokes
+	type span struct{ first, last int }
+	stack := []span{{0, len(s.Segments) - 1}}
+	for len(stack) > 0 {
+		sp := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		index, dmax := -1, tolerance
+		for i := sp.first + 1; i < sp.last; i++ {
+			d := segmentDistance(s.Segments[i], s.Segments[sp.first], s.Segments[sp.last])
+			if d > dmax {
+				index, dmax = i, d
+			}
+		}
+		if index >= 0 {
+			keep[index] = true
+			stack = append(stack, span{sp.first, index}, span{index, sp.last})
+		}
+	}
+
+	out := s
+	out.Segments = nil
+	for i, seg := range s.Segments {
+		if keep[i] {
+			out.Segments = append(out.Segments, seg)
+		}
+	}
+	return out
+}
+
+// Translate returns a copy of the stroke moved by dx, dy.
+func (s Stroke) Translate(dx, dy float32) Stroke {
+	return s.transform(func(seg Segment) Segment {
+		seg.X += dx
+		seg.Y += dy
+		return seg
+	})
+}
+
+// Scale returns a copy of the stroke scaled from the origin of the page.
+// Widths are scaled by the geometric mean of both factors.
+func (s Stroke) Scale(sx, sy float32) Stroke {
+	k := float32(math.Sqrt(math.Abs(float64(sx) * float64(sy))))
+	return s.transform(func(seg Segment) Segment {
+		seg.X *= sx
+		seg.Y *= sy
+		seg.Width *= k
+		return seg
+	})
+}
+
+// Rotate returns a copy of the stroke rotated by angle radians around
+// the point cx, cy. As the y axis points down, positive angles turn clockwise.
+func (s Stroke) Rotate(angle float64, cx, cy float32) Stroke {
+	sin, cos := math.Sincos(angle)
+	return s.transform(func(seg Segment) Segment {
+		x, y := float64(seg.X-cx), float64(seg.Y-cy)
+		seg.X = float32(x*cos-y*sin) + cx
+		seg.Y = float32(x*sin+y*cos) + cy
+		seg.Direction = float32(math.Mod(float64(seg.Direction)+angle+2*math.Pi, 2*math.Pi))
+		return seg
+	})
+}
+
+func (s Stroke) transform(f func(Segment) Segment) Stroke {
+	out := s
+	out.Segments = make([]Segment, len(s.Segments))
+	for i, seg := range s.Segments {
+		out.Segments[i] = f(seg)
+	}
+	return out
+}
+
+// BoundingBox returns the area inked by all the strokes of the layer.
+// It is the zero Rect for a layer without segments.
+func (l Layer) BoundingBox() Rect {
+	var r Rect
+	first := true
+	for _, s := range l.Strokes {
+		if len(s.Segments) == 0 {
+			continue
+		}
+		if first {
+			r, first = s.BoundingBox(), false
+		} else {
+			r = r.Union(s.BoundingBox())
+		}
+	}
+	return r
+}
+
+// Length returns the total length of the strokes of the layer.
+func (l Layer) Length() float64 {
+	var length float64
+	for _, s := range l.Strokes {
+		length += s.Length()
+	}
+	return length
+}
+
+// PointCount returns the number of points of all the strokes of the layer.
+func (l Layer) PointCount() int {
+	var n int
+	for _, s := range l.Strokes {
+		n += s.PointCount()
+	}
+	return n
+}
+
+// Simplify returns a copy of the layer with all its strokes simplified.
+func (l Layer) Simplify(tolerance float64) Layer {
+	return l.transform(func(s Stroke) Stroke { return s.Simplify(tolerance) })
+}
+
+// Translate returns a copy of the layer moved by dx, dy.
+func (l Layer) Translate(dx, dy float32) Layer {
+	return l.transform(func(s Stroke) Stroke { return s.Translate(dx, dy) })
+}
+
+// Scale returns a copy of the layer scaled from the origin of the page.
+func (l Layer) Scale(sx, sy float32) Layer {
+	return l.transform(func(s Stroke) Stroke { return s.Scale(sx, sy) })
+}
+
+// Rotate returns a copy of the layer rotated by angle radians around cx, cy.
+func (l Layer) Rotate(angle float64, cx, cy float32) Layer {
+	return l.transform(func(s Stroke) Stroke { return s.Rotate(angle, cx, cy) })
+}
+
+func (l Layer) transform(f func(Stroke) Stroke) Layer {
+	out := l
+	out.Strokes = make([]Stroke, len(l.Strokes))
+	for i, s := range l.Strokes {
+		out.Strokes[i] = f(s)
+	}
+	return out
+}
+
+func distance(a, b Segment) float64 {
+	return math.Hypot(float64(b.X-a.X), float64(b.Y-a.Y))
+}
+
+// segmentDistance returns the distance from p to the segment [a, b].
+func segmentDistance(p, a, b Segment) float64 {
+	dx, dy := float64(b.X-a.X), float64(b.Y-a.Y)
+	l2 := dx*dx + dy*dy
+	if l2 == 0 {
+		return distance(p, a)
+	}
+
+	t := (float64(p.X-a.X)*dx + float64(p.Y-a.Y)*dy) / l2
+	t = math.Max(0, math.Min(1, t))
+	return math.Hypot(float64(a.X)+t*dx-float64(p.X), float64(a.Y)+t*dy-float64(p.Y))
+}
+
+func min32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,97 @@
+package rm
+
+import (
+	"math"
+	"testing"
+)
+
+func line(points ...float32) Stroke {
+	s := Stroke{BrushType: Fineliner}
+	for i := 0; i < len(points); i += 2 {
+		s.Segments = append(s.Segments, Segment{X: points[i], Y: points[i+1], Width: 2})
+	}
+	return s
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-4
+}
+
+func TestStrokeMeasures(t *testing.T) {
+	s := line(0, 0, 3, 4, 3, 10)
+
+	if s.PointCount() != 3 {
+		t.Errorf("got %d points", s.PointCount())
+	}
+	if !near(s.Length(), 11) {
+		t.Errorf("got length %f, want 11", s.Length())
+	}
+	if bbox := s.BoundingBox(); bbox != (Rect{MinX: -1, MinY: -1, MaxX: 4, MaxY: 11}) {
+		t.Errorf("wrong bounding box %+v", bbox)
+	}
+
+	l := Layer{Strokes: []Stroke{s, line(10, 20), {}}}
+	if l.PointCount() != 4 || !near(l.Length(), 11) {
+		t.Errorf("wrong layer measures %d %f", l.PointCount(), l.Length())
+	}
+	if bbox := l.BoundingBox(); bbox != (Rect{MinX: -1, MinY: -1, MaxX: 11, MaxY: 21}) {
+		t.Errorf("wrong layer bounding box %+v", bbox)
+	}
+
+	// a dot at the origin has a zero box which still counts
+	dot := Stroke{Segments: []Segment{{X: 0, Y: 0}}}
+	l = Layer{Strokes: []Stroke{{}, line(10, 20), dot}}
+	if bbox := l.BoundingBox(); bbox != (Rect{MinX: 0, MinY: 0, MaxX: 11, MaxY: 21}) {
+		t.Errorf("wrong layer bounding box with a dot %+v", bbox)
+	}
+	if bbox := (Layer{Strokes: []Stroke{{}}}).BoundingBox(); bbox != (Rect{}) {
+		t.Errorf("wrong bounding box of a layer without segments %+v", bbox)
+	}
+}
+
+func TestStrokeSimplify(t *testing.T) {
+	s := line(0, 0, 1, 0.1, 2, -0.1, 3, 5, 4, 6, 5, 7, 6, 8)
+
+	simple := s.Simplify(0.5)
+	want := line(0, 0, 2, -0.1, 3, 5, 6, 8)
+	if len(simple.Segments) != len(want.Segments) {
+		t.Fatalf("got %+v, want %+v", simple.Segments, want.Segments)
+	}
+	for i := range want.Segments {
+		if simple.Segments[i] != want.Segments[i] {
+			t.Errorf("point %d: got %+v, want %+v", i, simple.Segments[i], want.Segments[i])
+		}
+	}
+
+	if len(s.Segments) != 7 {
+		t.Error("the original stroke was modified")
+	}
+}
+
+func TestStrokeTransforms(t *testing.T) {
+	s := line(10, 0, 20, 0)
+
+	moved := s.Translate(5, 5)
+	if moved.Segments[0].X != 15 || moved.Segments[1].Y != 5 || s.Segments[0].X != 10 {
+		t.Errorf("wrong translation %+v", moved.Segments)
+	}
+
+	scaled := s.Scale(2, 2)
+	if scaled.Segments[1].X != 40 || scaled.Segments[1].Width != 4 {
+		t.Errorf("wrong scale %+v", scaled.Segments)
+	}
+
+	rotated := s.Rotate(math.Pi/2, 0, 0)
+	p := rotated.Segments[1]
+	if !near(float64(p.X), 0) || !near(float64(p.Y), 20) {
+		t.Errorf("wrong rotation %+v", p)
+	}
+	if !near(float64(p.Direction), math.Pi/2) {
+		t.Errorf("direction not rotated %+v", p)
+	}
+
+	l := Layer{Strokes: []Stroke{s}}.Translate(1, 1)
+	if l.Strokes[0].Segments[0].X != 11 || s.Segments[0].X != 10 {
+		t.Error("wrong layer translation")
+	}
+}
@@ -0,0 +1,168 @@
+package rm
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// The JSON representation of a page is meant to be diffed and read by
+// tools: fields are always written in the same order and every value
+// needed by MarshalBinary is kept, so that decoding the JSON of a v3 or
+// v5 page and marshalling it gives back the original bytes.
+//
+// The layers of a v6 page are written like the ones of older versions,
+// and its scene is kept as the base64 encoding of its blocks. A page whose
+// scene is removed from the JSON is written from its layers.
+
+type jsonRm struct {
+	Version string      `json:"version"`
+	Layers  []jsonLayer `json:"layers"`
+	Scene   []byte      `json:"scene,omitempty"`
+}
+
+type jsonLayer struct {
+	Name    string       `json:"name,omitempty"`
+	Strokes []jsonStroke `json:"strokes"`
+}
+
+type jsonStroke struct {
+	BrushType  BrushType     `json:"brushType"`
+	BrushColor BrushColor    `json:"brushColor"`
+	Padding    uint32        `json:"padding"`
+	BrushSize  BrushSize     `json:"brushSize"`
+	Unknown    float32       `json:"unknown"`
+	Segments   []jsonSegment `json:"segments"`
+}
+
+type jsonSegment struct {
+	X         float32 `json:"x"`
+	Y         float32 `json:"y"`
+	Speed     float32 `json:"speed"`
+	Direction float32 `json:"direction"`
+	Width     float32 `json:"width"`
+	Pressure  float32 `json:"pressure"`
+}
+
+var versionNames = map[Version]string{V3: "v3", V5: "v5", V6: "v6"}
+
+// MarshalJSON implements json.Marshaler.
+func (rm *Rm) MarshalJSON() ([]byte, error) {
+	version, ok := versionNames[rm.Version]
+	if !ok {
+		return nil, fmt.Errorf("Unknown version %d", rm.Version)
+	}
+
+	j := jsonRm{Version: version, Layers: make([]jsonLayer, len(rm.Layers))}
+	for i, layer := range rm.Layers {
+		jl := jsonLayer{Name: layer.Name, Strokes: make([]jsonStroke, len(layer.Strokes))}
+		for k, stroke := range layer.Strokes {
+			js := jsonStroke{
+				BrushType:  stroke.BrushType,
+				BrushColor: stroke.BrushColor,
+				Padding:    stroke.Width,
+				BrushSize:  stroke.BrushSize,
+				Unknown:    stroke.Unknown,
+				Segments:   make([]jsonSegment, len(stroke.Segments)),
+			}
+			for n, s := range stroke.Segments {
+				js.Segments[n] = jsonSegment(s)
+			}
+			jl.Strokes[k] = js
+		}
+		j.Layers[i] = jl
+	}
+
+	if rm.Version == V6 && rm.Scene != nil {
+		data, err := rm.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		j.Scene = data[HeaderLen:]
+	}
+
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (rm *Rm) UnmarshalJSON(data []byte) error {
+	var j jsonRm
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	version := Version(-1)
+	for v, name := range versionNames {
+		if name == j.Version {
+			version = v
+		}
+	}
+	if version < 0 {
+		return fmt.Errorf("Unknown version %q", j.Version)
+	}
+
+	var scene *Scene
+	if len(j.Scene) > 0 {
+		if version != V6 {
+			return fmt.Errorf("Scene found in a %s page", j.Version)
+		}
+		page := New()
+		if err := page.UnmarshalBinary(append([]byte(HeaderV6), j.Scene...)); err != nil {
+			return err
+		}
+		scene = page.Scene
+	}
+
+	layers := make([]Layer, len(j.Layers))
+	for i, jl := range j.Layers {
+		layer := Layer{Name: jl.Name, Strokes: make([]Stroke, len(jl.Strokes))}
+		for k, js := range jl.Strokes {
+			stroke := Stroke{
+				BrushType:  js.BrushType,
+				BrushColor: js.BrushColor,
+				Unknown:    js.Unknown,
+				Width:      js.Padding,
+				BrushSize:  js.BrushSize,
+				Segments:   make([]Segment, len(js.Segments)),
+			}
+			for n, s := range js.Segments {
+				stroke.Segments[n] = Segment(s)
+			}
+			layer.Strokes[k] = stroke
+		}
+		layers[i] = layer
+	}
+
+	rm.Version = version
+	rm.Layers = layers
+	rm.Scene = scene
+	return nil
+}
+
+// WriteCSV writes one record per segment of the page, after a header line.
+func WriteCSV(w io.Writer, page *Rm) error {
+	cw := csv.NewWriter(w)
+
+	cw.Write([]string{"layer", "stroke", "segment", "brushType", "brushColor", "brushSize",
+		"x", "y", "speed", "direction", "width", "pressure"})
+
+	f := func(v float32) string {
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	}
+	for i, layer := range page.Layers {
+		for k, stroke := range layer.Strokes {
+			for n, s := range stroke.Segments {
+				cw.Write([]string{
+					strconv.Itoa(i), strconv.Itoa(k), strconv.Itoa(n),
+					strconv.Itoa(int(stroke.BrushType)), stroke.BrushColor.String(), f(float32(stroke.BrushSize)),
+					f(s.X), f(s.Y), f(s.Speed), f(s.Direction), f(s.Width), f(s.Pressure),
+				})
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,96 @@
+package rm
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+)
+
+func testJSONRoundTrip(t *testing.T, data []byte) {
+	page := New()
+	if err := page.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := json.MarshalIndent(page, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := New()
+	if err := json.Unmarshal(j, decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := json.MarshalIndent(decoded, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(j, again) {
+		t.Error("json is not stable")
+	}
+
+	out, err := decoded.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, out) {
+		t.Errorf("round trip through json differs: got %d bytes, want %d", len(out), len(data))
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, fn := range []string{"test_v3.rm", "test_v5.rm"} {
+		t.Run(fn, func(t *testing.T) {
+			data, err := ioutil.ReadFile(fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testJSONRoundTrip(t, data)
+		})
+	}
+
+	t.Run("v6", func(t *testing.T) {
+		page := &Rm{Version: V6, Layers: []Layer{{Name: "Sketch", Strokes: []Stroke{
+			{BrushType: FinelinerV5, BrushColor: Blue, Segments: []Segment{{X: 10, Y: 20, Width: 2, Pressure: 0.5}, {X: 30, Y: 40, Width: 2}}},
+		}}}}
+		data, err := page.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		testJSONRoundTrip(t, data)
+	})
+}
+
+func TestJSONInvalid(t *testing.T) {
+	if err := json.Unmarshal([]byte(`{"version":"v9","layers":[]}`), New()); err == nil {
+		t.Error("expected an error for an unknown version")
+	}
+	if err := json.Unmarshal([]byte(`{"version":"v5","layers":[],"scene":"AAAA"}`), New()); err == nil {
+		t.Error("expected an error for a scene in a v5 page")
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	page := &Rm{Layers: []Layer{{Strokes: []Stroke{
+		{BrushType: Pencil, BrushColor: Red, Segments: []Segment{{X: 1.5, Y: 2}, {X: 3, Y: 4}}},
+	}}}}
+
+	var out bytes.Buffer
+	if err := WriteCSV(&out, page); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3", len(records))
+	}
+	if r := records[1]; r[4] != "red" || r[6] != "1.5" {
+		t.Errorf("wrong record %v", r)
+	}
+}
@@ -1,8 +1,126 @@
 package rm
 
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
 // MarshalBinary implements encoding.MarshalBinary for
-// transforming a Rm page into bytes
-// TODO
+// transforming a Rm page into bytes.
+// A v6 page is written from its Scene, or from a new scene
+// built from its Layers when Scene is nil.
 func (rm *Rm) MarshalBinary() (data []byte, err error) {
-	return nil, nil
+	w := newWriter(rm.Version)
+	if err := w.writeHeader(); err != nil {
+		return nil, err
+	}
+
+	if rm.Version == V6 {
+		scene := rm.Scene
+		if scene == nil {
+			scene = NewScene(rm.Layers)
+		}
+		if err := w.marshalScene(scene); err != nil {
+			return nil, err
+		}
+		return w.Bytes(), nil
+	}
+
+	if err := w.writeNumber(uint32(len(rm.Layers))); err != nil {
+		return nil, err
+	}
+
+	for _, layer := range rm.Layers {
+		if err := w.writeNumber(uint32(len(layer.Strokes))); err != nil {
+			return nil, err
+		}
+
+		for _, line := range layer.Strokes {
+			if err := w.writeStroke(line); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return w.Bytes(), nil
+}
+
+type writer struct {
+	bytes.Buffer
+	version Version
+}
+
+func newWriter(version Version) writer {
+	return writer{bytes.Buffer{}, version}
+}
+
+func (w *writer) writeHeader() error {
+	var header string
+
+	switch w.version {
+	case V6:
+		header = HeaderV6
+	case V5:
+		header = HeaderV5
+	case V3:
+		header = HeaderV3
+	default:
+		return fmt.Errorf("Unknown version")
+	}
+
+	_, err := w.WriteString(header)
+	return err
+}
+
+func (w *writer) writeNumber(nb uint32) error {
+	if err := binary.Write(w, binary.LittleEndian, nb); err != nil {
+		return fmt.Errorf("Wrong number written")
+	}
+	return nil
+}
+
+func (w *writer) writeStroke(line Stroke) error {
+	if err := binary.Write(w, binary.LittleEndian, line.BrushType); err != nil {
+		return fmt.Errorf("Failed to write line")
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, line.BrushColor); err != nil {
+		return fmt.Errorf("Failed to write line")
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, line.Width); err != nil {
+		return fmt.Errorf("Failed to write line")
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, line.BrushSize); err != nil {
+		return fmt.Errorf("Failed to write line")
+	}
+
+	// this new attribute has been added in v5
+	if w.version == V5 {
+		if err := binary.Write(w, binary.LittleEndian, line.Unknown); err != nil {
+			return fmt.Errorf("Failed to write line")
+		}
+	}
+
+	if err := w.writeNumber(uint32(len(line.Segments))); err != nil {
+		return err
+	}
+
+	for _, point := range line.Segments {
+		if err := w.writeSegment(point); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *writer) writeSegment(point Segment) error {
+	if err := binary.Write(w, binary.LittleEndian, point); err != nil {
+		return fmt.Errorf("Failed to write point")
+	}
+
+	return nil
 }
@@ -0,0 +1,54 @@
+package rm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func testMarshalBinary(t *testing.T, fn string) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatalf("can't open %s file", fn)
+	}
+
+	rm := New()
+	if err := rm.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := rm.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, out) {
+		t.Errorf("marshaled %s differs from original (%d bytes, want %d)", fn, len(out), len(b))
+	}
+}
+
+func TestMarshalBinaryV5(t *testing.T) {
+	testMarshalBinary(t, "test_v5.rm")
+}
+
+func TestMarshalBinaryV3(t *testing.T) {
+	testMarshalBinary(t, "test_v3.rm")
+}
+
+func TestMarshalBinaryEmpty(t *testing.T) {
+	rm := &Rm{Version: V5, Layers: []Layer{{}}}
+
+	b, err := rm.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := New()
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Version != V5 || len(got.Layers) != 1 || len(got.Layers[0].Strokes) != 0 {
+		t.Error("empty page not preserved")
+	}
+}
@@ -0,0 +1,410 @@
+package rm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// marshalScene writes the blocks following a v6 header.
+func (w *writer) marshalScene(scene *Scene) error {
+	for _, block := range scene.Blocks {
+		if err := w.writeBlock(block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *writer) writeBlock(block Block) error {
+	sw := &sceneWriter{}
+
+	var err error
+	switch b := block.(type) {
+	case *AuthorIDsBlock:
+		err = sw.writeAuthorIDs(b)
+	case *MigrationInfoBlock:
+		err = sw.writeMigrationInfo(b)
+	case *PageInfoBlock:
+		err = sw.writePageInfo(b)
+	case *SceneInfoBlock:
+		err = sw.writeSceneInfo(b)
+	case *SceneTreeBlock:
+		err = sw.writeSceneTree(b)
+	case *TreeNodeBlock:
+		err = sw.writeTreeNode(b)
+	case *SceneLineItemBlock:
+		err = sw.writeSceneItem(b.SceneItem, func(sub *sceneWriter) error {
+			return sub.writeLine(b.Line, b.Version)
+		})
+	case *SceneGroupItemBlock:
+		err = sw.writeSceneItem(b.SceneItem, func(sub *sceneWriter) error {
+			sub.writeID(2, b.NodeID)
+			return nil
+		})
+	case *SceneGlyphItemBlock:
+		err = sw.writeSceneItem(b.SceneItem, func(sub *sceneWriter) error {
+			return sub.writeGlyphRange(b.Glyph)
+		})
+	case *SceneTextItemBlock:
+		err = sw.writeSceneItem(b.SceneItem, nil)
+	case *SceneTombstoneItemBlock:
+		err = sw.writeSceneItem(b.SceneItem, nil)
+	case *RootTextBlock:
+		err = sw.writeRootText(b)
+	case *UnknownBlock:
+		sw.Write(b.Data)
+	default:
+		err = fmt.Errorf("Unknown block type %d", block.Type())
+	}
+	if err != nil {
+		return err
+	}
+
+	info := block.Info()
+	sw.Write(info.Extra)
+
+	header := struct {
+		Length     uint32
+		Unknown    uint8
+		MinVersion uint8
+		Version    uint8
+		Type       BlockType
+	}{uint32(sw.Len()), 0, info.MinVersion, info.Version, block.Type()}
+
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return fmt.Errorf("Failed to write block header")
+	}
+	_, err = w.Write(sw.Bytes())
+	return err
+}
+
+// A sceneWriter writes the tagged values of a block or a subblock.
+// Writing to a bytes.Buffer can't fail so most methods don't return errors.
+type sceneWriter struct {
+	bytes.Buffer
+}
+
+func (s *sceneWriter) writeRaw(data interface{}) {
+	binary.Write(s, binary.LittleEndian, data)
+}
+
+func (s *sceneWriter) writeVaruint(v uint64) {
+	for v >= 0x80 {
+		s.WriteByte(byte(v) | 0x80)
+		v >>= 7
+	}
+	s.WriteByte(byte(v))
+}
+
+func (s *sceneWriter) writeCrdtID(id CrdtID) {
+	s.WriteByte(id.Part1)
+	s.writeVaruint(id.Part2)
+}
+
+func (s *sceneWriter) writeTag(index uint64, tagType uint8) {
+	s.writeVaruint(index<<4 | uint64(tagType))
+}
+
+func (s *sceneWriter) writeID(index uint64, id CrdtID) {
+	s.writeTag(index, tagID)
+	s.writeCrdtID(id)
+}
+
+func (s *sceneWriter) writeBool(index uint64, b bool) {
+	s.writeTag(index, tagByte1)
+	if b {
+		s.WriteByte(1)
+	} else {
+		s.WriteByte(0)
+	}
+}
+
+func (s *sceneWriter) writeByte(index uint64, b uint8) {
+	s.writeTag(index, tagByte1)
+	s.WriteByte(b)
+}
+
+func (s *sceneWriter) writeInt(index uint64, n uint32) {
+	s.writeTag(index, tagByte4)
+	s.writeRaw(n)
+}
+
+func (s *sceneWriter) writeFloat(index uint64, f float32) {
+	s.writeTag(index, tagByte4)
+	s.writeRaw(f)
+}
+
+func (s *sceneWriter) writeDouble(index uint64, f float64) {
+	s.writeTag(index, tagByte8)
+	s.writeRaw(f)
+}
+
+// writeSubblock writes what fn writes prefixed by its length.
+func (s *sceneWriter) writeSubblock(index uint64, fn func(sub *sceneWriter) error) error {
+	sub := &sceneWriter{}
+	if err := fn(sub); err != nil {
+		return err
+	}
+	s.writeTag(index, tagLength4)
+	s.writeRaw(uint32(sub.Len()))
+	s.Write(sub.Bytes())
+	return nil
+}
+
+func (s *sceneWriter) writeString(index uint64, str string) {
+	s.writeSubblock(index, func(sub *sceneWriter) error {
+		sub.writeStringData(str)
+		return nil
+	})
+}
+
+func (s *sceneWriter) writeStringData(str string) {
+	s.writeVaruint(uint64(len(str)))
+	isASCII := uint8(1)
+	for i := 0; i < len(str); i++ {
+		if str[i] >= 0x80 {
+			isASCII = 0
+			break
+		}
+	}
+	s.WriteByte(isASCII)
+	s.WriteString(str)
+}
+
+func (s *sceneWriter) writeLwwBool(index uint64, v LwwBool) {
+	s.writeSubblock(index, func(sub *sceneWriter) error {
+		sub.writeID(1, v.Timestamp)
+		sub.writeBool(2, v.Value)
+		return nil
+	})
+}
+
+func (s *sceneWriter) writeLwwByte(index uint64, v LwwByte) {
+	s.writeSubblock(index, func(sub *sceneWriter) error {
+		sub.writeID(1, v.Timestamp)
+		sub.writeByte(2, v.Value)
+		return nil
+	})
+}
+
+func (s *sceneWriter) writeLwwFloat(index uint64, v LwwFloat) {
+	s.writeSubblock(index, func(sub *sceneWriter) error {
+		sub.writeID(1, v.Timestamp)
+		sub.writeFloat(2, v.Value)
+		return nil
+	})
+}
+
+func (s *sceneWriter) writeLwwID(index uint64, v LwwID) {
+	s.writeSubblock(index, func(sub *sceneWriter) error {
+		sub.writeID(1, v.Timestamp)
+		sub.writeID(2, v.Value)
+		return nil
+	})
+}
+
+func (s *sceneWriter) writeLwwString(index uint64, v LwwString) {
+	s.writeSubblock(index, func(sub *sceneWriter) error {
+		sub.writeID(1, v.Timestamp)
+		sub.writeString(2, v.Value)
+		return nil
+	})
+}
+
+func (s *sceneWriter) writeAuthorIDs(b *AuthorIDsBlock) error {
+	s.writeVaruint(uint64(len(b.Authors)))
+	for _, author := range b.Authors {
+		s.writeSubblock(0, func(sub *sceneWriter) error {
+			sub.writeVaruint(uint64(len(author.UUID)))
+			sub.writeRaw(author.UUID)
+			sub.writeRaw(author.ID)
+			return nil
+		})
+	}
+	return nil
+}
+
+func (s *sceneWriter) writeMigrationInfo(b *MigrationInfoBlock) error {
+	s.writeID(1, b.MigrationID)
+	s.writeBool(2, b.IsDevice)
+	return nil
+}
+
+func (s *sceneWriter) writePageInfo(b *PageInfoBlock) error {
+	s.writeInt(1, b.LoadsCount)
+	s.writeInt(2, b.MergesCount)
+	s.writeInt(3, b.TextCharsCount)
+	s.writeInt(4, b.TextLinesCount)
+	return nil
+}
+
+func (s *sceneWriter) writeSceneInfo(b *SceneInfoBlock) error {
+	s.writeLwwID(1, b.CurrentLayer)
+	if b.BackgroundVisible != nil {
+		s.writeLwwBool(2, *b.BackgroundVisible)
+	}
+	if b.RootDocumentVisible != nil {
+		s.writeLwwBool(3, *b.RootDocumentVisible)
+	}
+	return nil
+}
+
+func (s *sceneWriter) writeSceneTree(b *SceneTreeBlock) error {
+	s.writeID(1, b.TreeID)
+	s.writeID(2, b.NodeID)
+	s.writeBool(3, b.IsUpdate)
+	return s.writeSubblock(4, func(sub *sceneWriter) error {
+		sub.writeID(1, b.ParentID)
+		return nil
+	})
+}
+
+func (s *sceneWriter) writeTreeNode(b *TreeNodeBlock) error {
+	s.writeID(1, b.NodeID)
+	s.writeLwwString(2, b.Label)
+	s.writeLwwBool(3, b.Visible)
+	if b.Anchor != nil {
+		s.writeLwwID(7, b.Anchor.ID)
+		s.writeLwwByte(8, b.Anchor.Type)
+		s.writeLwwFloat(9, b.Anchor.Threshold)
+		s.writeLwwFloat(10, b.Anchor.OriginX)
+	}
+	return nil
+}
+
+// writeSceneItem writes the fields common to scene items, value
+// writes the specific part of the value if the item has one.
+func (s *sceneWriter) writeSceneItem(item SceneItem, value func(sub *sceneWriter) error) error {
+	s.writeID(1, item.ParentID)
+	s.writeID(2, item.ItemID)
+	s.writeID(3, item.LeftID)
+	s.writeID(4, item.RightID)
+	s.writeInt(5, item.DeletedLength)
+
+	if !item.HasValue {
+		return nil
+	}
+
+	return s.writeSubblock(6, func(sub *sceneWriter) error {
+		sub.WriteByte(item.ItemType)
+		if value != nil {
+			if err := value(sub); err != nil {
+				return err
+			}
+		}
+		sub.Write(item.Extra)
+		return nil
+	})
+}
+
+func (s *sceneWriter) writeLine(line Line, version uint8) error {
+	s.writeInt(1, uint32(line.Tool))
+	s.writeInt(2, uint32(line.Color))
+	s.writeDouble(3, line.ThicknessScale)
+	s.writeFloat(4, line.StartingLength)
+
+	err := s.writeSubblock(5, func(sub *sceneWriter) error {
+		for _, p := range line.Points {
+			if version == 1 {
+				seg := p.Segment()
+				sub.writeRaw([]float32{
+					seg.X - float32(Width)/2, seg.Y, seg.Speed, seg.Direction, seg.Width, seg.Pressure,
+				})
+			} else {
+				sub.writeRaw(p)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.writeID(6, line.Timestamp)
+	if line.MoveID != nil {
+		s.writeID(7, *line.MoveID)
+	}
+	return nil
+}
+
+func (s *sceneWriter) writeGlyphRange(g GlyphRange) error {
+	if g.Start != nil {
+		s.writeInt(2, *g.Start)
+	}
+	s.writeInt(3, g.Length)
+	s.writeInt(4, uint32(g.Color))
+	s.writeString(5, g.Text)
+	return s.writeSubblock(6, func(sub *sceneWriter) error {
+		sub.writeVaruint(uint64(len(g.Rects)))
+		for _, r := range g.Rects {
+			sub.writeRaw(r)
+		}
+		return nil
+	})
+}
+
+func (s *sceneWriter) writeRootText(b *RootTextBlock) error {
+	s.writeID(1, b.BlockID)
+
+	err := s.writeSubblock(2, func(sub *sceneWriter) error {
+		sub.writeSubblock(1, func(sub *sceneWriter) error {
+			return sub.writeSubblock(1, func(sub *sceneWriter) error {
+				sub.writeVaruint(uint64(len(b.Items)))
+				for _, item := range b.Items {
+					sub.writeTextItem(item)
+				}
+				return nil
+			})
+		})
+		return sub.writeSubblock(2, func(sub *sceneWriter) error {
+			return sub.writeSubblock(1, func(sub *sceneWriter) error {
+				sub.writeVaruint(uint64(len(b.Formats)))
+				for _, f := range b.Formats {
+					sub.writeTextFormat(f)
+				}
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	s.writeSubblock(3, func(sub *sceneWriter) error {
+		sub.writeRaw(b.PosX)
+		sub.writeRaw(b.PosY)
+		return nil
+	})
+	s.writeFloat(4, b.Width)
+	return nil
+}
+
+func (s *sceneWriter) writeTextItem(item TextItem) {
+	s.writeSubblock(0, func(sub *sceneWriter) error {
+		sub.writeID(2, item.ItemID)
+		sub.writeID(3, item.LeftID)
+		sub.writeID(4, item.RightID)
+		sub.writeInt(5, item.DeletedLength)
+		if !item.HasValue {
+			return nil
+		}
+		return sub.writeSubblock(6, func(sub *sceneWriter) error {
+			sub.writeStringData(item.Text)
+			if item.HasFormat {
+				sub.writeInt(2, item.Format)
+			}
+			return nil
+		})
+	})
+}
+
+func (s *sceneWriter) writeTextFormat(f TextFormat) {
+	s.writeCrdtID(f.CharID)
+	s.writeID(1, f.Timestamp)
+	s.writeSubblock(2, func(sub *sceneWriter) error {
+		sub.WriteByte(f.Unknown)
+		sub.WriteByte(uint8(f.Style))
+		return nil
+	})
+}
@@ -9,7 +9,14 @@
 // https://github.com/ax3l/lines-are-beautiful
 //
 // To mention that the format has since evolve to a new version labeled as v3 in the
-// header. This implementation is targeting this new version.
+// header. This implementation is targeting this new version, as well as v5 which
+// only adds a field to each stroke.
+//
+// Firmware 3.x writes a v6 file which is a completely different tagged-block
+// format describing a scene tree (see scene.go). Ricky Lupton's rmscene
+// documents it at https://github.com/ricklupton/rmscene.
+// A v6 page is decoded both into its raw Scene and, where possible, into the
+// same Layers/Strokes model used by the older versions.
 //
 // As Ben Johnson says, "In the Go standard library, we use the term encoding
 // and marshaling for two separate but related ideas. An encoder in Go is an object
@@ -20,6 +27,8 @@
 // We will follow this convention and refer to marshaling for this encoder/decoder
 // because we want to transform a .rm binary into a bounded in-memory representation
 // of a .rm file.
+// For very large pages, a Decoder reading a stream one stroke at a time
+// is provided as well, following the same convention.
 //
 // To try to be as idiomatic as possible, this package implements the two following interfaces
 // of the default encoding package (https://golang.org/pkg/encoding/).
@@ -44,12 +53,14 @@ type Version int
 const (
 	V3 Version = iota
 	V5
+	V6
 )
 
 // Header starting a .rm binary file. This can help recognizing a .rm file.
 const (
 	HeaderV3  = "reMarkable .lines file, version=3          "
 	HeaderV5  = "reMarkable .lines file, version=5          "
+	HeaderV6  = "reMarkable .lines file, version=6          "
 	HeaderLen = 43
 )
 
@@ -59,10 +70,12 @@ const (
 	Height int = 1872
 )
 
-// BrushColor defines the 3 colors of the brush.
+// BrushColor is the color of a brush. The first three colors are the
+// ones of the older firmwares, the others are defined in color.go and
+// mapped to RGBA colors by a Palette.
 type BrushColor uint32
 
-// Mapping of the three colors.
+// The colors of the older firmwares.
 const (
 	Black BrushColor = 0
 	Grey  BrushColor = 1
@@ -95,6 +108,10 @@ const (
 	MarkerV5           BrushType = 16
 	FinelinerV5        BrushType = 17
 	HighlighterV5      BrushType = 18
+
+	// v6 brings new brush types
+	Calligraphy BrushType = 21
+	Shader      BrushType = 23
 )
 
 // BrushSize represents the base brush sizes.
@@ -112,10 +129,16 @@ const (
 type Rm struct {
 	Version Version
 	Layers  []Layer
+	// Scene holds the full block structure of a v6 page.
+	// It is nil for older versions.
+	Scene *Scene
 }
 
 // A Layer contains lines.
 type Layer struct {
+	// Name is only stored in the .rm file since v6,
+	// older versions keep it in the page metadata.
+	Name    string
 	Strokes []Stroke
 }
 
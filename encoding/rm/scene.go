@@ -0,0 +1,666 @@
+package rm
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// BlockType identifies the kind of a v6 block.
+type BlockType uint8
+
+// Mappings for v6 block types.
+const (
+	MigrationInfoBlockType      BlockType = 0x00
+	SceneTreeBlockType          BlockType = 0x01
+	TreeNodeBlockType           BlockType = 0x02
+	SceneGlyphItemBlockType     BlockType = 0x03
+	SceneGroupItemBlockType     BlockType = 0x04
+	SceneLineItemBlockType      BlockType = 0x05
+	SceneTextItemBlockType      BlockType = 0x06
+	RootTextBlockType           BlockType = 0x07
+	SceneTombstoneItemBlockType BlockType = 0x08
+	AuthorIDsBlockType          BlockType = 0x09
+	PageInfoBlockType           BlockType = 0x0a
+	SceneInfoBlockType          BlockType = 0x0d
+)
+
+// ParagraphStyle is the formatting of a paragraph of typed text.
+type ParagraphStyle uint8
+
+// Mappings for paragraph styles.
+const (
+	BasicStyle           ParagraphStyle = 0
+	PlainStyle           ParagraphStyle = 1
+	HeadingStyle         ParagraphStyle = 2
+	BoldStyle            ParagraphStyle = 3
+	BulletStyle          ParagraphStyle = 4
+	Bullet2Style         ParagraphStyle = 5
+	CheckboxStyle        ParagraphStyle = 6
+	CheckboxCheckedStyle ParagraphStyle = 7
+)
+
+// Item types stored in the value of scene items.
+const (
+	glyphItemType     uint8 = 0x01
+	groupItemType     uint8 = 0x02
+	lineItemType      uint8 = 0x03
+	textItemType      uint8 = 0x06
+	tombstoneItemType uint8 = 0x08
+)
+
+// CrdtID identifies a node or an item of a v6 scene.
+// Part1 is the author of the item and Part2 a counter.
+type CrdtID struct {
+	Part1 uint8
+	Part2 uint64
+}
+
+// RootID is the id of the root node of the scene tree,
+// layers are its direct children.
+var RootID = CrdtID{0, 1}
+
+// EndID marks the ends of a CRDT sequence.
+var EndID = CrdtID{0, 0}
+
+// A Scene is the content of a v6 file: a list of blocks
+// describing a tree of groups (layers) holding items (lines, text, glyphs).
+type Scene struct {
+	Blocks []Block
+}
+
+// A Block is one of the v6 blocks. Its concrete type is one of the
+// *Block structs of this package, or *UnknownBlock when the block
+// type isn't supported.
+type Block interface {
+	Type() BlockType
+	Info() *BlockInfo
+}
+
+// BlockInfo holds the header fields shared by every block.
+type BlockInfo struct {
+	MinVersion uint8
+	Version    uint8
+	// Extra holds any trailing bytes of the block that weren't decoded,
+	// so that they are written back as is.
+	Extra []byte
+}
+
+// Info returns the shared header of a block.
+func (b *BlockInfo) Info() *BlockInfo {
+	return b
+}
+
+// LwwBool is a last-writer-wins boolean.
+type LwwBool struct {
+	Timestamp CrdtID
+	Value     bool
+}
+
+// LwwByte is a last-writer-wins byte.
+type LwwByte struct {
+	Timestamp CrdtID
+	Value     uint8
+}
+
+// LwwFloat is a last-writer-wins float.
+type LwwFloat struct {
+	Timestamp CrdtID
+	Value     float32
+}
+
+// LwwID is a last-writer-wins CrdtID.
+type LwwID struct {
+	Timestamp CrdtID
+	Value     CrdtID
+}
+
+// LwwString is a last-writer-wins string.
+type LwwString struct {
+	Timestamp CrdtID
+	Value     string
+}
+
+// An Author maps a UUID to the short id used as Part1 of CrdtIDs.
+type Author struct {
+	UUID [16]byte
+	ID   uint16
+}
+
+// AuthorIDsBlock lists the authors of a page.
+type AuthorIDsBlock struct {
+	BlockInfo
+	Authors []Author
+}
+
+// MigrationInfoBlock tells where a page was migrated from.
+type MigrationInfoBlock struct {
+	BlockInfo
+	MigrationID CrdtID
+	IsDevice    bool
+}
+
+// PageInfoBlock holds statistics about a page.
+type PageInfoBlock struct {
+	BlockInfo
+	LoadsCount     uint32
+	MergesCount    uint32
+	TextCharsCount uint32
+	TextLinesCount uint32
+}
+
+// SceneInfoBlock holds the state of the page in the editor.
+type SceneInfoBlock struct {
+	BlockInfo
+	CurrentLayer        LwwID
+	BackgroundVisible   *LwwBool
+	RootDocumentVisible *LwwBool
+}
+
+// SceneTreeBlock attaches a node of the tree to its parent.
+type SceneTreeBlock struct {
+	BlockInfo
+	TreeID   CrdtID
+	NodeID   CrdtID
+	IsUpdate bool
+	ParentID CrdtID
+}
+
+// An Anchor attaches a group to a position in the typed text.
+type Anchor struct {
+	ID        LwwID
+	Type      LwwByte
+	Threshold LwwFloat
+	OriginX   LwwFloat
+}
+
+// TreeNodeBlock describes a node of the tree, a layer being a
+// direct child of the root node.
+type TreeNodeBlock struct {
+	BlockInfo
+	NodeID  CrdtID
+	Label   LwwString
+	Visible LwwBool
+	Anchor  *Anchor
+}
+
+// SceneItem holds the CRDT sequence fields shared by all scene items.
+// An item with no value has been deleted.
+type SceneItem struct {
+	ParentID      CrdtID
+	ItemID        CrdtID
+	LeftID        CrdtID
+	RightID       CrdtID
+	DeletedLength uint32
+	HasValue      bool
+	ItemType      uint8
+	// Extra holds any trailing bytes of the value that weren't decoded.
+	Extra []byte
+}
+
+// A Point is a v6 stroke point. Speed, Direction, Width and
+// Pressure are stored with the integer scaling of the format.
+type Point struct {
+	X         float32
+	Y         float32
+	Speed     uint16
+	Width     uint16
+	Direction uint8
+	Pressure  uint8
+}
+
+// A Line is the value of a SceneLineItemBlock.
+type Line struct {
+	Tool           BrushType
+	Color          BrushColor
+	ThicknessScale float64
+	StartingLength float32
+	Points         []Point
+	Timestamp      CrdtID
+	MoveID         *CrdtID
+}
+
+// SceneLineItemBlock is a stroke drawn in a group.
+type SceneLineItemBlock struct {
+	BlockInfo
+	SceneItem
+	Line Line
+}
+
+// SceneGroupItemBlock adds a group (a node of the tree) to its parent.
+type SceneGroupItemBlock struct {
+	BlockInfo
+	SceneItem
+	NodeID CrdtID
+}
+
+// A GlyphRect is one of the rectangles covered by a highlight.
+type GlyphRect struct {
+	X, Y, W, H float64
+}
+
+// A GlyphRange is the value of a SceneGlyphItemBlock, the highlight of
+// some text of the underlying PDF or EPUB.
+type GlyphRange struct {
+	Start  *uint32
+	Length uint32
+	Color  BrushColor
+	Text   string
+	Rects  []GlyphRect
+}
+
+// SceneGlyphItemBlock is a text highlight.
+type SceneGlyphItemBlock struct {
+	BlockInfo
+	SceneItem
+	Glyph GlyphRange
+}
+
+// SceneTextItemBlock is a text item of a group, its value isn't decoded.
+type SceneTextItemBlock struct {
+	BlockInfo
+	SceneItem
+}
+
+// SceneTombstoneItemBlock marks a deleted item.
+type SceneTombstoneItemBlock struct {
+	BlockInfo
+	SceneItem
+}
+
+// A TextItem is a piece of typed text. It holds either
+// some text or, when HasFormat is set, a formatting code.
+type TextItem struct {
+	ItemID        CrdtID
+	LeftID        CrdtID
+	RightID       CrdtID
+	DeletedLength uint32
+	HasValue      bool
+	Text          string
+	HasFormat     bool
+	Format        uint32
+}
+
+// A TextFormat sets the style of the paragraph starting after
+// the line break CharID, EndID for the first paragraph.
+type TextFormat struct {
+	CharID    CrdtID
+	Timestamp CrdtID
+	Unknown   uint8
+	Style     ParagraphStyle
+}
+
+// RootTextBlock holds the typed text of a page.
+type RootTextBlock struct {
+	BlockInfo
+	BlockID CrdtID
+	Items   []TextItem
+	Formats []TextFormat
+	PosX    float64
+	PosY    float64
+	Width   float32
+}
+
+// UnknownBlock keeps the data of a block this package can't decode.
+type UnknownBlock struct {
+	BlockInfo
+	BlockType BlockType
+	Data      []byte
+}
+
+// Type implementations of the Block interface.
+func (*AuthorIDsBlock) Type() BlockType          { return AuthorIDsBlockType }
+func (*MigrationInfoBlock) Type() BlockType      { return MigrationInfoBlockType }
+func (*PageInfoBlock) Type() BlockType           { return PageInfoBlockType }
+func (*SceneInfoBlock) Type() BlockType          { return SceneInfoBlockType }
+func (*SceneTreeBlock) Type() BlockType          { return SceneTreeBlockType }
+func (*TreeNodeBlock) Type() BlockType           { return TreeNodeBlockType }
+func (*SceneLineItemBlock) Type() BlockType      { return SceneLineItemBlockType }
+func (*SceneGroupItemBlock) Type() BlockType     { return SceneGroupItemBlockType }
+func (*SceneGlyphItemBlock) Type() BlockType     { return SceneGlyphItemBlockType }
+func (*SceneTextItemBlock) Type() BlockType      { return SceneTextItemBlockType }
+func (*SceneTombstoneItemBlock) Type() BlockType { return SceneTombstoneItemBlockType }
+func (*RootTextBlock) Type() BlockType           { return RootTextBlockType }
+func (b *UnknownBlock) Type() BlockType          { return b.BlockType }
+
+// String returns the typed text of the block, skipping deleted
+// items and formatting codes.
+func (b *RootTextBlock) String() string {
+	var o strings.Builder
+	for _, item := range b.Items {
+		if item.HasValue && !item.HasFormat {
+			o.WriteString(item.Text)
+		}
+	}
+	return o.String()
+}
+
+// Text returns the typed text of the scene or nil if it has none.
+func (s *Scene) Text() *RootTextBlock {
+	for _, b := range s.Blocks {
+		if t, ok := b.(*RootTextBlock); ok {
+			return t
+		}
+	}
+	return nil
+}
+
+// itemOf returns the CRDT sequence fields of a scene item block.
+func itemOf(b Block) (*SceneItem, bool) {
+	switch b := b.(type) {
+	case *SceneLineItemBlock:
+		return &b.SceneItem, true
+	case *SceneGroupItemBlock:
+		return &b.SceneItem, true
+	case *SceneGlyphItemBlock:
+		return &b.SceneItem, true
+	case *SceneTextItemBlock:
+		return &b.SceneItem, true
+	case *SceneTombstoneItemBlock:
+		return &b.SceneItem, true
+	}
+	return nil, false
+}
+
+// Layers maps the scene into the layers of the older formats.
+// Layers are the direct children of the root node, in the order of the
+// root's group items, and contain every line of their subtree. Lines and
+// groups are taken in the order of their CRDT sequence, which is the order
+// they are drawn in on the device, whatever the order of their blocks.
+// Coordinates are shifted so that x goes from 0 to Width as in v3 and v5,
+// v6 having its origin at the top center of the page.
+func (s *Scene) Layers() []Layer {
+	parents := make(map[CrdtID]CrdtID)
+	labels := make(map[CrdtID]string)
+	items := make(map[CrdtID][]Block)
+
+	for _, b := range s.Blocks {
+		switch b := b.(type) {
+		case *SceneTreeBlock:
+			parents[b.TreeID] = b.ParentID
+		case *TreeNodeBlock:
+			labels[b.NodeID] = b.Label.Value
+		}
+		// deleted items are kept, others can be on their right
+		if it, ok := itemOf(b); ok {
+			items[it.ParentID] = append(items[it.ParentID], b)
+		}
+	}
+
+	// children returns the items of a group in sequence order
+	children := func(parent CrdtID) []Block {
+		blocks := items[parent]
+		ids := make([]CrdtID, len(blocks))
+		lefts := make([]CrdtID, len(blocks))
+		for i, b := range blocks {
+			it, _ := itemOf(b)
+			ids[i], lefts[i] = it.ItemID, it.LeftID
+		}
+		ordered := make([]Block, 0, len(blocks))
+		for _, i := range sequence(ids, lefts) {
+			ordered = append(ordered, blocks[i])
+		}
+		return ordered
+	}
+
+	var order []CrdtID
+	seen := make(map[CrdtID]bool)
+	for _, b := range children(RootID) {
+		if g, ok := b.(*SceneGroupItemBlock); ok && g.HasValue && !seen[g.NodeID] {
+			seen[g.NodeID] = true
+			order = append(order, g.NodeID)
+		}
+	}
+
+	// layers without group item are appended in tree order
+	for _, b := range s.Blocks {
+		if t, ok := b.(*SceneTreeBlock); ok && t.ParentID == RootID && !seen[t.TreeID] {
+			seen[t.TreeID] = true
+			order = append(order, t.TreeID)
+		}
+	}
+
+	index := make(map[CrdtID]int)
+	layers := make([]Layer, len(order))
+	for i, id := range order {
+		index[id] = i
+		layers[i].Name = labels[id]
+	}
+
+	drawn := make(map[*SceneLineItemBlock]bool)
+	walked := make(map[CrdtID]bool)
+	var walk func(layer int, group CrdtID)
+	walk = func(layer int, group CrdtID) {
+		if walked[group] {
+			return
+		}
+		walked[group] = true
+		for _, b := range children(group) {
+			switch b := b.(type) {
+			case *SceneLineItemBlock:
+				if b.HasValue {
+					drawn[b] = true
+					layers[layer].Strokes = append(layers[layer].Strokes, b.Line.Stroke())
+				}
+			case *SceneGroupItemBlock:
+				if b.HasValue {
+					walk(layer, b.NodeID)
+				}
+			}
+		}
+	}
+	for i, id := range order {
+		walk(i, id)
+	}
+
+	// layerOf walks up the tree until it finds a layer
+	layerOf := func(id CrdtID) (int, bool) {
+		for depth := 0; depth <= len(parents); depth++ {
+			if i, ok := index[id]; ok {
+				return i, true
+			}
+			parent, ok := parents[id]
+			if !ok {
+				return 0, false
+			}
+			id = parent
+		}
+		return 0, false
+	}
+
+	// lines of groups only attached by the tree come last, in storage order
+	for _, b := range s.Blocks {
+		line, ok := b.(*SceneLineItemBlock)
+		if !ok || !line.HasValue || drawn[line] {
+			continue
+		}
+		i, ok := layerOf(line.ParentID)
+		if !ok {
+			continue
+		}
+		layers[i].Strokes = append(layers[i].Strokes, line.Line.Stroke())
+	}
+
+	return layers
+}
+
+// Stroke converts a v6 line into a stroke of the older formats.
+func (l *Line) Stroke() Stroke {
+	stroke := Stroke{
+		BrushType:  l.Tool,
+		BrushColor: l.Color,
+		BrushSize:  BrushSize(l.ThicknessScale),
+		Segments:   make([]Segment, len(l.Points)),
+	}
+
+	for i, p := range l.Points {
+		stroke.Segments[i] = p.Segment()
+	}
+
+	return stroke
+}
+
+// Segment converts a v6 point into a segment of the older formats.
+func (p Point) Segment() Segment {
+	return Segment{
+		X:         p.X + float32(Width)/2,
+		Y:         p.Y,
+		Speed:     float32(p.Speed) / 4,
+		Direction: float32(p.Direction) * 2 * math.Pi / 255,
+		Width:     float32(p.Width) / 4,
+		Pressure:  float32(p.Pressure) / 255,
+	}
+}
+
+// point converts a segment of the older formats into a v6 point.
+func (s Segment) point() Point {
+	direction := math.Mod(float64(s.Direction), 2*math.Pi)
+	if direction < 0 {
+		direction += 2 * math.Pi
+	}
+
+	return Point{
+		X:         s.X - float32(Width)/2,
+		Y:         s.Y,
+		Speed:     uint16(clamp(math.Round(float64(s.Speed)*4), math.MaxUint16)),
+		Width:     uint16(clamp(math.Round(float64(s.Width)*4), math.MaxUint16)),
+		Direction: uint8(clamp(math.Round(direction*255/(2*math.Pi)), math.MaxUint8)),
+		Pressure:  uint8(clamp(math.Round(float64(s.Pressure)*255), math.MaxUint8)),
+	}
+}
+
+func clamp(v float64, max float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// NewScene builds the scene of a page holding the given layers, as the
+// device would when creating a new page. Layers without a name are
+// labelled "Layer 1", "Layer 2"...
+func NewScene(layers []Layer) *Scene {
+	author := CrdtID{1, 0}
+	nextID := func() CrdtID {
+		author.Part2++
+		return author
+	}
+
+	s := &Scene{}
+	s.Blocks = append(s.Blocks,
+		&AuthorIDsBlock{
+			BlockInfo: BlockInfo{MinVersion: 1, Version: 1},
+			Authors:   []Author{{ID: 1}},
+		},
+		&MigrationInfoBlock{
+			BlockInfo:   BlockInfo{MinVersion: 1, Version: 1},
+			MigrationID: CrdtID{1, 1},
+			IsDevice:    true,
+		},
+		&PageInfoBlock{
+			BlockInfo:  BlockInfo{MinVersion: 0, Version: 1},
+			LoadsCount: 1,
+		},
+	)
+
+	layerIDs := make([]CrdtID, len(layers))
+	for i := range layers {
+		layerIDs[i] = nextID()
+	}
+
+	if len(layers) > 0 {
+		s.Blocks = append(s.Blocks, &SceneInfoBlock{
+			BlockInfo:    BlockInfo{MinVersion: 0, Version: 1},
+			CurrentLayer: LwwID{Value: layerIDs[0]},
+		})
+	}
+
+	for _, id := range layerIDs {
+		s.Blocks = append(s.Blocks, &SceneTreeBlock{
+			BlockInfo: BlockInfo{MinVersion: 1, Version: 1},
+			TreeID:    id,
+			NodeID:    EndID,
+			IsUpdate:  true,
+			ParentID:  RootID,
+		})
+	}
+
+	s.Blocks = append(s.Blocks, &TreeNodeBlock{
+		BlockInfo: BlockInfo{MinVersion: 1, Version: 1},
+		NodeID:    RootID,
+		Visible:   LwwBool{Value: true},
+	})
+
+	for i, layer := range layers {
+		name := layer.Name
+		if name == "" {
+			name = fmt.Sprintf("Layer %d", i+1)
+		}
+		s.Blocks = append(s.Blocks, &TreeNodeBlock{
+			BlockInfo: BlockInfo{MinVersion: 1, Version: 1},
+			NodeID:    layerIDs[i],
+			Label:     LwwString{Timestamp: nextID(), Value: name},
+			Visible:   LwwBool{Value: true},
+		})
+	}
+
+	left := EndID
+	for _, id := range layerIDs {
+		item := nextID()
+		s.Blocks = append(s.Blocks, &SceneGroupItemBlock{
+			BlockInfo: BlockInfo{MinVersion: 0, Version: 0},
+			SceneItem: SceneItem{
+				ParentID: RootID,
+				ItemID:   item,
+				LeftID:   left,
+				RightID:  EndID,
+				HasValue: true,
+				ItemType: groupItemType,
+			},
+			NodeID: id,
+		})
+		left = item
+	}
+
+	for i, layer := range layers {
+		left := EndID
+		for _, stroke := range layer.Strokes {
+			item := nextID()
+			s.Blocks = append(s.Blocks, &SceneLineItemBlock{
+				BlockInfo: BlockInfo{MinVersion: 2, Version: 2},
+				SceneItem: SceneItem{
+					ParentID: layerIDs[i],
+					ItemID:   item,
+					LeftID:   left,
+					RightID:  EndID,
+					HasValue: true,
+					ItemType: lineItemType,
+				},
+				Line: newLine(stroke, nextID()),
+			})
+			left = item
+		}
+	}
+
+	return s
+}
+
+// newLine converts a stroke of the older formats into a v6 line.
+func newLine(stroke Stroke, timestamp CrdtID) Line {
+	line := Line{
+		Tool:           stroke.BrushType,
+		Color:          stroke.BrushColor,
+		ThicknessScale: float64(stroke.BrushSize),
+		Points:         make([]Point, len(stroke.Segments)),
+		Timestamp:      timestamp,
+	}
+
+	for i, s := range stroke.Segments {
+		line.Points[i] = s.point()
+	}
+
+	return line
+}
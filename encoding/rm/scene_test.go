@@ -0,0 +1,215 @@
+package rm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalBinaryV6FromLayers(t *testing.T) {
+	b, err := ioutil.ReadFile("test_v5.rm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v5 := New()
+	if err := v5.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+
+	v6 := &Rm{Version: V6, Layers: v5.Layers}
+	data, err := v6.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := New()
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Version != V6 || got.Scene == nil {
+		t.Fatal("wrong version parsed")
+	}
+
+	if len(got.Layers) != len(v5.Layers) {
+		t.Fatalf("got %d layers, want %d", len(got.Layers), len(v5.Layers))
+	}
+
+	for i, layer := range got.Layers {
+		if layer.Name == "" {
+			t.Errorf("layer %d has no name", i)
+		}
+		want := v5.Layers[i].Strokes
+		if len(layer.Strokes) != len(want) {
+			t.Fatalf("layer %d: got %d strokes, want %d", i, len(layer.Strokes), len(want))
+		}
+		for j, stroke := range layer.Strokes {
+			if stroke.BrushType != want[j].BrushType || stroke.BrushSize != want[j].BrushSize {
+				t.Errorf("layer %d stroke %d: wrong brush", i, j)
+			}
+			if len(stroke.Segments) != len(want[j].Segments) {
+				t.Fatalf("layer %d stroke %d: wrong number of segments", i, j)
+			}
+			for k, s := range stroke.Segments {
+				w := want[j].Segments[k]
+				if math.Abs(float64(s.X-w.X)) > 1e-3 || s.Y != w.Y {
+					t.Errorf("layer %d stroke %d segment %d: got %v,%v want %v,%v", i, j, k, s.X, s.Y, w.X, w.Y)
+				}
+				if math.Abs(float64(s.Pressure-w.Pressure)) > 1.0/255 {
+					t.Errorf("layer %d stroke %d segment %d: wrong pressure", i, j, k)
+				}
+			}
+		}
+	}
+
+	// the decoded scene is written back as is
+	again, err := got.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, again) {
+		t.Error("v6 round trip changed the data")
+	}
+}
+
+func TestMarshalBinaryV6Scene(t *testing.T) {
+	start := uint32(12)
+	moveID := CrdtID{1, 40}
+	visible := LwwBool{CrdtID{1, 3}, false}
+
+	scene := NewScene([]Layer{{Name: "Sketch"}})
+	scene.Blocks = append(scene.Blocks,
+		&SceneInfoBlock{
+			BlockInfo:         BlockInfo{MinVersion: 0, Version: 1},
+			CurrentLayer:      LwwID{CrdtID{1, 2}, CrdtID{1, 1}},
+			BackgroundVisible: &visible,
+		},
+		&TreeNodeBlock{
+			BlockInfo: BlockInfo{MinVersion: 1, Version: 2},
+			NodeID:    CrdtID{1, 20},
+			Label:     LwwString{CrdtID{1, 21}, "Grüße"},
+			Visible:   LwwBool{CrdtID{1, 22}, true},
+			Anchor: &Anchor{
+				ID:        LwwID{CrdtID{1, 23}, CrdtID{1, 30}},
+				Type:      LwwByte{CrdtID{1, 24}, 2},
+				Threshold: LwwFloat{CrdtID{1, 25}, 0.5},
+				OriginX:   LwwFloat{CrdtID{1, 26}, -300},
+			},
+		},
+		&SceneLineItemBlock{
+			BlockInfo: BlockInfo{MinVersion: 2, Version: 2, Extra: []byte{0xde, 0xad}},
+			SceneItem: SceneItem{
+				ParentID: CrdtID{1, 1},
+				ItemID:   CrdtID{1, 300},
+				LeftID:   EndID,
+				RightID:  EndID,
+				HasValue: true,
+				ItemType: lineItemType,
+				Extra:    []byte{0x8c, 0x01},
+			},
+			Line: Line{
+				Tool:           FinelinerV5,
+				Color:          Grey,
+				ThicknessScale: 2,
+				StartingLength: 10,
+				Points:         []Point{{X: -10, Y: 20, Speed: 4, Width: 8, Direction: 16, Pressure: 255}},
+				Timestamp:      CrdtID{1, 301},
+				MoveID:         &moveID,
+			},
+		},
+		&SceneGlyphItemBlock{
+			BlockInfo: BlockInfo{MinVersion: 0, Version: 0},
+			SceneItem: SceneItem{
+				ParentID: CrdtID{1, 1},
+				ItemID:   CrdtID{1, 302},
+				LeftID:   CrdtID{1, 300},
+				RightID:  EndID,
+				HasValue: true,
+				ItemType: glyphItemType,
+			},
+			Glyph: GlyphRange{
+				Start:  &start,
+				Length: 5,
+				Color:  Black,
+				Text:   "hello",
+				Rects:  []GlyphRect{{1, 2, 3, 4}},
+			},
+		},
+		&SceneTombstoneItemBlock{
+			BlockInfo: BlockInfo{MinVersion: 0, Version: 0},
+			SceneItem: SceneItem{
+				ParentID:      CrdtID{1, 1},
+				ItemID:        CrdtID{1, 303},
+				LeftID:        CrdtID{1, 302},
+				RightID:       EndID,
+				DeletedLength: 1,
+			},
+		},
+		&RootTextBlock{
+			BlockInfo: BlockInfo{MinVersion: 0, Version: 0},
+			BlockID:   EndID,
+			Items: []TextItem{
+				{ItemID: CrdtID{1, 400}, LeftID: EndID, RightID: EndID, HasValue: true, Text: "Title\n"},
+				{ItemID: CrdtID{1, 406}, LeftID: CrdtID{1, 405}, RightID: EndID, DeletedLength: 3},
+				{ItemID: CrdtID{1, 407}, LeftID: CrdtID{1, 406}, RightID: EndID, HasValue: true, HasFormat: true, Format: 1},
+				{ItemID: CrdtID{1, 408}, LeftID: CrdtID{1, 407}, RightID: EndID, HasValue: true, Text: "body"},
+			},
+			Formats: []TextFormat{
+				{CharID: CrdtID{1, 405}, Timestamp: CrdtID{1, 410}, Unknown: 17, Style: HeadingStyle},
+			},
+			PosX:  -468,
+			PosY:  234,
+			Width: 936,
+		},
+		&UnknownBlock{
+			BlockInfo: BlockInfo{MinVersion: 3, Version: 4},
+			BlockType: 0x42,
+			Data:      []byte{1, 2, 3},
+		},
+	)
+
+	data, err := (&Rm{Version: V6, Scene: scene}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := New()
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(scene, got.Scene) {
+		t.Error("v6 scene not preserved")
+		for i := range scene.Blocks {
+			if i < len(got.Scene.Blocks) && !reflect.DeepEqual(scene.Blocks[i], got.Scene.Blocks[i]) {
+				t.Logf("block %d: got %+v, want %+v", i, got.Scene.Blocks[i], scene.Blocks[i])
+			}
+		}
+	}
+
+	if len(got.Layers) != 1 || got.Layers[0].Name != "Sketch" || len(got.Layers[0].Strokes) != 1 {
+		t.Fatalf("wrong layers %+v", got.Layers)
+	}
+
+	if x := got.Layers[0].Strokes[0].Segments[0].X; x != float32(Width)/2-10 {
+		t.Errorf("x not shifted to device coordinates: %v", x)
+	}
+
+	if text := got.Scene.Text().String(); text != "Title\nbody" {
+		t.Errorf("wrong text %q", text)
+	}
+}
+
+func TestUnmarshalBinaryV6Truncated(t *testing.T) {
+	data, err := (&Rm{Version: V6, Layers: []Layer{{}}}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := New().UnmarshalBinary(data[:len(data)-3]); err == nil {
+		t.Error("truncated file should fail")
+	}
+}
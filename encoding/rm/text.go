@@ -0,0 +1,164 @@
+package rm
+
+import (
+	"sort"
+	"strings"
+)
+
+// A Paragraph is a paragraph of typed text, without its line break.
+type Paragraph struct {
+	Style ParagraphStyle
+	Text  string
+}
+
+// textChar is a character of typed text. Text items are sequences of
+// characters whose ids follow the id of the item.
+type textChar struct {
+	id, left CrdtID
+	value    string
+	// deleted characters and formatting codes keep their place
+	// in the sequence but aren't part of the text
+	hidden bool
+}
+
+// chars expands the text items into characters, in storage order.
+func (b *RootTextBlock) chars() []textChar {
+	var chars []textChar
+	for _, item := range b.Items {
+		id, left := item.ItemID, item.LeftID
+		add := func(value string, hidden bool) {
+			chars = append(chars, textChar{id: id, left: left, value: value, hidden: hidden})
+			left = id
+			id.Part2++
+		}
+
+		switch {
+		case !item.HasValue:
+			for i := uint32(0); i < item.DeletedLength; i++ {
+				add("", true)
+			}
+		case item.HasFormat:
+			add("", true)
+		default:
+			for _, r := range item.Text {
+				add(string(r), false)
+			}
+		}
+	}
+	return chars
+}
+
+// ordered returns the characters in reading order.
+func ordered(chars []textChar) []textChar {
+	ids := make([]CrdtID, len(chars))
+	lefts := make([]CrdtID, len(chars))
+	for i, c := range chars {
+		ids[i], lefts[i] = c.id, c.left
+	}
+
+	result := make([]textChar, 0, len(chars))
+	for _, i := range sequence(ids, lefts) {
+		result = append(result, chars[i])
+	}
+	return result
+}
+
+// sequence returns the order of the elements of a CRDT sequence, given the
+// ids of the elements and of the ones on their left, as indices in the
+// slices. Each element follows the one on its left, the latest insertions
+// coming first. Elements whose left one is unknown are put at the end.
+func sequence(ids, lefts []CrdtID) []int {
+	known := make(map[CrdtID]bool, len(ids))
+	for _, id := range ids {
+		known[id] = true
+	}
+
+	children := make(map[CrdtID][]int)
+	var orphans []int
+	for i, left := range lefts {
+		if left != EndID && !known[left] {
+			orphans = append(orphans, i)
+			continue
+		}
+		children[left] = append(children[left], i)
+	}
+	for _, list := range children {
+		sort.SliceStable(list, func(i, j int) bool {
+			return ids[list[j]].less(ids[list[i]])
+		})
+	}
+
+	result := make([]int, 0, len(ids))
+	visited := make([]bool, len(ids))
+	var stack []int
+	push := func(parent CrdtID) {
+		list := children[parent]
+		for i := len(list) - 1; i >= 0; i-- {
+			stack = append(stack, list[i])
+		}
+	}
+
+	push(EndID)
+	for _, i := range orphans {
+		stack = append([]int{i}, stack...)
+	}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[i] {
+			continue
+		}
+		visited[i] = true
+		result = append(result, i)
+		push(ids[i])
+	}
+
+	return result
+}
+
+// Paragraphs returns the typed text in reading order, split into paragraphs.
+// Paragraphs without a format are PlainStyle.
+func (b *RootTextBlock) Paragraphs() []Paragraph {
+	styles := make(map[CrdtID]TextFormat)
+	for _, f := range b.Formats {
+		if prev, ok := styles[f.CharID]; ok && !prev.Timestamp.less(f.Timestamp) {
+			continue
+		}
+		styles[f.CharID] = f
+	}
+	style := func(id CrdtID) ParagraphStyle {
+		if f, ok := styles[id]; ok {
+			return f.Style
+		}
+		return PlainStyle
+	}
+
+	// the first paragraph starts at the beginning of the sequence
+	var paragraphs []Paragraph
+	var text strings.Builder
+	current := Paragraph{Style: style(EndID)}
+	for _, c := range ordered(b.chars()) {
+		if c.hidden {
+			continue
+		}
+		if c.value == "\n" {
+			current.Text = text.String()
+			paragraphs = append(paragraphs, current)
+			text.Reset()
+			current = Paragraph{Style: style(c.id)}
+			continue
+		}
+		text.WriteString(c.value)
+	}
+	current.Text = text.String()
+	paragraphs = append(paragraphs, current)
+
+	return paragraphs
+}
+
+func (id CrdtID) less(other CrdtID) bool {
+	if id.Part2 != other.Part2 {
+		return id.Part2 < other.Part2
+	}
+	return id.Part1 < other.Part1
+}
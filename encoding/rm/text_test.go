@@ -0,0 +1,53 @@
+package rm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParagraphs(t *testing.T) {
+	block := &RootTextBlock{
+		Items: []TextItem{
+			// "Title\n" then "a lis", a deleted character and "t",
+			// and " more" inserted in the middle afterwards
+			{ItemID: CrdtID{1, 10}, LeftID: EndID, RightID: EndID, HasValue: true, Text: "Title\n"},
+			{ItemID: CrdtID{1, 16}, LeftID: CrdtID{1, 15}, RightID: EndID, HasValue: true, Text: "a lis"},
+			{ItemID: CrdtID{1, 21}, LeftID: CrdtID{1, 20}, RightID: EndID, DeletedLength: 1},
+			{ItemID: CrdtID{1, 30}, LeftID: CrdtID{1, 21}, RightID: EndID, HasValue: true, Text: "t\nitem"},
+			{ItemID: CrdtID{1, 40}, LeftID: CrdtID{1, 16}, RightID: CrdtID{1, 17}, HasValue: true, Text: " more"},
+			// a formatting code isn't part of the text
+			{ItemID: CrdtID{1, 50}, LeftID: CrdtID{1, 35}, RightID: EndID, HasValue: true, HasFormat: true, Format: 1},
+			// written by another device
+			{ItemID: CrdtID{2, 1}, LeftID: CrdtID{1, 50}, RightID: EndID, HasValue: true, Text: "s"},
+		},
+		Formats: []TextFormat{
+			{CharID: EndID, Timestamp: CrdtID{1, 1}, Style: HeadingStyle},
+			{CharID: CrdtID{1, 31}, Timestamp: CrdtID{1, 2}, Style: PlainStyle},
+			// the latest format wins
+			{CharID: CrdtID{1, 31}, Timestamp: CrdtID{1, 60}, Style: BulletStyle},
+		},
+	}
+
+	expected := []Paragraph{
+		{Style: HeadingStyle, Text: "Title"},
+		{Style: PlainStyle, Text: "a more list"},
+		{Style: BulletStyle, Text: "items"},
+	}
+	if p := block.Paragraphs(); !reflect.DeepEqual(p, expected) {
+		t.Errorf("got %+v, expected %+v", p, expected)
+	}
+}
+
+func TestParagraphsUnknownLeft(t *testing.T) {
+	block := &RootTextBlock{
+		Items: []TextItem{
+			{ItemID: CrdtID{1, 10}, LeftID: CrdtID{1, 5}, RightID: EndID, HasValue: true, Text: "end"},
+			{ItemID: CrdtID{1, 20}, LeftID: EndID, RightID: EndID, HasValue: true, Text: "start "},
+		},
+	}
+
+	expected := []Paragraph{{Style: PlainStyle, Text: "start end"}}
+	if p := block.Paragraphs(); !reflect.DeepEqual(p, expected) {
+		t.Errorf("got %+v, expected %+v", p, expected)
+	}
+}
@@ -1,8 +1,11 @@
 package rm
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
+	"math"
+	"reflect"
 	"testing"
 )
 
@@ -43,3 +46,81 @@ func TestUnmarshalBinaryV5(t *testing.T) {
 func TestUnmarshalBinaryV3(t *testing.T) {
 	testUnmarshalBinary(t, "test_v3.rm", V3)
 }
+
+// test_v6.rm is written from the block layout of the format, independently
+// of the encoder of this package. It has two layers, "Layer 1" holding a
+// line, a group with a highlighter line and another line, and "Sketch"
+// holding a deleted line and a pencil line, with the typed text
+// "Hello\nworld". Items are stored out of their sequence order.
+func TestUnmarshalBinaryV6(t *testing.T) {
+	rm := testUnmarshalBinary(t, "test_v6.rm", V6)
+
+	type stroke struct {
+		brush BrushType
+		color BrushColor
+		size  BrushSize
+	}
+	want := []struct {
+		name    string
+		strokes []stroke
+	}{
+		{"Layer 1", []stroke{{BallPointV5, Blue, Small}, {HighlighterV5, HighlightYellow, Large}, {FinelinerV5, Black, Medium}}},
+		{"Sketch", []stroke{{PencilV5, Grey, Medium}}},
+	}
+	if len(rm.Layers) != len(want) {
+		t.Fatalf("got %d layers, want %d", len(rm.Layers), len(want))
+	}
+	for i, layer := range rm.Layers {
+		if layer.Name != want[i].name || len(layer.Strokes) != len(want[i].strokes) {
+			t.Fatalf("layer %d: got %q with %d strokes, want %+v", i, layer.Name, len(layer.Strokes), want[i])
+		}
+		for j, s := range layer.Strokes {
+			if got := (stroke{s.BrushType, s.BrushColor, s.BrushSize}); got != want[i].strokes[j] {
+				t.Errorf("layer %d stroke %d: got %+v, want %+v", i, j, got, want[i].strokes[j])
+			}
+		}
+	}
+
+	// x is shifted from the center of the page, speed and width are
+	// stored times 4, pressure and direction scaled to a byte
+	segments := rm.Layers[0].Strokes[0].Segments
+	wantSegments := []Segment{
+		{X: 0, Y: 0, Speed: 0, Direction: 0, Width: 2, Pressure: 128.0 / 255},
+		{X: 1404, Y: 1872, Speed: 10, Direction: 2 * math.Pi, Width: 4, Pressure: 1},
+	}
+	if len(segments) != len(wantSegments) {
+		t.Fatalf("got %d segments, want %d", len(segments), len(wantSegments))
+	}
+	for i, s := range segments {
+		w := wantSegments[i]
+		if s.X != w.X || s.Y != w.Y || s.Speed != w.Speed || s.Width != w.Width ||
+			math.Abs(float64(s.Direction-w.Direction)) > 1e-6 || math.Abs(float64(s.Pressure-w.Pressure)) > 1e-6 {
+			t.Errorf("segment %d: got %+v, want %+v", i, s, w)
+		}
+	}
+
+	text := rm.Scene.Text()
+	if text == nil {
+		t.Fatal("no typed text")
+	}
+	paragraphs := []Paragraph{{Style: HeadingStyle, Text: "Hello"}, {Style: BulletStyle, Text: "world"}}
+	if p := text.Paragraphs(); !reflect.DeepEqual(p, paragraphs) {
+		t.Errorf("got paragraphs %+v, want %+v", p, paragraphs)
+	}
+	if text.PosX != -468 || text.PosY != 234 || text.Width != 936 {
+		t.Errorf("text at %v,%v width %v", text.PosX, text.PosY, text.Width)
+	}
+
+	// writing the scene back gives the same bytes
+	b, err := ioutil.ReadFile("test_v6.rm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := rm.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, b) {
+		t.Error("the scene isn't written back as read")
+	}
+}
@@ -0,0 +1,777 @@
+package rm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Tag types of the values stored in v6 blocks.
+const (
+	tagByte1   uint8 = 0x1
+	tagByte4   uint8 = 0x4
+	tagByte8   uint8 = 0x8
+	tagLength4 uint8 = 0xc
+	tagID      uint8 = 0xf
+)
+
+// pos returns the current offset in the data.
+func (r *reader) pos() int64 {
+	return r.Size() - int64(r.Len())
+}
+
+func (r *reader) readBlock() (Block, error) {
+	var header struct {
+		Length     uint32
+		Unknown    uint8
+		MinVersion uint8
+		Version    uint8
+		Type       BlockType
+	}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("Failed to read block header")
+	}
+
+	end := r.pos() + int64(header.Length)
+	if end > r.Size() {
+		return nil, fmt.Errorf("Block exceeds file size")
+	}
+
+	info := BlockInfo{MinVersion: header.MinVersion, Version: header.Version}
+	sr := &sceneReader{r: r, end: end}
+
+	var block Block
+	var err error
+	switch header.Type {
+	case AuthorIDsBlockType:
+		block, err = sr.readAuthorIDs(info)
+	case MigrationInfoBlockType:
+		block, err = sr.readMigrationInfo(info)
+	case PageInfoBlockType:
+		block, err = sr.readPageInfo(info)
+	case SceneInfoBlockType:
+		block, err = sr.readSceneInfo(info)
+	case SceneTreeBlockType:
+		block, err = sr.readSceneTree(info)
+	case TreeNodeBlockType:
+		block, err = sr.readTreeNode(info)
+	case SceneLineItemBlockType, SceneGroupItemBlockType, SceneGlyphItemBlockType,
+		SceneTextItemBlockType, SceneTombstoneItemBlockType:
+		block, err = sr.readSceneItem(info, header.Type)
+	case RootTextBlockType:
+		block, err = sr.readRootText(info)
+	default:
+		unknown := &UnknownBlock{BlockInfo: info, BlockType: header.Type}
+		unknown.Data, err = sr.readRemaining()
+		block = unknown
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// keep what wasn't decoded for the encoder
+	if sr.remaining() > 0 {
+		block.Info().Extra, err = sr.readRemaining()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return block, nil
+}
+
+// A sceneReader reads the tagged values of a block
+// or of a subblock ending at end.
+type sceneReader struct {
+	r   *reader
+	end int64
+}
+
+func (s *sceneReader) remaining() int64 {
+	return s.end - s.r.pos()
+}
+
+func (s *sceneReader) readRemaining() ([]byte, error) {
+	buf := make([]byte, s.remaining())
+	if _, err := io.ReadFull(s.r, buf); err != nil {
+		return nil, fmt.Errorf("Failed to read block data")
+	}
+	return buf, nil
+}
+
+func (s *sceneReader) readRaw(data interface{}) error {
+	if int64(binary.Size(data)) > s.remaining() {
+		return fmt.Errorf("Unexpected end of block")
+	}
+	if err := binary.Read(s.r, binary.LittleEndian, data); err != nil {
+		return fmt.Errorf("Unexpected end of block")
+	}
+	return nil
+}
+
+func (s *sceneReader) readVaruint() (uint64, error) {
+	var result uint64
+	var shift uint
+	for {
+		var b uint8
+		if err := s.readRaw(&b); err != nil {
+			return 0, err
+		}
+		if shift >= 64 {
+			return 0, fmt.Errorf("Varuint overflow")
+		}
+		result |= uint64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			return result, nil
+		}
+	}
+}
+
+func (s *sceneReader) readCrdtID() (CrdtID, error) {
+	var id CrdtID
+	if err := s.readRaw(&id.Part1); err != nil {
+		return id, err
+	}
+	part2, err := s.readVaruint()
+	if err != nil {
+		return id, err
+	}
+	id.Part2 = part2
+	return id, nil
+}
+
+// peekTag returns the next tag without consuming it.
+func (s *sceneReader) peekTag() (index uint64, tagType uint8, ok bool) {
+	if s.remaining() <= 0 {
+		return 0, 0, false
+	}
+	start := s.r.pos()
+	defer s.r.Seek(start, io.SeekStart)
+
+	tag, err := s.readVaruint()
+	if err != nil {
+		return 0, 0, false
+	}
+	return tag >> 4, uint8(tag & 0xf), true
+}
+
+// hasTag tells if the next value has the given index and type.
+func (s *sceneReader) hasTag(index uint64, tagType uint8) bool {
+	i, t, ok := s.peekTag()
+	return ok && i == index && t == tagType
+}
+
+func (s *sceneReader) readTag(index uint64, tagType uint8) error {
+	tag, err := s.readVaruint()
+	if err != nil {
+		return err
+	}
+	if tag>>4 != index || uint8(tag&0xf) != tagType {
+		return fmt.Errorf("Unexpected tag %d/%x, expected %d/%x at %d",
+			tag>>4, tag&0xf, index, tagType, s.r.pos())
+	}
+	return nil
+}
+
+func (s *sceneReader) readID(index uint64) (CrdtID, error) {
+	if err := s.readTag(index, tagID); err != nil {
+		return CrdtID{}, err
+	}
+	return s.readCrdtID()
+}
+
+func (s *sceneReader) readBool(index uint64) (bool, error) {
+	if err := s.readTag(index, tagByte1); err != nil {
+		return false, err
+	}
+	var b uint8
+	err := s.readRaw(&b)
+	return b != 0, err
+}
+
+func (s *sceneReader) readByte(index uint64) (uint8, error) {
+	if err := s.readTag(index, tagByte1); err != nil {
+		return 0, err
+	}
+	var b uint8
+	err := s.readRaw(&b)
+	return b, err
+}
+
+func (s *sceneReader) readInt(index uint64) (uint32, error) {
+	if err := s.readTag(index, tagByte4); err != nil {
+		return 0, err
+	}
+	var n uint32
+	err := s.readRaw(&n)
+	return n, err
+}
+
+func (s *sceneReader) readFloat(index uint64) (float32, error) {
+	if err := s.readTag(index, tagByte4); err != nil {
+		return 0, err
+	}
+	var f float32
+	err := s.readRaw(&f)
+	return f, err
+}
+
+func (s *sceneReader) readDouble(index uint64) (float64, error) {
+	if err := s.readTag(index, tagByte8); err != nil {
+		return 0, err
+	}
+	var f float64
+	err := s.readRaw(&f)
+	return f, err
+}
+
+// readSubblock calls fn with a reader bounded to the subblock
+// and skips whatever fn didn't read.
+func (s *sceneReader) readSubblock(index uint64, fn func(sub *sceneReader) error) error {
+	if err := s.readTag(index, tagLength4); err != nil {
+		return err
+	}
+	var length uint32
+	if err := s.readRaw(&length); err != nil {
+		return err
+	}
+	if int64(length) > s.remaining() {
+		return fmt.Errorf("Subblock exceeds block size")
+	}
+
+	sub := &sceneReader{r: s.r, end: s.r.pos() + int64(length)}
+	if err := fn(sub); err != nil {
+		return err
+	}
+
+	_, err := s.r.Seek(sub.end, io.SeekStart)
+	return err
+}
+
+func (s *sceneReader) readString(index uint64) (string, error) {
+	var str string
+	err := s.readSubblock(index, func(sub *sceneReader) error {
+		var err error
+		str, err = sub.readStringData()
+		return err
+	})
+	return str, err
+}
+
+func (s *sceneReader) readStringData() (string, error) {
+	length, err := s.readVaruint()
+	if err != nil {
+		return "", err
+	}
+	var isASCII uint8
+	if err := s.readRaw(&isASCII); err != nil {
+		return "", err
+	}
+	if int64(length) > s.remaining() {
+		return "", fmt.Errorf("String exceeds block size")
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(s.r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func (s *sceneReader) readLwwBool(index uint64) (v LwwBool, err error) {
+	err = s.readSubblock(index, func(sub *sceneReader) error {
+		if v.Timestamp, err = sub.readID(1); err != nil {
+			return err
+		}
+		v.Value, err = sub.readBool(2)
+		return err
+	})
+	return
+}
+
+func (s *sceneReader) readLwwByte(index uint64) (v LwwByte, err error) {
+	err = s.readSubblock(index, func(sub *sceneReader) error {
+		if v.Timestamp, err = sub.readID(1); err != nil {
+			return err
+		}
+		v.Value, err = sub.readByte(2)
+		return err
+	})
+	return
+}
+
+func (s *sceneReader) readLwwFloat(index uint64) (v LwwFloat, err error) {
+	err = s.readSubblock(index, func(sub *sceneReader) error {
+		if v.Timestamp, err = sub.readID(1); err != nil {
+			return err
+		}
+		v.Value, err = sub.readFloat(2)
+		return err
+	})
+	return
+}
+
+func (s *sceneReader) readLwwID(index uint64) (v LwwID, err error) {
+	err = s.readSubblock(index, func(sub *sceneReader) error {
+		if v.Timestamp, err = sub.readID(1); err != nil {
+			return err
+		}
+		v.Value, err = sub.readID(2)
+		return err
+	})
+	return
+}
+
+func (s *sceneReader) readLwwString(index uint64) (v LwwString, err error) {
+	err = s.readSubblock(index, func(sub *sceneReader) error {
+		if v.Timestamp, err = sub.readID(1); err != nil {
+			return err
+		}
+		v.Value, err = sub.readString(2)
+		return err
+	})
+	return
+}
+
+func (s *sceneReader) readAuthorIDs(info BlockInfo) (*AuthorIDsBlock, error) {
+	b := &AuthorIDsBlock{BlockInfo: info}
+
+	nb, err := s.readVaruint()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := uint64(0); i < nb; i++ {
+		var author Author
+		err := s.readSubblock(0, func(sub *sceneReader) error {
+			length, err := sub.readVaruint()
+			if err != nil {
+				return err
+			}
+			if length != uint64(len(author.UUID)) {
+				return fmt.Errorf("Wrong author uuid length %d", length)
+			}
+			if err := sub.readRaw(&author.UUID); err != nil {
+				return err
+			}
+			return sub.readRaw(&author.ID)
+		})
+		if err != nil {
+			return nil, err
+		}
+		b.Authors = append(b.Authors, author)
+	}
+
+	return b, nil
+}
+
+func (s *sceneReader) readMigrationInfo(info BlockInfo) (b *MigrationInfoBlock, err error) {
+	b = &MigrationInfoBlock{BlockInfo: info}
+	if b.MigrationID, err = s.readID(1); err != nil {
+		return nil, err
+	}
+	if b.IsDevice, err = s.readBool(2); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (s *sceneReader) readPageInfo(info BlockInfo) (b *PageInfoBlock, err error) {
+	b = &PageInfoBlock{BlockInfo: info}
+	if b.LoadsCount, err = s.readInt(1); err != nil {
+		return nil, err
+	}
+	if b.MergesCount, err = s.readInt(2); err != nil {
+		return nil, err
+	}
+	if b.TextCharsCount, err = s.readInt(3); err != nil {
+		return nil, err
+	}
+	if b.TextLinesCount, err = s.readInt(4); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (s *sceneReader) readSceneInfo(info BlockInfo) (b *SceneInfoBlock, err error) {
+	b = &SceneInfoBlock{BlockInfo: info}
+	if b.CurrentLayer, err = s.readLwwID(1); err != nil {
+		return nil, err
+	}
+	if s.hasTag(2, tagLength4) {
+		v, err := s.readLwwBool(2)
+		if err != nil {
+			return nil, err
+		}
+		b.BackgroundVisible = &v
+	}
+	if s.hasTag(3, tagLength4) {
+		v, err := s.readLwwBool(3)
+		if err != nil {
+			return nil, err
+		}
+		b.RootDocumentVisible = &v
+	}
+	return b, nil
+}
+
+func (s *sceneReader) readSceneTree(info BlockInfo) (b *SceneTreeBlock, err error) {
+	b = &SceneTreeBlock{BlockInfo: info}
+	if b.TreeID, err = s.readID(1); err != nil {
+		return nil, err
+	}
+	if b.NodeID, err = s.readID(2); err != nil {
+		return nil, err
+	}
+	if b.IsUpdate, err = s.readBool(3); err != nil {
+		return nil, err
+	}
+	err = s.readSubblock(4, func(sub *sceneReader) error {
+		b.ParentID, err = sub.readID(1)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (s *sceneReader) readTreeNode(info BlockInfo) (b *TreeNodeBlock, err error) {
+	b = &TreeNodeBlock{BlockInfo: info}
+	if b.NodeID, err = s.readID(1); err != nil {
+		return nil, err
+	}
+	if b.Label, err = s.readLwwString(2); err != nil {
+		return nil, err
+	}
+	if b.Visible, err = s.readLwwBool(3); err != nil {
+		return nil, err
+	}
+	if s.hasTag(7, tagLength4) {
+		a := &Anchor{}
+		if a.ID, err = s.readLwwID(7); err != nil {
+			return nil, err
+		}
+		if a.Type, err = s.readLwwByte(8); err != nil {
+			return nil, err
+		}
+		if a.Threshold, err = s.readLwwFloat(9); err != nil {
+			return nil, err
+		}
+		if a.OriginX, err = s.readLwwFloat(10); err != nil {
+			return nil, err
+		}
+		b.Anchor = a
+	}
+	return b, nil
+}
+
+func (s *sceneReader) readSceneItem(info BlockInfo, t BlockType) (Block, error) {
+	var item SceneItem
+	var err error
+
+	if item.ParentID, err = s.readID(1); err != nil {
+		return nil, err
+	}
+	if item.ItemID, err = s.readID(2); err != nil {
+		return nil, err
+	}
+	if item.LeftID, err = s.readID(3); err != nil {
+		return nil, err
+	}
+	if item.RightID, err = s.readID(4); err != nil {
+		return nil, err
+	}
+	if item.DeletedLength, err = s.readInt(5); err != nil {
+		return nil, err
+	}
+
+	var block Block
+	switch t {
+	case SceneLineItemBlockType:
+		block = &SceneLineItemBlock{BlockInfo: info}
+	case SceneGroupItemBlockType:
+		block = &SceneGroupItemBlock{BlockInfo: info}
+	case SceneGlyphItemBlockType:
+		block = &SceneGlyphItemBlock{BlockInfo: info}
+	case SceneTextItemBlockType:
+		block = &SceneTextItemBlock{BlockInfo: info}
+	default:
+		block = &SceneTombstoneItemBlock{BlockInfo: info}
+	}
+
+	if s.hasTag(6, tagLength4) {
+		item.HasValue = true
+		err = s.readSubblock(6, func(sub *sceneReader) error {
+			if err := sub.readRaw(&item.ItemType); err != nil {
+				return err
+			}
+
+			var err error
+			switch b := block.(type) {
+			case *SceneLineItemBlock:
+				b.Line, err = sub.readLine(info.Version)
+			case *SceneGroupItemBlock:
+				b.NodeID, err = sub.readID(2)
+			case *SceneGlyphItemBlock:
+				b.Glyph, err = sub.readGlyphRange()
+			}
+			if err != nil {
+				return err
+			}
+
+			if sub.remaining() > 0 {
+				item.Extra, err = sub.readRemaining()
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch b := block.(type) {
+	case *SceneLineItemBlock:
+		b.SceneItem = item
+	case *SceneGroupItemBlock:
+		b.SceneItem = item
+	case *SceneGlyphItemBlock:
+		b.SceneItem = item
+	case *SceneTextItemBlock:
+		b.SceneItem = item
+	case *SceneTombstoneItemBlock:
+		b.SceneItem = item
+	}
+
+	return block, nil
+}
+
+func (s *sceneReader) readLine(version uint8) (line Line, err error) {
+	var tool, color uint32
+	if tool, err = s.readInt(1); err != nil {
+		return
+	}
+	line.Tool = BrushType(tool)
+	if color, err = s.readInt(2); err != nil {
+		return
+	}
+	line.Color = BrushColor(color)
+	if line.ThicknessScale, err = s.readDouble(3); err != nil {
+		return
+	}
+	if line.StartingLength, err = s.readFloat(4); err != nil {
+		return
+	}
+
+	err = s.readSubblock(5, func(sub *sceneReader) error {
+		size := int64(0x0e)
+		if version == 1 {
+			size = 0x18
+		}
+		nb := sub.remaining() / size
+		line.Points = make([]Point, nb)
+		for i := range line.Points {
+			p, err := sub.readPoint(version)
+			if err != nil {
+				return err
+			}
+			line.Points[i] = p
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	if line.Timestamp, err = s.readID(6); err != nil {
+		return
+	}
+	if s.hasTag(7, tagID) {
+		var id CrdtID
+		if id, err = s.readID(7); err != nil {
+			return
+		}
+		line.MoveID = &id
+	}
+	return
+}
+
+func (s *sceneReader) readPoint(version uint8) (Point, error) {
+	var p Point
+
+	if version == 1 {
+		var v1 struct {
+			X, Y, Speed, Direction, Width, Pressure float32
+		}
+		if err := s.readRaw(&v1); err != nil {
+			return p, err
+		}
+		return Segment{
+			X:         v1.X + float32(Width)/2,
+			Y:         v1.Y,
+			Speed:     v1.Speed,
+			Direction: v1.Direction,
+			Width:     v1.Width,
+			Pressure:  v1.Pressure,
+		}.point(), nil
+	}
+
+	err := s.readRaw(&p)
+	return p, err
+}
+
+func (s *sceneReader) readGlyphRange() (g GlyphRange, err error) {
+	if s.hasTag(2, tagByte4) {
+		var start uint32
+		if start, err = s.readInt(2); err != nil {
+			return
+		}
+		g.Start = &start
+	}
+	if g.Length, err = s.readInt(3); err != nil {
+		return
+	}
+	var color uint32
+	if color, err = s.readInt(4); err != nil {
+		return
+	}
+	g.Color = BrushColor(color)
+	if g.Text, err = s.readString(5); err != nil {
+		return
+	}
+	err = s.readSubblock(6, func(sub *sceneReader) error {
+		nb, err := sub.readVaruint()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < nb; i++ {
+			var r GlyphRect
+			if err := sub.readRaw(&r); err != nil {
+				return err
+			}
+			g.Rects = append(g.Rects, r)
+		}
+		return nil
+	})
+	return
+}
+
+func (s *sceneReader) readRootText(info BlockInfo) (b *RootTextBlock, err error) {
+	b = &RootTextBlock{BlockInfo: info}
+	if b.BlockID, err = s.readID(1); err != nil {
+		return nil, err
+	}
+
+	err = s.readSubblock(2, func(sub *sceneReader) error {
+		err := sub.readSubblock(1, func(sub *sceneReader) error {
+			return sub.readSubblock(1, func(sub *sceneReader) error {
+				nb, err := sub.readVaruint()
+				if err != nil {
+					return err
+				}
+				for i := uint64(0); i < nb; i++ {
+					item, err := sub.readTextItem()
+					if err != nil {
+						return err
+					}
+					b.Items = append(b.Items, item)
+				}
+				return nil
+			})
+		})
+		if err != nil {
+			return err
+		}
+
+		return sub.readSubblock(2, func(sub *sceneReader) error {
+			return sub.readSubblock(1, func(sub *sceneReader) error {
+				nb, err := sub.readVaruint()
+				if err != nil {
+					return err
+				}
+				for i := uint64(0); i < nb; i++ {
+					format, err := sub.readTextFormat()
+					if err != nil {
+						return err
+					}
+					b.Formats = append(b.Formats, format)
+				}
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.readSubblock(3, func(sub *sceneReader) error {
+		if err := sub.readRaw(&b.PosX); err != nil {
+			return err
+		}
+		return sub.readRaw(&b.PosY)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if b.Width, err = s.readFloat(4); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (s *sceneReader) readTextItem() (item TextItem, err error) {
+	err = s.readSubblock(0, func(sub *sceneReader) error {
+		var err error
+		if item.ItemID, err = sub.readID(2); err != nil {
+			return err
+		}
+		if item.LeftID, err = sub.readID(3); err != nil {
+			return err
+		}
+		if item.RightID, err = sub.readID(4); err != nil {
+			return err
+		}
+		if item.DeletedLength, err = sub.readInt(5); err != nil {
+			return err
+		}
+		if !sub.hasTag(6, tagLength4) {
+			return nil
+		}
+
+		item.HasValue = true
+		return sub.readSubblock(6, func(sub *sceneReader) error {
+			var err error
+			if item.Text, err = sub.readStringData(); err != nil {
+				return err
+			}
+			if sub.hasTag(2, tagByte4) {
+				item.HasFormat = true
+				item.Format, err = sub.readInt(2)
+			}
+			return err
+		})
+	})
+	return
+}
+
+func (s *sceneReader) readTextFormat() (f TextFormat, err error) {
+	if f.CharID, err = s.readCrdtID(); err != nil {
+		return
+	}
+	if f.Timestamp, err = s.readID(1); err != nil {
+		return
+	}
+	err = s.readSubblock(2, func(sub *sceneReader) error {
+		if err := sub.readRaw(&f.Unknown); err != nil {
+			return err
+		}
+		var style uint8
+		if err := sub.readRaw(&style); err != nil {
+			return err
+		}
+		f.Style = ParagraphStyle(style)
+		return nil
+	})
+	return
+}
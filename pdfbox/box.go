@@ -0,0 +1,180 @@
+// Package pdfbox finds where the pages of a pdf are shown: their crop box
+// and their rotation.
+package pdfbox
+
+import (
+	"bytes"
+	"math"
+
+	"github.com/juruen/rmapi/util"
+	"github.com/unidoc/unipdf/v3/core"
+	pdfmodel "github.com/unidoc/unipdf/v3/model"
+)
+
+// A Box is the visible area of a pdf page, its crop box, and the
+// rotation applied to it by the viewers, in degrees clockwise. The
+// tablet shows the page like the viewers.
+type Box struct {
+	Rect     pdfmodel.PdfRectangle
+	Rotation int
+	// Media is the media box of the page, which holds its crop box.
+	Media pdfmodel.PdfRectangle
+}
+
+// maxDepth limits the climb up the page tree of malformed files.
+const maxDepth = 32
+
+// Open parses a pdf, once for all that is read from it.
+func Open(payload []byte) (*pdfmodel.PdfReader, error) {
+	var reader *pdfmodel.PdfReader
+	err := util.Safely(func() error {
+		var err error
+		reader, err = pdfmodel.NewPdfReader(bytes.NewReader(payload))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+// Read reads the boxes of the pages of a pdf, numbered from 1.
+func Read(reader *pdfmodel.PdfReader) (map[int]Box, error) {
+	boxes := make(map[int]Box)
+	err := util.Safely(func() error {
+		count, err := reader.GetNumPages()
+		if err != nil {
+			return err
+		}
+
+		for num := 1; num <= count; num++ {
+			page, err := reader.GetPage(num)
+			if err != nil {
+				return err
+			}
+			if boxes[num], err = ReadPage(page); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return boxes, nil
+}
+
+// ReadPage reads the crop box of a page, which defaults to its media
+// box, and its rotation, both of which can be inherited from the page tree.
+func ReadPage(page *pdfmodel.PdfPage) (Box, error) {
+	media, err := page.GetMediaBox()
+	if err != nil {
+		return Box{}, err
+	}
+
+	crop, rotate := page.CropBox, page.Rotate
+	parent := page.Parent
+	for depth := 0; parent != nil && (crop == nil || rotate == nil) && depth < maxDepth; depth++ {
+		dict, ok := core.GetDict(parent)
+		if !ok {
+			break
+		}
+		if arr, ok := core.GetArray(dict.Get("CropBox")); ok && crop == nil {
+			if r, err := pdfmodel.NewPdfRectangle(*arr); err == nil {
+				crop = r
+			}
+		}
+		if r, ok := core.GetIntVal(dict.Get("Rotate")); ok && rotate == nil {
+			r64 := int64(r)
+			rotate = &r64
+		}
+		parent = dict.Get("Parent")
+	}
+
+	box := Box{Rect: Normalize(*media), Media: Normalize(*media)}
+	if crop != nil {
+		box.Rect = Normalize(*crop)
+	}
+
+	// the rotation must be a multiple of 90, others are ignored like gofpdi does
+	if rotate != nil && *rotate%90 == 0 {
+		box.Rotation = int((*rotate%360 + 360) % 360)
+	}
+	return box, nil
+}
+
+// Normalize returns a rectangle whose lower left corner comes first,
+// as some writers swap the corners.
+func Normalize(r pdfmodel.PdfRectangle) pdfmodel.PdfRectangle {
+	return pdfmodel.PdfRectangle{
+		Llx: math.Min(r.Llx, r.Urx),
+		Lly: math.Min(r.Lly, r.Ury),
+		Urx: math.Max(r.Llx, r.Urx),
+		Ury: math.Max(r.Lly, r.Ury),
+	}
+}
+
+// Size returns the size of the page as shown.
+func (b Box) Size() (float64, float64) {
+	if b.Rotation == 90 || b.Rotation == 270 {
+		return b.Rect.Height(), b.Rect.Width()
+	}
+	return b.Rect.Width(), b.Rect.Height()
+}
+
+// FromPdf converts a point of the pdf page to the page as shown,
+// from its top left corner.
+func (b Box) FromPdf(x, y float64) (float64, float64) {
+	r := b.Rect
+	switch b.Rotation {
+	case 90:
+		return y - r.Lly, x - r.Llx
+	case 180:
+		return r.Urx - x, y - r.Lly
+	case 270:
+		return r.Ury - y, r.Urx - x
+	}
+	return x - r.Llx, r.Ury - y
+}
+
+// ToPdf converts a point of the page as shown, from its top left corner,
+// to the pdf page.
+func (b Box) ToPdf(x, y float64) (float64, float64) {
+	r := b.Rect
+	switch b.Rotation {
+	case 90:
+		return r.Llx + y, r.Lly + x
+	case 180:
+		return r.Urx - x, r.Lly + y
+	case 270:
+		return r.Urx - y, r.Ury - x
+	}
+	return r.Llx + x, r.Ury - y
+}
+
+// TemplateRect returns where to draw the template gofpdi imports the page
+// into, from the top left corner of the page as shown, and its size, so that
+// the box is shown from that corner. gofpdi gives the templates of all the
+// pages the media box of the first one, first, rotated like each page.
+func (b Box) TemplateRect(first Box) (x, y, w, h float64) {
+	tpl := Box{Rect: first.Media, Rotation: b.Rotation}
+	x, y, _, _ = tpl.FromPdfRect(b.Rect)
+	w, h = tpl.Size()
+	return -x, -y, w, h
+}
+
+// FromPdfRect converts a rectangle of the pdf page to the position of its
+// top left corner and its size on the page as shown.
+func (b Box) FromPdfRect(rect pdfmodel.PdfRectangle) (x, y, w, h float64) {
+	x0, y0 := b.FromPdf(rect.Llx, rect.Lly)
+	x1, y1 := b.FromPdf(rect.Urx, rect.Ury)
+	return math.Min(x0, x1), math.Min(y0, y1), math.Abs(x1 - x0), math.Abs(y1 - y0)
+}
+
+// ToPdfRect converts the rectangle between two corners on the page
+// as shown to the pdf page.
+func (b Box) ToPdfRect(x0, y0, x1, y1 float64) pdfmodel.PdfRectangle {
+	px0, py0 := b.ToPdf(x0, y0)
+	px1, py1 := b.ToPdf(x1, y1)
+	return Normalize(pdfmodel.PdfRectangle{Llx: px0, Lly: py0, Urx: px1, Ury: py1})
+}
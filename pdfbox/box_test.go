@@ -0,0 +1,42 @@
+package pdfbox
+
+import (
+	"testing"
+
+	pdfmodel "github.com/unidoc/unipdf/v3/model"
+)
+
+func TestConversions(t *testing.T) {
+	rect := pdfmodel.PdfRectangle{Llx: 10, Lly: 20, Urx: 110, Ury: 220}
+	// the corner of the pdf page shown at the top left
+	corners := map[int][2]float64{0: {10, 220}, 90: {10, 20}, 180: {110, 20}, 270: {110, 220}}
+
+	for rotation, corner := range corners {
+		box := Box{Rect: rect, Rotation: rotation}
+		if x, y := box.ToPdf(0, 0); x != corner[0] || y != corner[1] {
+			t.Errorf("rotation %d: top left corner at %v %v, expected %v", rotation, x, y, corner)
+		}
+		x, y := box.FromPdf(30, 50)
+		if px, py := box.ToPdf(x, y); px != 30 || py != 50 {
+			t.Errorf("rotation %d: %v %v converted back to %v %v", rotation, x, y, px, py)
+		}
+		w, h := box.Size()
+		if x < 0 || y < 0 || x > w || y > h {
+			t.Errorf("rotation %d: %v %v outside of the page", rotation, x, y)
+		}
+	}
+}
+
+func TestTemplateRect(t *testing.T) {
+	first := Box{Rect: pdfmodel.PdfRectangle{Urx: 595, Ury: 842}, Media: pdfmodel.PdfRectangle{Urx: 595, Ury: 842}}
+	crop := pdfmodel.PdfRectangle{Llx: 50, Lly: 60, Urx: 545, Ury: 792}
+
+	// the media box of the first page is drawn so that the crop box is at the top left corner
+	expected := map[int][4]float64{0: {-50, -50, 595, 842}, 90: {-60, -50, 842, 595}}
+	for rotation, e := range expected {
+		x, y, w, h := Box{Rect: crop, Rotation: rotation}.TemplateRect(first)
+		if [4]float64{x, y, w, h} != e {
+			t.Errorf("rotation %d: template at %v %v %vx%v, expected %v", rotation, x, y, w, h, e)
+		}
+	}
+}
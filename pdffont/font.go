@@ -0,0 +1,91 @@
+// Package pdffont embeds the fonts of the text written in pdf documents:
+// the Go fonts, whose glyphs cover the latin, greek and cyrillic scripts
+// unlike the core fonts of pdf which are limited to cp1252.
+package pdffont
+
+import (
+	"sync"
+
+	"github.com/juruen/rmapi/log"
+	"github.com/phpdave/gofpdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/gomonobolditalic"
+	"golang.org/x/image/font/gofont/gomonoitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+)
+
+// The font families, with the styles "", B, I and BI.
+const (
+	Text = "Go"
+	Code = "Go Mono"
+)
+
+var fontFiles = []struct {
+	family, style string
+	ttf           []byte
+}{
+	{Text, "", goregular.TTF},
+	{Text, "B", gobold.TTF},
+	{Text, "I", goitalic.TTF},
+	{Text, "BI", gobolditalic.TTF},
+	{Code, "", gomono.TTF},
+	{Code, "B", gomonobold.TTF},
+	{Code, "I", gomonoitalic.TTF},
+	{Code, "BI", gomonobolditalic.TTF},
+}
+
+// Add adds the styles of a font family to a pdf, the text written with
+// them being UTF-8. They are embedded in the pdf, whether they are used
+// or not, and the ones already added are left as they are.
+func Add(pdf *gofpdf.Fpdf, family string) {
+	for _, f := range fontFiles {
+		if f.family == family {
+			pdf.AddUTF8FontFromBytes(f.family, f.style, f.ttf)
+		}
+	}
+}
+
+var (
+	parseFont sync.Once
+	font      *sfnt.Font
+)
+
+// MissingGlyphs returns the characters of text that the fonts
+// can't show, once each.
+func MissingGlyphs(text string) []rune {
+	parseFont.Do(func() {
+		var err error
+		if font, err = sfnt.Parse(goregular.TTF); err != nil {
+			log.Warning.Printf("can't parse the text font: %v", err)
+		}
+	})
+	if font == nil {
+		return nil
+	}
+
+	var b sfnt.Buffer
+	var missing []rune
+	seen := make(map[rune]bool)
+	for _, r := range text {
+		if r < ' ' || seen[r] {
+			continue
+		}
+		seen[r] = true
+		if idx, err := font.GlyphIndex(&b, r); err != nil || idx == 0 {
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
+
+// WarnMissingGlyphs logs the characters of text that are left blank.
+func WarnMissingGlyphs(text string) {
+	if missing := MissingGlyphs(text); len(missing) > 0 {
+		log.Warning.Printf("no glyph for %q in the font, these characters are left blank", string(missing))
+	}
+}
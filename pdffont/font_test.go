@@ -0,0 +1,18 @@
+package pdffont
+
+import (
+	"testing"
+
+	"github.com/juruen/rmapi/log"
+)
+
+func TestMissingGlyphs(t *testing.T) {
+	log.InitLog()
+
+	if missing := MissingGlyphs("café – “Ελληνικά” Кириллица • €\n\t"); len(missing) != 0 {
+		t.Errorf("unexpected missing glyphs %q", string(missing))
+	}
+	if missing := MissingGlyphs("漢字 漢"); string(missing) != "漢字" {
+		t.Errorf("got missing glyphs %q, want %q", string(missing), "漢字")
+	}
+}
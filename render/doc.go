@@ -0,0 +1,13 @@
+// Package render draws the content of a .rm page decoded by the
+// encoding/rm package, without going through a PDF.
+//
+// The output uses the coordinates of the device: a page is a canvas
+// of rm.Width x rm.Height pixels. Each brush is styled following the
+// same magic numbers as the annotations package (based on RMRL and hand
+// tuned), the width and the shade of the pressure sensitive brushes
+// varying along the stroke.
+//
+// Like the annotations package, eraser and erase-area strokes are resolved
+// with rm.ResolveErasers first: they aren't drawn and what they erased is
+// removed from the strokes drawn before them.
+package render
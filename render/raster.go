@@ -0,0 +1,226 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/juruen/rmapi/encoding/rm"
+	"golang.org/x/image/vector"
+)
+
+// DeviceDPI is the resolution of the screen of the device.
+const DeviceDPI = 226.0
+
+// RasterOptions tells how to rasterize a page.
+type RasterOptions struct {
+	// DPI of the image, the device resolution is used if zero.
+	DPI float64
+	// Background fills the image before drawing, white if nil.
+	Background color.Color
+}
+
+func (o RasterOptions) scale() float64 {
+	if o.DPI <= 0 {
+		return 1
+	}
+	return o.DPI / DeviceDPI
+}
+
+func (o RasterOptions) background() color.Color {
+	if o.Background == nil {
+		return color.White
+	}
+	return o.Background
+}
+
+// Raster draws a page onto a new anti-aliased image.
+// Erasers are resolved before drawing.
+func Raster(page *rm.Rm, opts RasterOptions) *image.RGBA {
+	scale := opts.scale()
+	width := int(math.Ceil(float64(rm.Width) * scale))
+	height := int(math.Ceil(float64(rm.Height) * scale))
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(opts.background()), image.Point{}, draw.Src)
+
+	r := &rasterizer{dst: img, scale: scale}
+	for _, layer := range rm.ResolveErasers(page).Layers {
+		for _, stroke := range layer.Strokes {
+			r.drawStroke(stroke)
+		}
+	}
+
+	return img
+}
+
+// PNG writes a page as a PNG image.
+func PNG(w io.Writer, page *rm.Rm, opts RasterOptions) error {
+	return png.Encode(w, Raster(page, opts))
+}
+
+type rasterizer struct {
+	dst   *image.RGBA
+	scale float64
+	v     vector.Rasterizer
+}
+
+type point struct {
+	X, Y float64
+}
+
+func (r *rasterizer) drawStroke(stroke rm.Stroke) {
+	if len(stroke.Segments) == 0 {
+		return
+	}
+
+	style := styleOf(stroke)
+	if style.Skip {
+		return
+	}
+
+	// strokes of constant style are filled at once so that
+	// the joints of transparent strokes don't overlap
+	if !style.Variable {
+		s := segmentStyleOf(stroke, 0)
+		var polys [][]point
+		for idx := range stroke.Segments {
+			polys = append(polys, r.capsule(stroke, idx, s.Width, style.Square))
+		}
+		r.fill(polys, image.NewUniform(withOpacity(s.Color, s.Opacity)))
+		return
+	}
+
+	pencil := isPencil(stroke.BrushType)
+	for idx := range stroke.Segments {
+		if idx == 0 && len(stroke.Segments) > 1 {
+			continue
+		}
+		s := segmentStyleOf(stroke, idx)
+		poly := r.capsule(stroke, idx, s.Width, false)
+
+		var src image.Image = image.NewUniform(withOpacity(s.Color, s.Opacity))
+		if pencil {
+			src = &pencilTexture{c: s.Color, pressure: stroke.Segments[idx].Pressure}
+		}
+		r.fill([][]point{poly}, src)
+	}
+}
+
+// capsule returns the outline of the segment idx of a stroke, joining
+// it to the previous segment with round or square caps.
+func (r *rasterizer) capsule(stroke rm.Stroke, idx int, width float64, square bool) []point {
+	seg := stroke.Segments[idx]
+	prev := seg
+	if idx > 0 {
+		prev = stroke.Segments[idx-1]
+	}
+
+	p0 := point{float64(prev.X) * r.scale, float64(prev.Y) * r.scale}
+	p1 := point{float64(seg.X) * r.scale, float64(seg.Y) * r.scale}
+	radius := math.Max(width*r.scale/2, 0.5)
+
+	angle := math.Atan2(p1.Y-p0.Y, p1.X-p0.X)
+
+	if square {
+		d := point{math.Cos(angle) * radius, math.Sin(angle) * radius}
+		n := point{-d.Y, d.X}
+		return []point{
+			{p1.X + d.X - n.X, p1.Y + d.Y - n.Y},
+			{p1.X + d.X + n.X, p1.Y + d.Y + n.Y},
+			{p0.X - d.X + n.X, p0.Y - d.Y + n.Y},
+			{p0.X - d.X - n.X, p0.Y - d.Y - n.Y},
+		}
+	}
+
+	// half circles around both ends, always turning the same way
+	// so that overlapping outlines are merged by the rasterizer
+	steps := int(math.Max(4, math.Min(32, radius*2)))
+	poly := make([]point, 0, 2*steps+2)
+	for i := 0; i <= steps; i++ {
+		a := angle - math.Pi/2 + math.Pi*float64(i)/float64(steps)
+		poly = append(poly, point{p1.X + math.Cos(a)*radius, p1.Y + math.Sin(a)*radius})
+	}
+	for i := 0; i <= steps; i++ {
+		a := angle + math.Pi/2 + math.Pi*float64(i)/float64(steps)
+		poly = append(poly, point{p0.X + math.Cos(a)*radius, p0.Y + math.Sin(a)*radius})
+	}
+	return poly
+}
+
+// fill rasterizes the polygons on the smallest possible area.
+func (r *rasterizer) fill(polys [][]point, src image.Image) {
+	if len(polys) == 0 {
+		return
+	}
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, poly := range polys {
+		for _, p := range poly {
+			minX, minY = math.Min(minX, p.X), math.Min(minY, p.Y)
+			maxX, maxY = math.Max(maxX, p.X), math.Max(maxY, p.Y)
+		}
+	}
+
+	bounds := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)),
+		int(math.Ceil(maxX)), int(math.Ceil(maxY))).Intersect(r.dst.Bounds())
+	if bounds.Empty() {
+		return
+	}
+
+	r.v.Reset(bounds.Dx(), bounds.Dy())
+	ox, oy := float64(bounds.Min.X), float64(bounds.Min.Y)
+	for _, poly := range polys {
+		r.v.MoveTo(float32(poly[0].X-ox), float32(poly[0].Y-oy))
+		for _, p := range poly[1:] {
+			r.v.LineTo(float32(p.X-ox), float32(p.Y-oy))
+		}
+		r.v.ClosePath()
+	}
+	r.v.Draw(r.dst, bounds, src, bounds.Min)
+}
+
+func isPencil(t rm.BrushType) bool {
+	switch t {
+	case rm.Pencil, rm.PencilV5, rm.MechanicalPencil, rm.MechanicalPencilV5:
+		return true
+	}
+	return false
+}
+
+func withOpacity(c color.RGBA, opacity float64) color.NRGBA {
+	return color.NRGBA{R: c.R, G: c.G, B: c.B, A: uint8(float64(c.A) * opacity)}
+}
+
+// pencilTexture is a grainy color whose density grows with the pressure,
+// the grain being a hash of the position so that rendering is reproducible.
+type pencilTexture struct {
+	c        color.RGBA
+	pressure float32
+}
+
+func (p *pencilTexture) ColorModel() color.Model {
+	return color.NRGBAModel
+}
+
+func (p *pencilTexture) Bounds() image.Rectangle {
+	return image.Rect(-1e9, -1e9, 1e9, 1e9)
+}
+
+func (p *pencilTexture) At(x, y int) color.Color {
+	h := uint32(x)*73856093 ^ uint32(y)*19349663
+	h ^= h >> 13
+	h *= 0x5bd1e995
+	h ^= h >> 15
+	grain := float64(h&0xff) / 255
+
+	pressure := math.Min(math.Max(float64(p.pressure), 0), 1)
+	density := 0.35 + 0.65*pressure
+	alpha := density * (0.6 + 0.4*grain)
+
+	return withOpacity(p.c, alpha)
+}
@@ -0,0 +1,67 @@
+package render
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/juruen/rmapi/encoding/rm"
+)
+
+func TestRaster(t *testing.T) {
+	page := readPage(t, "../encoding/rm/test_v5.rm")
+
+	var out bytes.Buffer
+	if err := PNG(&out, page, RasterOptions{DPI: DeviceDPI / 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := png.Decode(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != rm.Width/2 || b.Dy() != rm.Height/2 {
+		t.Errorf("wrong size %v", b)
+	}
+
+	inked := false
+	for y := 0; y < img.Bounds().Dy() && !inked; y++ {
+		for x := 0; x < img.Bounds().Dx(); x++ {
+			if r, _, _, _ := img.At(x, y).RGBA(); r < 0x8000 {
+				inked = true
+				break
+			}
+		}
+	}
+	if !inked {
+		t.Error("no stroke drawn")
+	}
+}
+
+func TestRasterBrushes(t *testing.T) {
+	line := func(t rm.BrushType, color rm.BrushColor, y float32) rm.Stroke {
+		return rm.Stroke{BrushType: t, BrushColor: color, Segments: []rm.Segment{
+			{X: 100, Y: y, Width: 20, Pressure: 1},
+			{X: 400, Y: y, Width: 20, Pressure: 1},
+		}}
+	}
+	page := &rm.Rm{Layers: []rm.Layer{{Strokes: []rm.Stroke{
+		line(rm.FinelinerV5, rm.Black, 100),
+		line(rm.HighlighterV5, rm.Black, 200),
+		line(rm.FinelinerV5, rm.Black, 300),
+		line(rm.Eraser, rm.Black, 300),
+	}}}}
+
+	img := Raster(page, RasterOptions{})
+
+	if c := img.RGBAAt(250, 100); c.R > 10 {
+		t.Errorf("fineliner should be black, got %v", c)
+	}
+	if c := img.RGBAAt(250, 200); c.B == 255 || c.R < 200 {
+		t.Errorf("highlighter should be transparent yellow, got %v", c)
+	}
+	if c := img.RGBAAt(250, 300); c != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("eraser should paint the background, got %v", c)
+	}
+}
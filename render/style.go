@@ -0,0 +1,110 @@
+package render
+
+import (
+	"image/color"
+
+	"github.com/juruen/rmapi/encoding/rm"
+)
+
+// Palette maps the brush colors to the colors used for rendering,
+// it can be changed to customize the output.
+var Palette = rm.DefaultPalette()
+
+const (
+	// highlightOpacity is the opacity of highlighter strokes.
+	highlightOpacity = 0.3
+	// minWidth keeps very light strokes visible.
+	minWidth = 0.5
+)
+
+// A segmentStyle describes how to draw the part of a stroke
+// going from the previous segment to the current one.
+type segmentStyle struct {
+	Width   float64
+	Color   color.RGBA
+	Opacity float64
+}
+
+// strokeStyle tells how a whole stroke is drawn.
+type strokeStyle struct {
+	// Variable is set when the width or the color
+	// change along the stroke
+	Variable bool
+	// Square caps are used for the highlighter
+	Square bool
+	// Skip is set for erasers
+	Skip bool
+}
+
+func styleOf(stroke rm.Stroke) strokeStyle {
+	switch stroke.BrushType {
+	case rm.EraseArea, rm.Eraser:
+		return strokeStyle{Skip: true}
+	case rm.Highlighter, rm.HighlighterV5:
+		return strokeStyle{Square: true}
+	case rm.Marker, rm.MarkerV5, rm.Fineliner, rm.FinelinerV5:
+		return strokeStyle{}
+	default:
+		return strokeStyle{Variable: true}
+	}
+}
+
+// segmentStyleOf returns the style of the segment idx of the stroke.
+// Beware! Here lie magic numbers aplenty, they are the ones
+// of annotations.PaintStroke.
+func segmentStyleOf(stroke rm.Stroke, idx int) segmentStyle {
+	segment := stroke.Segments[idx]
+
+	base := Palette.RGBA(stroke.BrushColor)
+	style := segmentStyle{
+		Width:   float64(segment.Width),
+		Color:   base,
+		Opacity: 1,
+	}
+
+	switch stroke.BrushType {
+	case rm.MechanicalPencil, rm.MechanicalPencilV5:
+		style.Color = shade(base, segment.Pressure)
+		style.Width = float64(segment.Width) * 1.5
+
+	case rm.Pencil, rm.PencilV5:
+		style.Color = shade(base, segment.Pressure)
+		style.Width = float64(segment.Width) * 0.58
+
+	case rm.Brush, rm.BrushV5:
+		modwidth := segment.Width * 0.75
+		maxdelta := modwidth * 0.75
+		delta := (segment.Pressure - 1) * maxdelta
+		style.Width = float64(modwidth + delta)
+		style.Color = shade(base, segment.Pressure*(1-(segment.Speed/150)))
+
+	case rm.BallPoint, rm.BallPointV5:
+		maxdelta := segment.Width / 2
+		delta := (segment.Pressure - 1) * maxdelta
+		style.Width = float64(segment.Width + delta)
+
+	case rm.Highlighter, rm.HighlighterV5:
+		style.Color = Palette.Highlight(stroke.BrushColor)
+		style.Opacity = highlightOpacity
+	}
+
+	if style.Width < minWidth {
+		style.Width = minWidth
+	}
+
+	return style
+}
+
+// shade lightens a color towards white as the pressure drops.
+func shade(c color.RGBA, pressure float32) color.RGBA {
+	if pressure < 0 {
+		pressure = 0
+	}
+	if pressure > 1 {
+		pressure = 1
+	}
+	scale := func(v uint8) uint8 {
+		return uint8(255 - (255-float32(v))*pressure)
+	}
+	return color.RGBA{R: scale(c.R), G: scale(c.G), B: scale(c.B), A: c.A}
+}
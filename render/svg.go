@@ -0,0 +1,110 @@
+package render
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/juruen/rmapi/encoding/rm"
+)
+
+// SVG writes a page as a standalone SVG document sized like the device.
+// Each layer is a <g> group. Strokes of constant width are written as a
+// single polyline, pressure sensitive ones as one line per segment.
+// Erasers are resolved before drawing.
+func SVG(w io.Writer, page *rm.Rm) error {
+	bw := bufio.NewWriter(w)
+	page = rm.ResolveErasers(page)
+
+	fmt.Fprintf(bw, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(bw, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n",
+		rm.Width, rm.Height, rm.Width, rm.Height)
+
+	for i, layer := range page.Layers {
+		name := layer.Name
+		if name == "" {
+			name = fmt.Sprintf("Layer %d", i+1)
+		}
+
+		fmt.Fprintf(bw, "<g id=\"layer%d\">\n<title>", i+1)
+		if err := xml.EscapeText(bw, []byte(name)); err != nil {
+			return err
+		}
+		fmt.Fprintf(bw, "</title>\n")
+
+		for _, stroke := range layer.Strokes {
+			writeSVGStroke(bw, stroke)
+		}
+
+		fmt.Fprintf(bw, "</g>\n")
+	}
+
+	fmt.Fprintf(bw, "</svg>\n")
+
+	return bw.Flush()
+}
+
+func writeSVGStroke(w io.Writer, stroke rm.Stroke) {
+	style := styleOf(stroke)
+	if style.Skip || len(stroke.Segments) == 0 {
+		return
+	}
+
+	linecap := "round"
+	if style.Square {
+		linecap = "square"
+	}
+
+	if !style.Variable {
+		s := segmentStyleOf(stroke, 0)
+		points := make([]string, 0, len(stroke.Segments))
+		for _, segment := range stroke.Segments {
+			points = append(points, svgFloat(segment.X)+","+svgFloat(segment.Y))
+		}
+		// a single point is drawn as a dot
+		if len(points) == 1 {
+			points = append(points, points[0])
+		}
+		fmt.Fprintf(w, "<polyline points=\"%s\" %s stroke-linecap=\"%s\" stroke-linejoin=\"round\"/>\n",
+			strings.Join(points, " "), svgStyle(s), linecap)
+		return
+	}
+
+	fmt.Fprintf(w, "<g stroke-linecap=\"%s\">\n", linecap)
+	for idx, segment := range stroke.Segments {
+		if idx == 0 && len(stroke.Segments) > 1 {
+			continue
+		}
+		prev := stroke.Segments[0]
+		if idx > 0 {
+			prev = stroke.Segments[idx-1]
+		}
+		fmt.Fprintf(w, "<line x1=\"%s\" y1=\"%s\" x2=\"%s\" y2=\"%s\" %s/>\n",
+			svgFloat(prev.X), svgFloat(prev.Y), svgFloat(segment.X), svgFloat(segment.Y),
+			svgStyle(segmentStyleOf(stroke, idx)))
+	}
+	fmt.Fprintf(w, "</g>\n")
+}
+
+func svgStyle(s segmentStyle) string {
+	style := fmt.Sprintf("fill=\"none\" stroke=\"%s\" stroke-width=\"%s\"",
+		svgColor(s.Color), svgFloat(float32(s.Width)))
+	if s.Opacity < 1 {
+		style += fmt.Sprintf(" stroke-opacity=\"%s\"", svgFloat(float32(s.Opacity)))
+	}
+	return style
+}
+
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// svgFloat keeps two decimals, enough for the device resolution.
+func svgFloat(f float32) string {
+	return strconv.FormatFloat(math.Round(float64(f)*100)/100, 'f', -1, 64)
+}
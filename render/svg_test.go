@@ -0,0 +1,73 @@
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/juruen/rmapi/encoding/rm"
+)
+
+func readPage(t *testing.T, fn string) *rm.Rm {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page := rm.New()
+	if err := page.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	return page
+}
+
+func TestSVG(t *testing.T) {
+	page := readPage(t, "../encoding/rm/test_v5.rm")
+	page.Layers[0].Name = "Notes & <sketches>"
+	page.Layers[0].Strokes = append(page.Layers[0].Strokes,
+		rm.Stroke{BrushType: rm.HighlighterV5, Segments: []rm.Segment{{X: 10, Y: 10, Width: 30}, {X: 200, Y: 10, Width: 30}}},
+		rm.Stroke{BrushType: rm.Eraser, Segments: []rm.Segment{{X: 10, Y: 10, Width: 30}}},
+	)
+
+	var out bytes.Buffer
+	if err := SVG(&out, page); err != nil {
+		t.Fatal(err)
+	}
+
+	svg := out.String()
+	groups := 0
+	title := ""
+	d := xml.NewDecoder(&out)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid svg: %v", err)
+		}
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local == "g" {
+			for _, attr := range se.Attr {
+				if attr.Name.Local == "id" && strings.HasPrefix(attr.Value, "layer") {
+					groups++
+				}
+			}
+		}
+		if cd, ok := tok.(xml.CharData); ok && title == "" && strings.TrimSpace(string(cd)) != "" {
+			title = string(cd)
+		}
+	}
+
+	if groups != len(page.Layers) {
+		t.Errorf("got %d layer groups, want %d", groups, len(page.Layers))
+	}
+	if title != "Notes & <sketches>" {
+		t.Errorf("layer name not escaped correctly: %q", title)
+	}
+	if !strings.Contains(svg, "stroke-opacity=\"0.3\"") {
+		t.Error("highlighter should be transparent")
+	}
+}
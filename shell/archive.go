@@ -0,0 +1,77 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/abiosoft/ishell"
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/model"
+)
+
+// fetchZip downloads a document in dir and reads it.
+func fetchZip(ctx *ShellCtxt, node *model.Node, dir string) (*archive.Zip, error) {
+	zipName := filepath.Join(dir, node.Id()+".zip")
+	if err := ctx.api.FetchDocument(node.Document.ID, zipName); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(zipName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	zip := archive.NewZip()
+	if err := zip.Read(file, fi.Size()); err != nil {
+		return nil, err
+	}
+	return zip, nil
+}
+
+// uploadZip uploads an archive as a new document named name in the
+// directory dir, the archive being written in tmpDir first.
+func uploadZip(ctx *ShellCtxt, c *ishell.Context, zip *archive.Zip, name string, dir *model.Node, tmpDir string) error {
+	if _, err := ctx.api.Filetree.NodeByPath(name, dir); err == nil {
+		return fmt.Errorf("entry %s already exists", name)
+	}
+
+	// the name of the zip file is the name of the document
+	zipName := filepath.Join(tmpDir, name+".zip")
+	if err := writeZip(zip, zipName); err != nil {
+		return err
+	}
+
+	c.Printf("uploading: [%s] with %d pages...", name, len(zip.Pages))
+
+	document, err := ctx.api.UploadDocument(dir.Id(), zipName)
+	if err != nil {
+		return fmt.Errorf("Failed to upload [%s] %v", name, err)
+	}
+
+	c.Println("OK")
+
+	ctx.api.Filetree.AddDocument(*document)
+	return nil
+}
+
+// writeZip writes an archive to fileName.
+func writeZip(zip *archive.Zip, fileName string) error {
+	out, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+
+	if err := zip.Write(out); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
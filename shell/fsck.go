@@ -0,0 +1,82 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/abiosoft/ishell"
+	"github.com/juruen/rmapi/archive"
+)
+
+func fsckCmd(ctx *ShellCtxt) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name:      "fsck",
+		Help:      "check that a local zip file or a remote document can be opened by the tablet",
+		Completer: createEntryCompleter(ctx),
+		Func: func(c *ishell.Context) {
+			if len(c.Args) == 0 {
+				c.Err(errors.New("missing file"))
+				return
+			}
+
+			srcName := c.Args[0]
+			zipName := srcName
+
+			// local files come first
+			if _, err := os.Stat(srcName); err != nil {
+				node, err := ctx.api.Filetree.NodeByPath(srcName, ctx.node)
+				if err != nil || node.IsDirectory() {
+					c.Err(errors.New("file doesn't exist"))
+					return
+				}
+
+				tmpDir, err := ioutil.TempDir("", "rmapifsck")
+				if err != nil {
+					c.Err(err)
+					return
+				}
+				defer os.RemoveAll(tmpDir)
+
+				c.Println(fmt.Sprintf("downloading: [%s]...", srcName))
+				zipName = filepath.Join(tmpDir, node.Id()+".zip")
+				if err := ctx.api.FetchDocument(node.Document.ID, zipName); err != nil {
+					c.Err(fmt.Errorf("Failed to download file %s with %v", srcName, err))
+					return
+				}
+			}
+
+			problems, err := validateZip(zipName)
+			if err != nil {
+				c.Err(err)
+				return
+			}
+
+			for _, p := range problems {
+				c.Println(p)
+			}
+			if len(problems) > 0 {
+				c.Err(fmt.Errorf("%d problems found in %s", len(problems), srcName))
+				return
+			}
+			c.Println("no problem found")
+		},
+	}
+}
+
+func validateZip(zipName string) ([]archive.Problem, error) {
+	file, err := os.Open(zipName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return archive.ValidateArchive(file, fi.Size())
+}
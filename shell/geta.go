@@ -4,6 +4,8 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"strings"
+
 	"github.com/abiosoft/ishell"
 	"github.com/juruen/rmapi/annotations"
 )
@@ -17,6 +19,10 @@ func getACmd(ctx *ShellCtxt) *ishell.Cmd {
 
 			flagSet := flag.NewFlagSet("geta", flag.ContinueOnError)
 			addPageNumbers := flagSet.Bool("p", false, "add page numbers")
+			numberPosition := flagSet.String("number-position", annotations.BottomCenter,
+				"position of the page numbers: "+strings.Join(annotations.PageNumberPositions, ", "))
+			numberFormat := flagSet.String("number-format", annotations.DefaultPageNumberFormat,
+				"text of the page numbers, {n} being the page and {total} the number of pages")
 			allPages := flagSet.Bool("a", false, "all pages")
 			annotationsOnly := flagSet.Bool("n", false, "annotations only")
 			if err := flagSet.Parse(c.Args); err != nil {
@@ -51,7 +57,8 @@ func getACmd(ctx *ShellCtxt) *ishell.Cmd {
 			}
 
 			pdfName := fmt.Sprintf("%s-annotations.pdf", node.Name())
-			options := annotations.PdfGeneratorOptions{AddPageNumbers: *addPageNumbers, AllPages: *allPages, AnnotationsOnly: *annotationsOnly}
+			options := annotations.PdfGeneratorOptions{AddPageNumbers: *addPageNumbers, AllPages: *allPages, AnnotationsOnly: *annotationsOnly,
+				PageNumberPosition: *numberPosition, PageNumberFormat: *numberFormat}
 			generator := annotations.CreatePdfGenerator(zipName, pdfName, options)
 			err = generator.Generate()
 
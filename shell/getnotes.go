@@ -0,0 +1,116 @@
+package shell
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/abiosoft/ishell"
+	"github.com/juruen/rmapi/annotations"
+	"github.com/juruen/rmapi/archive"
+)
+
+func getNotesCmd(ctx *ShellCtxt) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name:      "getnotes",
+		Help:      "copy remote file to local and export its highlights and handwritten notes as Markdown or JSON",
+		Completer: createEntryCompleter(ctx),
+		Func: func(c *ishell.Context) {
+
+			flagSet := flag.NewFlagSet("getnotes", flag.ContinueOnError)
+			format := flagSet.String("f", "md", "format of the digest: md or json")
+			images := flagSet.String("i", "png", "format of the renderings of the notes: png, svg or none")
+			if err := flagSet.Parse(c.Args); err != nil {
+				if err != flag.ErrHelp {
+					c.Err(err)
+				}
+				return
+			}
+			argRest := flagSet.Args()
+			if len(argRest) == 0 {
+				c.Err(errors.New("missing source file"))
+				return
+			}
+			if *format != "md" && *format != "json" {
+				c.Err(fmt.Errorf("unsupported format: %s", *format))
+				return
+			}
+			if *images == "none" {
+				*images = ""
+			}
+
+			srcName := argRest[0]
+
+			node, err := ctx.api.Filetree.NodeByPath(srcName, ctx.node)
+
+			if err != nil || node.IsDirectory() {
+				c.Err(errors.New("file doesn't exist"))
+				return
+			}
+
+			c.Println(fmt.Sprintf("downloading: [%s]...", srcName))
+
+			zipName := fmt.Sprintf("%s.zip", node.Name())
+			err = ctx.api.FetchDocument(node.Document.ID, zipName)
+
+			if err != nil {
+				c.Err(errors.New(fmt.Sprintf("Failed to download file %s with %s", srcName, err.Error())))
+				return
+			}
+
+			fileName := fmt.Sprintf("%s-notes.%s", node.Name(), *format)
+			if err := writeDigest(zipName, node.Name(), fileName, *format, *images); err != nil {
+				c.Err(errors.New(fmt.Sprintf("Failed to export the notes of %s with %s", srcName, err.Error())))
+				return
+			}
+
+			c.Printf("Notes exported in: %s\n", fileName)
+		},
+	}
+}
+
+// writeDigest writes the digest of the annotations of an archive in fileName,
+// the handwritten notes being rendered next to it.
+func writeDigest(zipName, name, fileName, format, imageFormat string) error {
+	file, err := os.Open(zipName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	zip := archive.NewZip()
+	if err := zip.Read(file, fi.Size()); err != nil {
+		return err
+	}
+
+	digest, err := annotations.NewDigest(zip, name, imageFormat, nil)
+	if err != nil {
+		return err
+	}
+	if err := digest.WriteNotes("."); err != nil {
+		return err
+	}
+
+	out, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		err = digest.WriteJSON(out)
+	} else {
+		err = digest.WriteMarkdown(out)
+	}
+	if err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
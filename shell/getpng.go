@@ -0,0 +1,126 @@
+package shell
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/abiosoft/ishell"
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/juruen/rmapi/render"
+)
+
+func getPngCmd(ctx *ShellCtxt) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name:      "getpng",
+		Help:      "copy remote file to local and render its pages as PNG images",
+		Completer: createEntryCompleter(ctx),
+		Func: func(c *ishell.Context) {
+
+			flagSet := flag.NewFlagSet("getpng", flag.ContinueOnError)
+			dpi := flagSet.Float64("d", render.DeviceDPI, "resolution in dots per inch")
+			allPages := flagSet.Bool("a", false, "all pages, including the ones without drawings")
+			if err := flagSet.Parse(c.Args); err != nil {
+				if err != flag.ErrHelp {
+					c.Err(err)
+				}
+				return
+			}
+			argRest := flagSet.Args()
+			if len(argRest) == 0 {
+				c.Err(errors.New("missing source file"))
+				return
+			}
+			if *dpi <= 0 {
+				c.Err(errors.New("dpi must be positive"))
+				return
+			}
+
+			srcName := argRest[0]
+
+			node, err := ctx.api.Filetree.NodeByPath(srcName, ctx.node)
+
+			if err != nil || node.IsDirectory() {
+				c.Err(errors.New("file doesn't exist"))
+				return
+			}
+
+			c.Println(fmt.Sprintf("downloading: [%s]...", srcName))
+
+			zipName := fmt.Sprintf("%s.zip", node.Name())
+			err = ctx.api.FetchDocument(node.Document.ID, zipName)
+
+			if err != nil {
+				c.Err(errors.New(fmt.Sprintf("Failed to download file %s with %s", srcName, err.Error())))
+				return
+			}
+
+			count, err := writePngs(zipName, node.Name(), render.RasterOptions{DPI: *dpi}, *allPages)
+			if err != nil {
+				c.Err(errors.New(fmt.Sprintf("Failed to render %s with %s", srcName, err.Error())))
+				return
+			}
+
+			c.Printf("%d pages rendered as %s-<page>.png\n", count, node.Name())
+		},
+	}
+}
+
+// writePngs renders the pages of an archive as name-<page>.png files,
+// pages being numbered from 1.
+func writePngs(zipName, name string, opts render.RasterOptions, allPages bool) (int, error) {
+	file, err := os.Open(zipName)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	doc, err := archive.Open(file, fi.Size())
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := 0; i < doc.PageCount(); i++ {
+		page, err := doc.Page(i)
+		if err != nil {
+			return count, err
+		}
+
+		data := page.Data
+		if data == nil {
+			if !allPages {
+				continue
+			}
+			data = &rm.Rm{}
+		}
+
+		if err := writePng(fmt.Sprintf("%s-%d.png", name, i+1), data, opts); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+func writePng(fileName string, page *rm.Rm, opts render.RasterOptions) error {
+	out, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+
+	if err := render.PNG(out, page, opts); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,81 @@
+package shell
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/abiosoft/ishell"
+)
+
+func insertCmd(ctx *ShellCtxt) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name:      "insert",
+		Help:      "insert blank pages before a page of a document and upload it as a new document (insert file page new_name)",
+		Completer: createEntryCompleter(ctx),
+		Func: func(c *ishell.Context) {
+
+			flagSet := flag.NewFlagSet("insert", flag.ContinueOnError)
+			template := flagSet.String("t", "Blank", "background template of the pages")
+			count := flagSet.Int("n", 1, "number of pages")
+			if err := flagSet.Parse(c.Args); err != nil {
+				if err != flag.ErrHelp {
+					c.Err(err)
+				}
+				return
+			}
+			argRest := flagSet.Args()
+			if len(argRest) != 3 {
+				c.Err(errors.New("missing source file, page or name of the new document"))
+				return
+			}
+
+			srcName, name := argRest[0], argRest[2]
+			page, err := strconv.Atoi(argRest[1])
+			if err != nil {
+				c.Err(fmt.Errorf("invalid page %s", argRest[1]))
+				return
+			}
+
+			node, err := ctx.api.Filetree.NodeByPath(srcName, ctx.node)
+			if err != nil || node.IsDirectory() {
+				c.Err(errors.New("file doesn't exist"))
+				return
+			}
+
+			tmpDir, err := ioutil.TempDir("", "rmapiinsert")
+			if err != nil {
+				c.Err(err)
+				return
+			}
+			defer os.RemoveAll(tmpDir)
+
+			c.Println(fmt.Sprintf("downloading: [%s]...", srcName))
+			zip, err := fetchZip(ctx, node, tmpDir)
+			if err != nil {
+				c.Err(fmt.Errorf("Failed to download file %s with %v", srcName, err))
+				return
+			}
+
+			// pages are numbered from 1, the page after the last one appends the new pages
+			if err := zip.InsertPages(page-1, *count, *template); err != nil {
+				c.Err(err)
+				return
+			}
+
+			// the new document needs its own id
+			zip.UUID = ""
+
+			dir := node.Parent
+			if dir == nil {
+				dir = ctx.node
+			}
+			if err := uploadZip(ctx, c, zip, name, dir, tmpDir); err != nil {
+				c.Err(err)
+			}
+		},
+	}
+}
@@ -0,0 +1,62 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/abiosoft/ishell"
+	"github.com/juruen/rmapi/archive"
+)
+
+func mergeCmd(ctx *ShellCtxt) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name:      "merge",
+		Help:      "merge documents into a new document (merge file1 file2... new_name)",
+		Completer: createEntryCompleter(ctx),
+		Func: func(c *ishell.Context) {
+			if len(c.Args) < 3 {
+				c.Err(errors.New("missing source files or name of the new document"))
+				return
+			}
+
+			srcNames := c.Args[:len(c.Args)-1]
+			name := c.Args[len(c.Args)-1]
+
+			tmpDir, err := ioutil.TempDir("", "rmapimerge")
+			if err != nil {
+				c.Err(err)
+				return
+			}
+			defer os.RemoveAll(tmpDir)
+
+			var docs []*archive.Zip
+			for _, srcName := range srcNames {
+				node, err := ctx.api.Filetree.NodeByPath(srcName, ctx.node)
+				if err != nil || node.IsDirectory() {
+					c.Err(fmt.Errorf("file %s doesn't exist", srcName))
+					return
+				}
+
+				c.Println(fmt.Sprintf("downloading: [%s]...", srcName))
+				doc, err := fetchZip(ctx, node, tmpDir)
+				if err != nil {
+					c.Err(fmt.Errorf("Failed to download file %s with %v", srcName, err))
+					return
+				}
+				docs = append(docs, doc)
+			}
+
+			zip, err := archive.Merge(docs...)
+			if err != nil {
+				c.Err(err)
+				return
+			}
+
+			if err := uploadZip(ctx, c, zip, name, ctx.node, tmpDir); err != nil {
+				c.Err(err)
+			}
+		},
+	}
+}
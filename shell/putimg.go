@@ -0,0 +1,80 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/abiosoft/ishell"
+	"github.com/juruen/rmapi/convert"
+	"github.com/juruen/rmapi/util"
+)
+
+func putImagesCmd(ctx *ShellCtxt) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name:      "putimg",
+		Help:      "upload local images as a single pdf document, one page per image (putimg name image...)",
+		Completer: createFsEntryCompleter(),
+		Func: func(c *ishell.Context) {
+			if len(c.Args) < 2 {
+				c.Err(errors.New("missing document name or images"))
+				return
+			}
+
+			name, images := c.Args[0], c.Args[1:]
+			for _, image := range images {
+				if _, ext := util.DocPathToName(image); !convert.IsImage(ext) {
+					c.Err(fmt.Errorf("%s is not an image", image))
+					return
+				}
+			}
+
+			if _, err := ctx.api.Filetree.NodeByPath(name, ctx.node); err == nil {
+				c.Err(errors.New("entry already exists"))
+				return
+			}
+
+			tmpDir, err := ioutil.TempDir("", "rmapiimg")
+			if err != nil {
+				c.Err(err)
+				return
+			}
+			defer os.RemoveAll(tmpDir)
+
+			// the name of the file is the name of the document
+			pdfName := filepath.Join(tmpDir, name+".pdf")
+			if err := imagesToPdf(pdfName, images); err != nil {
+				c.Err(err)
+				return
+			}
+
+			c.Printf("uploading: [%s]...", name)
+
+			document, err := ctx.api.UploadDocument(ctx.node.Id(), pdfName)
+			if err != nil {
+				c.Err(fmt.Errorf("Failed to upload file [%s] %v", name, err))
+				return
+			}
+
+			c.Println("OK")
+
+			ctx.api.Filetree.AddDocument(*document)
+		},
+	}
+}
+
+func imagesToPdf(fileName string, images []string) error {
+	out, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+
+	if err := convert.ImagesToPdf(out, images...); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,135 @@
+package shell
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/abiosoft/ishell"
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/encoding/rm"
+)
+
+func putNotebookCmd(ctx *ShellCtxt) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name:      "putnb",
+		Help:      "upload a local directory of .rm pages as a notebook",
+		Completer: createFsEntryCompleter(),
+		Func: func(c *ishell.Context) {
+
+			flagSet := flag.NewFlagSet("putnb", flag.ContinueOnError)
+			template := flagSet.String("t", "Blank", "background template of the pages")
+			if err := flagSet.Parse(c.Args); err != nil {
+				if err != flag.ErrHelp {
+					c.Err(err)
+				}
+				return
+			}
+			argRest := flagSet.Args()
+			if len(argRest) == 0 {
+				c.Err(errors.New("missing source directory"))
+				return
+			}
+
+			srcDir := argRest[0]
+			name := filepath.Base(filepath.Clean(srcDir))
+
+			node := ctx.node
+			var err error
+
+			if len(argRest) == 2 {
+				node, err = ctx.api.Filetree.NodeByPath(argRest[1], ctx.node)
+
+				if err != nil || node.IsFile() {
+					c.Err(errors.New("directory doesn't exist"))
+					return
+				}
+			}
+
+			zip, err := notebookFromDir(srcDir, *template)
+			if err != nil {
+				c.Err(err)
+				return
+			}
+
+			if archive.ThumbnailsEnabled() {
+				zip.MakeThumbnails()
+			}
+
+			tmpDir, err := ioutil.TempDir("", "rmapinb")
+			if err != nil {
+				c.Err(err)
+				return
+			}
+			defer os.RemoveAll(tmpDir)
+
+			if err := uploadZip(ctx, c, zip, name, node, tmpDir); err != nil {
+				c.Err(err)
+			}
+		},
+	}
+}
+
+// notebookFromDir builds a notebook with one page per .rm file of dir.
+// Files named after numbers come first, sorted numerically, then the
+// others sorted by name.
+func notebookFromDir(dir, template string) (*archive.Zip, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.ToLower(filepath.Ext(e.Name())) == ".rm" {
+			files = append(files, e.Name())
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no .rm file found")
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return pageFileLess(files[i], files[j])
+	})
+
+	zip := archive.NewNotebook()
+	for _, f := range files {
+		data, err := ioutil.ReadFile(filepath.Join(dir, f))
+		if err != nil {
+			return nil, err
+		}
+
+		page := rm.New()
+		if err := page.UnmarshalBinary(data); err != nil {
+			return nil, fmt.Errorf("%s: %v", f, err)
+		}
+
+		idx := zip.AddPage()
+		zip.SetTemplate(idx, template)
+		zip.SetData(idx, page)
+	}
+
+	return zip, nil
+}
+
+// pageFileLess orders the page files of notebookFromDir.
+func pageFileLess(a, b string) bool {
+	na, erra := strconv.Atoi(strings.TrimSuffix(a, filepath.Ext(a)))
+	nb, errb := strconv.Atoi(strings.TrimSuffix(b, filepath.Ext(b)))
+	switch {
+	case erra == nil && errb == nil && na != nb:
+		return na < nb
+	case erra == nil && errb != nil:
+		return true
+	case erra != nil && errb == nil:
+		return false
+	}
+	return a < b
+}
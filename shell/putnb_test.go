@@ -0,0 +1,59 @@
+package shell
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/juruen/rmapi/encoding/rm"
+)
+
+func TestNotebookFromDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "putnb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i, name := range []string{"10.rm", "2.rm", "notes.txt"} {
+		page := &rm.Rm{Version: rm.V5, Layers: make([]rm.Layer, i+1)}
+		data, err := page.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	zip, err := notebookFromDir(dir, "P Lines small")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(zip.Pages) != 2 || zip.Content.PageCount != 2 {
+		t.Fatalf("got %d pages, want 2", len(zip.Pages))
+	}
+	// 2.rm has 2 layers and comes before 10.rm
+	if len(zip.Pages[0].Data.Layers) != 2 || len(zip.Pages[1].Data.Layers) != 1 {
+		t.Error("pages not sorted numerically")
+	}
+	if zip.Pages[1].Pagedata != "P Lines small" {
+		t.Errorf("wrong template %q", zip.Pages[1].Pagedata)
+	}
+}
+
+func TestPageFileLess(t *testing.T) {
+	files := []string{"b.rm", "10.rm", "a.rm", "9.rm", "010.rm", "1.rm"}
+	sort.Slice(files, func(i, j int) bool {
+		return pageFileLess(files[i], files[j])
+	})
+
+	expected := []string{"1.rm", "9.rm", "010.rm", "10.rm", "a.rm", "b.rm"}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("got %v, expected %v", files, expected)
+	}
+}
@@ -0,0 +1,109 @@
+package shell
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/abiosoft/ishell"
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/encoding/rm"
+)
+
+func rmDumpCmd(ctx *ShellCtxt) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "rmdump",
+		Help: "dump a page of a downloaded zip file as JSON or CSV",
+		Func: func(c *ishell.Context) {
+
+			flagSet := flag.NewFlagSet("rmdump", flag.ContinueOnError)
+			asCsv := flagSet.Bool("c", false, "one CSV record per segment instead of JSON")
+			output := flagSet.String("o", "", "write the dump to this file")
+			if err := flagSet.Parse(c.Args); err != nil {
+				if err != flag.ErrHelp {
+					c.Err(err)
+				}
+				return
+			}
+			argRest := flagSet.Args()
+			if len(argRest) != 2 {
+				c.Err(errors.New("usage: rmdump [-c] [-o file] local.zip page"))
+				return
+			}
+
+			pageNum, err := strconv.Atoi(argRest[1])
+			if err != nil || pageNum < 1 {
+				c.Err(errors.New("page must be a number starting at 1"))
+				return
+			}
+
+			page, err := readZipPage(argRest[0], pageNum-1)
+			if err != nil {
+				c.Err(err)
+				return
+			}
+
+			var out bytes.Buffer
+			if *asCsv {
+				err = rm.WriteCSV(&out, page)
+			} else {
+				var data []byte
+				data, err = json.MarshalIndent(page, "", "  ")
+				out.Write(data)
+				out.WriteString("\n")
+			}
+			if err != nil {
+				c.Err(err)
+				return
+			}
+
+			if *output == "" {
+				c.Print(out.String())
+				return
+			}
+
+			if err := ioutil.WriteFile(*output, out.Bytes(), 0644); err != nil {
+				c.Err(err)
+				return
+			}
+			c.Printf("page %d dumped in: %s\n", pageNum, *output)
+		},
+	}
+}
+
+// readZipPage returns the drawing of page idx of a local zip file.
+func readZipPage(zipName string, idx int) (*rm.Rm, error) {
+	file, err := os.Open(zipName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := archive.Open(file, fi.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	if idx >= doc.PageCount() {
+		return nil, fmt.Errorf("page %d out of range, the document has %d pages", idx+1, doc.PageCount())
+	}
+	page, err := doc.Page(idx)
+	if err != nil {
+		return nil, err
+	}
+	if page.Data == nil {
+		return nil, fmt.Errorf("page %d has no drawing", idx+1)
+	}
+
+	return page.Data, nil
+}
@@ -60,9 +60,18 @@ func RunShell(apiCtx *api.ApiCtx, args []string) error {
 	shell.AddCmd(mvCmd(ctx))
 	shell.AddCmd(putCmd(ctx))
 	shell.AddCmd(mputCmd(ctx))
+	shell.AddCmd(putNotebookCmd(ctx))
+	shell.AddCmd(putImagesCmd(ctx))
 	shell.AddCmd(versionCmd(ctx))
 	shell.AddCmd(statCmd(ctx))
 	shell.AddCmd(getACmd(ctx))
+	shell.AddCmd(getNotesCmd(ctx))
+	shell.AddCmd(getPngCmd(ctx))
+	shell.AddCmd(rmDumpCmd(ctx))
+	shell.AddCmd(splitCmd(ctx))
+	shell.AddCmd(mergeCmd(ctx))
+	shell.AddCmd(insertCmd(ctx))
+	shell.AddCmd(fsckCmd(ctx))
 	shell.AddCmd(findCmd(ctx))
 
 	setCustomCompleter(shell)
@@ -0,0 +1,102 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/abiosoft/ishell"
+	"github.com/juruen/rmapi/archive"
+)
+
+func splitCmd(ctx *ShellCtxt) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name:      "split",
+		Help:      "split a document into new documents, one per range of pages (split file 1-3 4 5-)",
+		Completer: createEntryCompleter(ctx),
+		Func: func(c *ishell.Context) {
+			if len(c.Args) < 2 {
+				c.Err(errors.New("missing source file or page ranges"))
+				return
+			}
+
+			srcName := c.Args[0]
+			node, err := ctx.api.Filetree.NodeByPath(srcName, ctx.node)
+			if err != nil || node.IsDirectory() {
+				c.Err(errors.New("file doesn't exist"))
+				return
+			}
+
+			tmpDir, err := ioutil.TempDir("", "rmapisplit")
+			if err != nil {
+				c.Err(err)
+				return
+			}
+			defer os.RemoveAll(tmpDir)
+
+			c.Println(fmt.Sprintf("downloading: [%s]...", srcName))
+			doc, err := fetchZip(ctx, node, tmpDir)
+			if err != nil {
+				c.Err(fmt.Errorf("Failed to download file %s with %v", srcName, err))
+				return
+			}
+
+			ranges, err := parsePageRanges(c.Args[1:], len(doc.Pages))
+			if err != nil {
+				c.Err(err)
+				return
+			}
+
+			docs, err := doc.Split(ranges...)
+			if err != nil {
+				c.Err(err)
+				return
+			}
+
+			dir := node.Parent
+			if dir == nil {
+				dir = ctx.node
+			}
+			for i, d := range docs {
+				name := fmt.Sprintf("%s %s", node.Name(), c.Args[i+1])
+				if err := uploadZip(ctx, c, d, name, dir, tmpDir); err != nil {
+					c.Err(err)
+					return
+				}
+			}
+		},
+	}
+}
+
+// parsePageRanges parses ranges of pages numbered from 1, such as
+// "3", "1-3" or "5-" for the pages from the fifth one to the last one.
+func parsePageRanges(args []string, pageCount int) ([]archive.PageRange, error) {
+	var ranges []archive.PageRange
+	for _, arg := range args {
+		bounds := strings.SplitN(arg, "-", 2)
+
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid page range %s", arg)
+		}
+		last := first
+		if len(bounds) == 2 {
+			last = pageCount
+			if bounds[1] != "" {
+				if last, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid page range %s", arg)
+				}
+			}
+		}
+
+		if first < 1 || last < first || last > pageCount {
+			return nil, fmt.Errorf("page range %s out of the %d pages of the document", arg, pageCount)
+		}
+		ranges = append(ranges, archive.PageRange{Start: first - 1, End: last})
+	}
+
+	return ranges, nil
+}
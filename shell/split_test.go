@@ -0,0 +1,25 @@
+package shell
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/juruen/rmapi/archive"
+)
+
+func TestParsePageRanges(t *testing.T) {
+	ranges, err := parsePageRanges([]string{"1-3", "4", "5-"}, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []archive.PageRange{{Start: 0, End: 3}, {Start: 3, End: 4}, {Start: 4, End: 8}}
+	if !reflect.DeepEqual(ranges, want) {
+		t.Errorf("got %v, want %v", ranges, want)
+	}
+
+	for _, arg := range []string{"0", "3-2", "7-9", "a-b", "-2"} {
+		if _, err := parsePageRanges([]string{arg}, 8); err == nil {
+			t.Errorf("expected an error for %s", arg)
+		}
+	}
+}
@@ -3,23 +3,40 @@ package util
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
 	"path"
 	"strings"
 
+	"github.com/juruen/rmapi/convert"
 	"github.com/juruen/rmapi/model"
 )
 
-var supportedExt = map[string]bool{
+// documentExt lists the extensions of the files uploaded as they are.
+var documentExt = map[string]bool{
 	"epub": true,
 	"pdf":  true,
 	"zip":  true,
 	"rm":   true,
 }
 
+// IsFileTypeSupported tells if files with the extension ext (without .)
+// can be uploaded, images and text files being converted to pdf.
 func IsFileTypeSupported(ext string) bool {
-	return supportedExt[ext]
+	return documentExt[ext] || convert.IsSupported(ext)
+}
+
+// Safely runs f, turning a panic into an error: unipdf is known to panic
+// on some files, which shouldn't prevent handling the rest of a document.
+func Safely(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return f()
 }
 
 // DocPathToName extracts the file name and file extension (without .) from a given path
@@ -0,0 +1,14 @@
+package util
+
+import "testing"
+
+func TestSafely(t *testing.T) {
+	err := Safely(func() error {
+		var m map[string]int
+		m["panic"]++
+		return nil
+	})
+	if err == nil {
+		t.Error("a panic should be turned into an error")
+	}
+}